@@ -0,0 +1,542 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package bench is a load generator for the rest api. it creates users, fires a mix of requests at them and checks
+// that their balances match the credits which were accepted
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+// the requests fired by the bench
+const (
+	OpAdd     = "add"
+	OpBalance = "balance"
+	OpHistory = "history"
+)
+
+// settleAttempts is the number of times a credit with an unknown outcome is resent
+const settleAttempts = 5
+
+// historyPageSize is the page size the fees of the unpriced credits are looked up by
+const historyPageSize = 100
+
+// Config holds the config of a bench run
+type Config struct {
+	// URL is the base url of the server, ex - http://localhost:8080
+	URL         string
+	Users       int
+	Concurrency int
+	Duration    time.Duration
+	Mix         Mix
+	// APIKey or Token authenticate the requests, the caller must be granted users:admin
+	APIKey string
+	Token  string
+	// Timeout is the timeout of a single request
+	Timeout time.Duration
+	// MaxAmount is the largest amount credited, the amounts are whole numbers from 1
+	MaxAmount      int
+	OpeningBalance float64
+	PageSize       int
+}
+
+// Mix is the weight of every request
+type Mix map[string]int
+
+// ParseMix parses a comma separated list of weights, ex - add=60,balance=30,history=10
+func ParseMix(s string) (Mix, error) {
+	m := Mix{}
+	for _, part := range strings.Split(s, ",") {
+		op, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mix %q, expected op=weight", part)
+		}
+
+		switch op {
+		case OpAdd, OpBalance, OpHistory:
+		default:
+			return nil, fmt.Errorf("unknown op %q, expected one of add, balance, history", op)
+		}
+
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight of %s %q", op, weight)
+		}
+		m[op] += w
+	}
+
+	if m.total() == 0 {
+		return nil, errors.New("mix has no weight")
+	}
+	return m, nil
+}
+
+func (m Mix) total() int {
+	t := 0
+	for _, w := range m {
+		t += w
+	}
+	return t
+}
+
+// pick returns an op at random by the weights
+func (m Mix) pick(r *rand.Rand) string {
+	n := r.IntN(m.total())
+	for _, op := range []string{OpAdd, OpBalance, OpHistory} {
+		if n < m[op] {
+			return op
+		}
+		n -= m[op]
+	}
+	return OpAdd
+}
+
+// user is a user created by the bench and the credits made to it
+type user struct {
+	id      string
+	opening float64
+
+	mu sync.Mutex
+	// accepted is the sum of the credits the server accepted, net of their fees
+	accepted float64
+	// unpriced are the transaction ids of the accepted credits whose fee is not known yet. their fees are looked up
+	// in the history before the balance is checked
+	unpriced map[string]bool
+	// unknown are the credits which failed without a response, the server may have applied them
+	unknown []credit
+	// unresolved is the number of unknown credits which could not be settled
+	unresolved int
+}
+
+type credit struct {
+	txID   string
+	amount float64
+}
+
+// result is the outcome of a request
+type result struct {
+	status int
+	code   string
+	data   json.RawMessage
+}
+
+// errorKey returns the key the failed request is counted by
+func (r result) errorKey() string {
+	if r.code == "" {
+		return strconv.Itoa(r.status)
+	}
+	return fmt.Sprintf("%d %s", r.status, r.code)
+}
+
+type bench struct {
+	cfg    Config
+	client *http.Client
+	run    string
+	users  []*user
+	seq    atomic.Int64
+
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	failed    map[string]int64
+	errors    map[string]int64
+}
+
+// Run creates the users, fires the requests for the duration and checks the balances of the users
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	b := &bench{
+		cfg:       cfg,
+		client:    &http.Client{Timeout: cfg.Timeout},
+		run:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		latencies: map[string][]time.Duration{},
+		failed:    map[string]int64{},
+		errors:    map[string]int64{},
+	}
+
+	if err := b.createUsers(ctx); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	b.fire(ctx)
+	elapsed := time.Since(start)
+
+	rep := b.report(elapsed)
+	rep.Settled, rep.Unresolved = b.settle(ctx)
+
+	if err := b.check(ctx, rep); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// createUsers creates the users of the run
+func (b *bench) createUsers(ctx context.Context) error {
+	for i := 0; i < b.cfg.Users; i++ {
+		body := map[string]interface{}{"name": fmt.Sprintf("bench-%s-%d", b.run, i), "opening_balance": b.cfg.OpeningBalance}
+
+		res, err := b.do(ctx, http.MethodPost, "/v1/users", body)
+		if err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		if res.status != http.StatusCreated {
+			return fmt.Errorf("failed to create user: %s", res.errorKey())
+		}
+
+		var u struct {
+			ID      string  `json:"id"`
+			Balance float64 `json:"balance"`
+		}
+		if err := json.Unmarshal(res.data, &u); err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		b.users = append(b.users, &user{id: u.ID, opening: u.Balance})
+	}
+	return nil
+}
+
+// fire runs the workers for the duration. the requests in flight are completed when the duration is over
+func (b *bench) fire(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, b.cfg.Duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for w := 0; w < b.cfg.Concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			r := rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), uint64(w)))
+			for ctx.Err() == nil {
+				b.fireOne(context.WithoutCancel(ctx), r)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// fireOne fires a single request of the mix to a random user
+func (b *bench) fireOne(ctx context.Context, r *rand.Rand) {
+	u := b.users[r.IntN(len(b.users))]
+	op := b.cfg.Mix.pick(r)
+
+	var (
+		res   result
+		err   error
+		start = time.Now()
+	)
+	switch op {
+	case OpAdd:
+		c := credit{txID: fmt.Sprintf("tx_bench_%s_%d", b.run, b.seq.Add(1)), amount: float64(r.IntN(b.cfg.MaxAmount) + 1)}
+		res, err = b.credit(ctx, u, c)
+		switch {
+		case err != nil || res.status >= http.StatusInternalServerError:
+			u.addUnknown(c)
+		case res.status == http.StatusAccepted:
+			u.accept(c.amount - res.fee())
+		}
+	case OpBalance:
+		res, err = b.do(ctx, http.MethodGet, "/v1/users/"+u.id+"/balance", nil)
+	case OpHistory:
+		res, err = b.do(ctx, http.MethodGet, fmt.Sprintf("/v1/users/%s/history?page_size=%d", u.id, b.cfg.PageSize), nil)
+	}
+
+	b.record(op, time.Since(start), res, err)
+}
+
+// credit sends a credit to a user
+func (b *bench) credit(ctx context.Context, u *user, c credit) (result, error) {
+	return b.do(ctx, http.MethodPost, "/v1/users/"+u.id+"/add", map[string]interface{}{"transaction_id": c.txID, "amount": c.amount})
+}
+
+// record records the latency and the outcome of a request
+func (b *bench) record(op string, latency time.Duration, res result, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.latencies[op] = append(b.latencies[op], latency)
+
+	switch {
+	case err != nil:
+		b.failed[op]++
+		b.errors["transport"]++
+	case res.status >= http.StatusBadRequest:
+		b.failed[op]++
+		b.errors[res.errorKey()]++
+	}
+}
+
+// settle resends the credits with an unknown outcome with their transaction ids. a credit was applied if it's
+// accepted now or its transaction id was processed already. it returns the number of settled and unresolved credits
+func (b *bench) settle(ctx context.Context) (settled, unresolved int) {
+	type job struct {
+		u *user
+		c credit
+	}
+
+	jobs := make(chan job)
+	go func() {
+		defer close(jobs)
+		for _, u := range b.users {
+			for _, c := range u.unknown {
+				jobs <- job{u: u, c: c}
+			}
+		}
+	}()
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for w := 0; w < b.cfg.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				ok := b.settleCredit(ctx, j.u, j.c)
+
+				mu.Lock()
+				if ok {
+					settled++
+				} else {
+					j.u.unresolved++
+					unresolved++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return settled, unresolved
+}
+
+func (b *bench) settleCredit(ctx context.Context, u *user, c credit) bool {
+	for attempt := 0; attempt < settleAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+
+		res, err := b.credit(ctx, u, c)
+		switch {
+		case err != nil || res.status >= http.StatusInternalServerError || res.status == http.StatusTooManyRequests:
+			continue
+		case res.status == http.StatusAccepted:
+			u.accept(c.amount - res.fee())
+			return true
+		case res.code == response.CodeTransactionProcessed:
+			// applied by the first attempt, whose response and so its fee was lost
+			u.acceptUnpriced(c)
+			return true
+		default:
+			// rejected, so it was not applied
+			return true
+		}
+	}
+	return false
+}
+
+// check compares the balance of every user with its opening balance and the credits it was sent. the users with
+// unresolved credits can't be checked
+func (b *bench) check(ctx context.Context, rep *Report) error {
+	for _, u := range b.users {
+		if u.unresolved > 0 {
+			continue
+		}
+
+		if err := b.price(ctx, u); err != nil {
+			return fmt.Errorf("failed to check balance: %w", err)
+		}
+
+		res, err := b.do(ctx, http.MethodGet, "/v1/users/"+u.id+"/balance", nil)
+		if err != nil {
+			return fmt.Errorf("failed to check balance: %w", err)
+		}
+		if res.status != http.StatusOK {
+			return fmt.Errorf("failed to check balance: %s", res.errorKey())
+		}
+
+		var balance struct {
+			Balance float64 `json:"balance"`
+		}
+		if err := json.Unmarshal(res.data, &balance); err != nil {
+			return fmt.Errorf("failed to check balance: %w", err)
+		}
+
+		rep.Checked++
+		if want := u.opening + u.accepted; !equalAmounts(want, balance.Balance) {
+			rep.Mismatches = append(rep.Mismatches, Mismatch{UserID: u.id, Expected: want, Actual: balance.Balance})
+		}
+	}
+	return nil
+}
+
+// price subtracts the fees of the unpriced credits of a user, which it looks up in the history of the user
+func (b *bench) price(ctx context.Context, u *user) error {
+	for page := ""; len(u.unpriced) > 0; {
+		res, err := b.do(ctx, http.MethodGet, fmt.Sprintf("/v1/users/%s/history?page_size=%d&page=%s", u.id, historyPageSize, url.QueryEscape(page)), nil)
+		if err != nil {
+			return err
+		}
+		if res.status != http.StatusOK {
+			return fmt.Errorf("failed to list history: %s", res.errorKey())
+		}
+
+		var history struct {
+			NextPage  string `json:"next_page"`
+			Histories []struct {
+				TransactionID string  `json:"transaction_id"`
+				Fee           float64 `json:"fee"`
+			} `json:"histories"`
+		}
+		if err := json.Unmarshal(res.data, &history); err != nil {
+			return err
+		}
+
+		for _, h := range history.Histories {
+			if u.unpriced[h.TransactionID] {
+				u.accepted -= h.Fee
+				delete(u.unpriced, h.TransactionID)
+			}
+		}
+		if len(history.Histories) == 0 || history.NextPage == "" {
+			// an unpriced credit which is not in the history leaves a mismatch
+			return nil
+		}
+		page = history.NextPage
+	}
+	return nil
+}
+
+// report returns the report of the requests fired
+func (b *bench) report(elapsed time.Duration) *Report {
+	rep := &Report{Elapsed: elapsed, Users: len(b.users), Errors: b.errors}
+
+	ops := make([]string, 0, len(b.latencies))
+	for op := range b.latencies {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	for _, op := range ops {
+		ls := b.latencies[op]
+		sort.Slice(ls, func(i, j int) bool { return ls[i] < ls[j] })
+
+		rep.Ops = append(rep.Ops, OpReport{
+			Op:       op,
+			Requests: int64(len(ls)),
+			Errors:   b.failed[op],
+			P50:      percentile(ls, 50),
+			P90:      percentile(ls, 90),
+			P99:      percentile(ls, 99),
+			Max:      ls[len(ls)-1],
+		})
+	}
+
+	for _, u := range b.users {
+		rep.Credited += u.accepted
+	}
+	return rep
+}
+
+// fee returns the fee charged on an accepted credit
+func (r result) fee() float64 {
+	var added struct {
+		Fee float64 `json:"fee"`
+	}
+	// a server without fees may not answer the fee
+	_ = json.Unmarshal(r.data, &added)
+	return added.Fee
+}
+
+// do sends a request and decodes the response envelope
+func (b *bench) do(ctx context.Context, method, path string, body interface{}) (result, error) {
+	var r io.Reader
+	if body != nil {
+		bs, err := json.Marshal(body)
+		if err != nil {
+			return result{}, err
+		}
+		r = bytes.NewReader(bs)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(b.cfg.URL, "/")+path, r)
+	if err != nil {
+		return result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.cfg.APIKey != "" {
+		req.Header.Set(auth.HeaderAPIKey, b.cfg.APIKey)
+	}
+	if b.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.cfg.Token)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return result{}, err
+	}
+	defer resp.Body.Close()
+
+	var env struct {
+		ErrorCode string          `json:"error_code"`
+		Data      json.RawMessage `json:"data"`
+	}
+	// the body of a few errors is not an envelope, they are counted by their status
+	_ = json.NewDecoder(resp.Body).Decode(&env)
+
+	return result{status: resp.StatusCode, code: env.ErrorCode, data: env.Data}, nil
+}
+
+func (u *user) accept(amount float64) {
+	u.mu.Lock()
+	u.accepted += amount
+	u.mu.Unlock()
+}
+
+func (u *user) acceptUnpriced(c credit) {
+	u.mu.Lock()
+	u.accepted += c.amount
+	if u.unpriced == nil {
+		u.unpriced = map[string]bool{}
+	}
+	u.unpriced[c.txID] = true
+	u.mu.Unlock()
+}
+
+func (u *user) addUnknown(c credit) {
+	u.mu.Lock()
+	u.unknown = append(u.unknown, c)
+	u.mu.Unlock()
+}
@@ -0,0 +1,216 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package bench
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/fee"
+	"github.com/diptomondal007/your-money/app/server/handler"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+func TestParseMix(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Mix
+		err  bool
+	}{
+		{name: "t-01", in: "add=60,balance=30,history=10", want: Mix{OpAdd: 60, OpBalance: 30, OpHistory: 10}},
+		{name: "t-02", in: "add=1", want: Mix{OpAdd: 1}},
+		{name: "t-03", in: " add=1 , add=2", want: Mix{OpAdd: 3}},
+		{name: "t-04", in: "add", err: true},
+		{name: "t-05", in: "debit=1", err: true},
+		{name: "t-06", in: "add=-1", err: true},
+		{name: "t-07", in: "add=0,balance=0", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseMix(tt.in)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, m)
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	ls := make([]time.Duration, 100)
+	for i := range ls {
+		ls[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	assert.Equal(t, 50*time.Millisecond, percentile(ls, 50))
+	assert.Equal(t, 99*time.Millisecond, percentile(ls, 99))
+	assert.Equal(t, 100*time.Millisecond, percentile(ls, 100))
+	assert.Equal(t, time.Millisecond, percentile(ls[:1], 99))
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+}
+
+// newTestServer serves the rest api over the memory repository with authentication disabled. fees charge the credits
+// if they're set, the house account of the policy is created
+func newTestServer(t *testing.T, fees *fee.Policy, wrap func(http.Handler) http.Handler) *httptest.Server {
+	ur := repository.NewMemoryUserRepo()
+	if fees != nil {
+		require.NoError(t, ur.CreateUser(context.Background(), &model.User{ID: "house", Name: "House", Status: model.UserStatusActive}))
+	}
+
+	e := echo.New()
+	router := handler.NewRouter(e, config.API{})
+	handler.NewHandler(router, usecase.NewUserUseCase(ur, fees), auth.NewAuthorizer(nil, nil), nil)
+
+	var h http.Handler = e
+	if wrap != nil {
+		h = wrap(e)
+	}
+
+	s := httptest.NewServer(h)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func testConfig(url string) Config {
+	return Config{
+		URL:            url,
+		Users:          3,
+		Concurrency:    4,
+		Duration:       200 * time.Millisecond,
+		Mix:            Mix{OpAdd: 60, OpBalance: 30, OpHistory: 10},
+		Timeout:        5 * time.Second,
+		MaxAmount:      10,
+		OpeningBalance: 100,
+		PageSize:       5,
+	}
+}
+
+func TestRun(t *testing.T) {
+	s := newTestServer(t, nil, nil)
+
+	rep, err := Run(context.Background(), testConfig(s.URL))
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, rep.Users)
+	assert.Greater(t, rep.Requests(), int64(0))
+	assert.Greater(t, rep.Credited, float64(0))
+	assert.Empty(t, rep.Errors)
+	assert.Equal(t, 3, rep.Checked)
+	assert.Empty(t, rep.Mismatches)
+
+	var out bytes.Buffer
+	require.NoError(t, rep.Print(&out))
+	assert.Contains(t, out.String(), "balances checked: 3, mismatched: 0")
+}
+
+func TestRunSettlesCreditsWithoutResponse(t *testing.T) {
+	// every third of the first credits is applied, but its response is lost
+	var credits atomic.Int64
+	s := newTestServer(t, nil, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if n := credits.Add(1); !strings.HasSuffix(r.URL.Path, "/add") || n > 30 || n%3 != 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(httptest.NewRecorder(), r)
+			w.WriteHeader(http.StatusBadGateway)
+		})
+	})
+
+	cfg := testConfig(s.URL)
+	cfg.Mix = Mix{OpAdd: 1}
+
+	rep, err := Run(context.Background(), cfg)
+	require.NoError(t, err)
+
+	assert.Greater(t, rep.Errors["502"], int64(0))
+	assert.Greater(t, rep.Settled, 0)
+	assert.Equal(t, 0, rep.Unresolved)
+	assert.Equal(t, 3, rep.Checked)
+	assert.Empty(t, rep.Mismatches)
+}
+
+func TestRunWithFees(t *testing.T) {
+	fees, err := fee.NewPolicy(config.Fees{Schedules: "standard=2%", DefaultSchedule: "standard", HouseAccount: "house"})
+	require.NoError(t, err)
+
+	// every third of the first credits is applied, but its response and so its fee is lost
+	var credits atomic.Int64
+	s := newTestServer(t, fees, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if n := credits.Add(1); !strings.HasSuffix(r.URL.Path, "/add") || n > 30 || n%3 != 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(httptest.NewRecorder(), r)
+			w.WriteHeader(http.StatusBadGateway)
+		})
+	})
+
+	cfg := testConfig(s.URL)
+	cfg.Mix = Mix{OpAdd: 1}
+
+	rep, err := Run(context.Background(), cfg)
+	require.NoError(t, err)
+
+	assert.Greater(t, rep.Settled, 0)
+	assert.Equal(t, 0, rep.Unresolved)
+	assert.Equal(t, 3, rep.Checked)
+	assert.Empty(t, rep.Mismatches)
+}
+
+func TestRunReportsMismatches(t *testing.T) {
+	// credits are accepted without being applied
+	s := newTestServer(t, nil, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/add") {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusAccepted)
+				w.Write([]byte(`{"success":true,"status_code":202,"data":{"current_balance":0}}`))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	cfg := testConfig(s.URL)
+	cfg.Mix = Mix{OpAdd: 1}
+
+	rep, err := Run(context.Background(), cfg)
+	require.NoError(t, err)
+	assert.Len(t, rep.Mismatches, 3)
+}
@@ -0,0 +1,140 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is the outcome of a bench run
+type Report struct {
+	Elapsed time.Duration
+	Users   int
+	Ops     []OpReport
+	// Errors are the failed requests by their status and error code
+	Errors map[string]int64
+	// Credited is the sum of the credits the server accepted
+	Credited float64
+	// Settled are the credits without a response which were resent, Unresolved the ones which failed again
+	Settled    int
+	Unresolved int
+	// Checked is the number of users whose balance was checked
+	Checked    int
+	Mismatches []Mismatch
+}
+
+// OpReport is the throughput and the latencies of a request
+type OpReport struct {
+	Op       string
+	Requests int64
+	Errors   int64
+	P50      time.Duration
+	P90      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+}
+
+// Mismatch is a user whose balance doesn't match its opening balance and the credits it accepted
+type Mismatch struct {
+	UserID   string
+	Expected float64
+	Actual   float64
+}
+
+// Requests returns the number of requests fired
+func (r *Report) Requests() int64 {
+	var n int64
+	for _, op := range r.Ops {
+		n += op.Requests
+	}
+	return n
+}
+
+// Throughput returns the requests per second
+func (r *Report) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Requests()) / r.Elapsed.Seconds()
+}
+
+// Print writes the report in a human readable form
+func (r *Report) Print(w io.Writer) error {
+	fmt.Fprintf(w, "%d requests to %d users in %s, %.1f req/s\n\n", r.Requests(), r.Users, r.Elapsed.Round(time.Millisecond), r.Throughput())
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OP\tREQUESTS\tERRORS\tREQ/S\tP50\tP90\tP99\tMAX")
+	for _, op := range r.Ops {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\n", op.Op, op.Requests, op.Errors, float64(op.Requests)/r.Elapsed.Seconds(),
+			roundLatency(op.P50), roundLatency(op.P90), roundLatency(op.P99), roundLatency(op.Max))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Errors) > 0 {
+		keys := make([]string, 0, len(r.Errors))
+		for k := range r.Errors {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintln(w, "\nerrors")
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s: %d\n", k, r.Errors[k])
+		}
+	}
+
+	fmt.Fprintf(w, "\ncredited %s, %d credits without a response settled, %d unresolved\n", formatAmount(r.Credited), r.Settled, r.Unresolved)
+	fmt.Fprintf(w, "balances checked: %d, mismatched: %d\n", r.Checked, len(r.Mismatches))
+	for _, m := range r.Mismatches {
+		fmt.Fprintf(w, "  user %s: expected %s, actual %s\n", m.UserID, formatAmount(m.Expected), formatAmount(m.Actual))
+	}
+	return nil
+}
+
+// percentile returns the p-th percentile of the sorted latencies by the nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// equalAmounts compares amounts to the cent. postgres stores the balances as float4
+func equalAmounts(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
+
+func formatAmount(a float64) string {
+	return fmt.Sprintf("%.2f", a)
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,151 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+)
+
+// audited actions
+const (
+	ActionBalanceCredit         = "balance.credit"
+	ActionUserStatusChange      = "user.status_change"
+	ActionUserFeeScheduleChange = "user.fee_schedule_change"
+	ActionUserCreate            = "user.create"
+	ActionLedgerAdjust          = "ledger.adjust"
+	ActionLedgerReconcile       = "ledger.reconcile"
+	ActionAPIKeyCreate          = "api_key.create"
+	ActionAPIKeyRevoke          = "api_key.revoke"
+	ActionWebhookCreate         = "webhook.create"
+	ActionWebhookUpdate         = "webhook.update"
+	ActionWebhookDelete         = "webhook.delete"
+	ActionWebhookReplay         = "webhook.replay"
+)
+
+// actor kinds besides the authenticated principals
+const (
+	// ActorKindCLI is an operator running a command of the binary
+	ActorKindCLI = "cli"
+	// ActorKindSystem is used when the caller of a mutation is unknown
+	ActorKindSystem = "system"
+)
+
+// maxPayload is the max number of bytes of a request body stored in an audit event
+const maxPayload = 64 << 10
+
+// Call describes who made a mutating call and from where
+type Call struct {
+	ActorKind string
+	ActorID   string
+	RequestID string
+	SourceIP  string
+	Payload   string
+}
+
+type ctxKey struct{}
+
+// WithCall returns a copy of ctx carrying the call
+func WithCall(ctx context.Context, call Call) context.Context {
+	return context.WithValue(ctx, ctxKey{}, call)
+}
+
+// CallFrom returns the call stored in ctx. mutations made outside of a call are attributed to the system
+func CallFrom(ctx context.Context) Call {
+	if call, ok := ctx.Value(ctxKey{}).(Call); ok {
+		return call
+	}
+	return Call{ActorKind: ActorKindSystem, ActorID: ActorKindSystem}
+}
+
+// NewEvent returns the audit event of action on resource made by the call in ctx.
+// details is marshalled to json
+func NewEvent(ctx context.Context, action, resource, outcome string, details interface{}) *model.AuditEvent {
+	call := CallFrom(ctx)
+
+	d, _ := json.Marshal(details)
+
+	return &model.AuditEvent{
+		CreatedAt: time.Now().UTC(),
+		RequestID: nullString(call.RequestID),
+		ActorKind: call.ActorKind,
+		ActorID:   call.ActorID,
+		Action:    action,
+		Resource:  resource,
+		Outcome:   outcome,
+		SourceIP:  nullString(call.SourceIP),
+		Details:   string(d),
+		Payload:   call.Payload,
+	}
+}
+
+// ActorFunc returns the kind and id of the caller of a request if it's known
+type ActorFunc func(c echo.Context) (kind string, id string, ok bool)
+
+// Capture stores the call in the request context so that the mutations made while serving it can be
+// attributed to the caller returned by actor. the body of mutating requests is kept as payload
+func Capture(actor ActorFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			call := Call{
+				ActorKind: ActorKindSystem,
+				ActorID:   ActorKindSystem,
+				RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+				SourceIP:  c.RealIP(),
+			}
+			if kind, id, ok := actor(c); ok {
+				call.ActorKind, call.ActorID = kind, id
+			}
+
+			if req.Method != http.MethodGet && req.Method != http.MethodHead && req.Body != nil {
+				body, err := io.ReadAll(io.LimitReader(req.Body, maxPayload+1))
+				if err != nil {
+					return err
+				}
+				// the rest of the body is left for the handler
+				req.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+
+				if len(body) > maxPayload {
+					body = body[:maxPayload]
+				}
+				call.Payload = string(body)
+			}
+
+			c.SetRequest(req.WithContext(WithCall(req.Context(), call)))
+			return next(c)
+		}
+	}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,93 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package audit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+)
+
+func TestCapture(t *testing.T) {
+	var call Call
+	var body string
+
+	e := echo.New()
+	e.POST("/users/:uid/add", func(c echo.Context) error {
+		call = CallFrom(c.Request().Context())
+		b, err := io.ReadAll(c.Request().Body)
+		body = string(b)
+		if err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusAccepted)
+	}, Capture(func(c echo.Context) (string, string, bool) {
+		return "service", "payments", true
+	}))
+
+	payload := `{"transaction_id":"tx_1","amount":10}`
+
+	req := httptest.NewRequest(http.MethodPost, "/users/u1/add", strings.NewReader(payload))
+	req.Header.Set(echo.HeaderXRealIP, "10.0.0.1")
+	rec := httptest.NewRecorder()
+	rec.Header().Set(echo.HeaderXRequestID, "req-1")
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, Call{ActorKind: "service", ActorID: "payments", RequestID: "req-1", SourceIP: "10.0.0.1", Payload: payload}, call)
+	// the handler still gets the whole body
+	assert.Equal(t, payload, body)
+}
+
+func TestCapturePayloadIsTruncated(t *testing.T) {
+	var call Call
+	var body []byte
+
+	e := echo.New()
+	e.POST("/", func(c echo.Context) error {
+		call = CallFrom(c.Request().Context())
+		body, _ = io.ReadAll(c.Request().Body)
+		return c.NoContent(http.StatusOK)
+	}, Capture(func(c echo.Context) (string, string, bool) { return "", "", false }))
+
+	payload := strings.Repeat("a", maxPayload+10)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload)))
+
+	assert.Len(t, call.Payload, maxPayload)
+	assert.Equal(t, ActorKindSystem, call.ActorKind)
+	assert.Len(t, body, len(payload))
+}
+
+func TestNewEventWithoutCall(t *testing.T) {
+	ev := NewEvent(context.Background(), ActionBalanceCredit, "user:u1", model.AuditOutcomeSuccess, map[string]interface{}{"amount": 10})
+
+	assert.Equal(t, ActorKindSystem, ev.ActorKind)
+	assert.Equal(t, ActorKindSystem, ev.ActorID)
+	assert.False(t, ev.RequestID.Valid)
+	assert.Equal(t, `{"amount":10}`, ev.Details)
+}
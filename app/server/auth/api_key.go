@@ -0,0 +1,120 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/repository"
+)
+
+// apiKeyPrefix is prepended to every api key so that leaked keys are easy to detect
+const apiKeyPrefix = "ym"
+
+var errInvalidAPIKey = errors.New("invalid api key")
+
+// APIKeyStore looks up the stored api keys
+type APIKeyStore interface {
+	GetAPIKey(ctx context.Context, keyID string) (*model.APIKey, error)
+}
+
+// NewAPIKey generates a new api key with the given role and extra scopes. the returned
+// plain key is shown once to the caller, only the hash of its secret part is stored
+func NewAPIKey(name, role string, scopes []string) (string, *model.APIKey, error) {
+	if err := ValidateRole(role); err != nil {
+		return "", nil, err
+	}
+
+	if err := ValidateScopes(scopes); err != nil {
+		return "", nil, err
+	}
+
+	keyID, err := randomHex(8)
+	if err != nil {
+		return "", nil, err
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := &model.APIKey{
+		KeyID:        keyID,
+		Name:         name,
+		HashedSecret: hashSecret(secret),
+		Role:         role,
+		Scopes:       FormatScopes(scopes),
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	return strings.Join([]string{apiKeyPrefix, keyID, secret}, "_"), key, nil
+}
+
+// verifyAPIKey checks the plain key against the stored one and returns the caller
+func verifyAPIKey(ctx context.Context, store APIKeyStore, plain string) (*Principal, error) {
+	parts := strings.Split(plain, "_")
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return nil, errInvalidAPIKey
+	}
+
+	key, err := store.GetAPIKey(ctx, parts[1])
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return nil, errInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if key.RevokedAt.Valid {
+		return nil, errInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(parts[2])), []byte(key.HashedSecret)) != 1 {
+		return nil, errInvalidAPIKey
+	}
+
+	return &Principal{
+		Kind:    KindService,
+		Subject: key.KeyID,
+		Name:    key.Name,
+		Role:    key.Role,
+		Scopes:  resolveScopes(key.Role, ParseScopes(key.Scopes)),
+	}, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,229 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/logger"
+)
+
+// ActionAccessDenied is the audit action recorded for authorization failures
+const ActionAccessDenied = "access.denied"
+
+// AuditLog stores the audit events
+type AuditLog interface {
+	AppendAuditEvent(ctx context.Context, ev *model.AuditEvent) error
+}
+
+// Authorizer authenticates the callers and guards the routes by scope
+type Authorizer struct {
+	authn *Authenticator
+	audit AuditLog
+	sig   *SignatureVerifier
+}
+
+// NewAuthorizer returns a new authorizer. if authn is nil authentication is disabled and
+// every caller is treated as an admin. denials are recorded to audit if it's not nil
+func NewAuthorizer(authn *Authenticator, audit AuditLog) *Authorizer {
+	return &Authorizer{authn: authn, audit: audit}
+}
+
+// SetSignatureVerifier makes the routes guarded by RequireSignature accept only signed requests
+func (a *Authorizer) SetSignatureVerifier(v *SignatureVerifier) {
+	a.sig = v
+}
+
+// RequireSignature verifies the hmac signature of the request if request signing is enabled
+func (a *Authorizer) RequireSignature() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if a.sig == nil {
+				return next(c)
+			}
+			return a.sig.Middleware()(next)(c)
+		}
+	}
+}
+
+// VerifySignature verifies the signature of a request made of method, path and body if request signing is
+// enabled. it's the RequireSignature check of the transports other than http
+func (a *Authorizer) VerifySignature(ctx context.Context, sig Signature, method, path string, body []byte) error {
+	if a.sig == nil {
+		return nil
+	}
+
+	err := a.sig.Verify(ctx, sig, method, path, body)
+	if err != nil && rejected(err) {
+		logger.FromContext(ctx).Warn("request signature rejected", slog.Any("error", err))
+	}
+	return err
+}
+
+// Authenticate stores the authenticated caller in the context
+func (a *Authorizer) Authenticate() echo.MiddlewareFunc {
+	if a.authn != nil {
+		return a.authn.Middleware()
+	}
+
+	anonymous := anonymousPrincipal()
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			setPrincipal(c, anonymous)
+			return next(c)
+		}
+	}
+}
+
+// AuthenticateCredentials authenticates the callers of the transports other than http by an api key or
+// the value of an authorization header
+func (a *Authorizer) AuthenticateCredentials(ctx context.Context, apiKey, authz string) (*Principal, error) {
+	if a.authn == nil {
+		return anonymousPrincipal(), nil
+	}
+	return a.authn.Authenticate(ctx, apiKey, authz)
+}
+
+// Authorize allows the principal of ctx to call route only if it was granted scope. end users may access only
+// their own userID. it's the RequireOwnUser and RequireScope check of the transports other than http,
+// denials are recorded with the request id and source ip of the audit call of ctx
+func (a *Authorizer) Authorize(ctx context.Context, route, scope, userID string) error {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return response.ErrUnauthorized
+	}
+
+	reason := ""
+	switch {
+	case !p.IsService() && p.Subject != userID:
+		reason = "other user"
+	case !p.HasScope(scope):
+		reason = fmt.Sprintf("missing scope %s", scope)
+	default:
+		return nil
+	}
+
+	call := audit.CallFrom(ctx)
+	a.recordDenial(ctx, p, route, "user:"+userID, call.RequestID, call.SourceIP, reason)
+	return response.ErrForbidden
+}
+
+// RequireOwnUser allows end users to access only the user identified by the path param.
+// service callers may access any user
+func (a *Authorizer) RequireOwnUser(param string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p, ok := GetPrincipal(c)
+			if !ok {
+				return response.SendError(c, response.ErrUnauthorized)
+			}
+
+			if !p.IsService() && p.Subject != c.Param(param) {
+				a.deny(c, p, "other user")
+				return response.SendError(c, response.ErrForbidden)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireScope allows only the callers granted scope
+func (a *Authorizer) RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p, ok := GetPrincipal(c)
+			if !ok {
+				return response.SendError(c, response.ErrUnauthorized)
+			}
+
+			if !p.HasScope(scope) {
+				a.deny(c, p, fmt.Sprintf("missing scope %s", scope))
+				return response.SendError(c, response.ErrForbidden)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// deny logs the denial of a http request and records it to the audit log
+func (a *Authorizer) deny(c echo.Context, p *Principal, reason string) {
+	route := fmt.Sprintf("%s %s", c.Request().Method, c.Path())
+	a.recordDenial(c.Request().Context(), p, route, resource(c), c.Response().Header().Get(echo.HeaderXRequestID), c.RealIP(), reason)
+}
+
+// recordDenial logs a denial and records it to the audit log
+func (a *Authorizer) recordDenial(ctx context.Context, p *Principal, route, res, requestID, sourceIP, reason string) {
+	l := logger.FromContext(ctx).With(
+		slog.String("actor_kind", p.Kind),
+		slog.String("actor_id", p.Subject),
+		slog.String("route", route),
+		slog.String("reason", reason),
+	)
+	l.Warn("access denied")
+
+	if a.audit == nil {
+		return
+	}
+
+	details, _ := json.Marshal(map[string]string{"route": route, "reason": reason})
+
+	ev := &model.AuditEvent{
+		CreatedAt: time.Now().UTC(),
+		RequestID: nullString(requestID),
+		ActorKind: p.Kind,
+		ActorID:   p.Subject,
+		Action:    ActionAccessDenied,
+		Resource:  res,
+		Outcome:   model.AuditOutcomeDenied,
+		SourceIP:  nullString(sourceIP),
+		Details:   string(details),
+	}
+
+	if err := a.audit.AppendAuditEvent(ctx, ev); err != nil {
+		l.Error("failed to record access denial", slog.Any("error", err))
+	}
+}
+
+// anonymousPrincipal is the caller of every request while authentication is disabled
+func anonymousPrincipal() *Principal {
+	return &Principal{Kind: KindService, Subject: "anonymous", Role: RoleAdmin, Scopes: resolveScopes(RoleAdmin, nil)}
+}
+
+// resource returns the audited resource of the request
+func resource(c echo.Context) string {
+	if uid := c.Param("uid"); uid != "" {
+		return "user:" + uid
+	}
+	return c.Path()
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
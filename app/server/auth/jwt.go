@@ -0,0 +1,96 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var errInvalidToken = errors.New("invalid bearer token")
+
+// claims are the jwt claims of an end user token
+type claims struct {
+	jwt.RegisteredClaims
+	// Scope is the space separated list of granted scopes. the default user scopes are granted if it's empty
+	Scope string `json:"scope"`
+}
+
+// jwtVerifier verifies end user bearer tokens against a local key set
+type jwtVerifier struct {
+	keys   keySet
+	parser *jwt.Parser
+}
+
+func newJWTVerifier(keys keySet, issuer, audience string) *jwtVerifier {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	return &jwtVerifier{keys: keys, parser: jwt.NewParser(opts...)}
+}
+
+// verify parses and validates the token and returns the end user it was issued for
+func (v *jwtVerifier) verify(token string) (*Principal, error) {
+	claims := claims{}
+
+	_, err := v.parser.ParseWithClaims(token, &claims, v.keyFunc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: subject is missing", errInvalidToken)
+	}
+
+	p := &Principal{Kind: KindUser, Subject: claims.Subject, Role: RoleUser}
+	if claims.Scope != "" {
+		p.Scopes = resolveScopes("", ParseScopes(claims.Scope))
+	} else {
+		p.Scopes = resolveScopes(RoleUser, nil)
+	}
+	return p, nil
+}
+
+func (v *jwtVerifier) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	var key crypto.PublicKey
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			key = k
+		}
+	} else {
+		key = v.keys[kid]
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
@@ -0,0 +1,100 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+	"github.com/diptomondal007/your-money/infrastructure/logger"
+)
+
+// HeaderAPIKey is the header service callers send their api key in
+const HeaderAPIKey = "X-API-Key"
+
+// Authenticator authenticates the callers by api key or jwt bearer token
+type Authenticator struct {
+	keys APIKeyStore
+	jwt  *jwtVerifier
+}
+
+// NewAuthenticator returns a new authenticator. bearer tokens are only accepted if a jwks file is configured
+func NewAuthenticator(cfg config.Auth, keys APIKeyStore) (*Authenticator, error) {
+	a := &Authenticator{keys: keys}
+
+	if cfg.JWKSFile != "" {
+		ks, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = newJWTVerifier(ks, cfg.JWTIssuer, cfg.JWTAudience)
+	}
+
+	return a, nil
+}
+
+// Middleware rejects the requests without valid credentials and stores the caller in the context
+func (a *Authenticator) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			p, err := a.Authenticate(req.Context(), req.Header.Get(HeaderAPIKey), req.Header.Get(echo.HeaderAuthorization))
+			if err != nil {
+				if !IsUnauthenticated(err) {
+					return response.SendError(c, err)
+				}
+
+				logger.FromContext(c.Request().Context()).Warn("authentication failed", slog.Any("error", err))
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer, ApiKey`)
+				return response.SendError(c, response.ErrUnauthorized)
+			}
+
+			setPrincipal(c, p)
+			return next(c)
+		}
+	}
+}
+
+// Authenticate returns the caller identified by an api key or the value of an authorization header
+func (a *Authenticator) Authenticate(ctx context.Context, apiKey, authz string) (*Principal, error) {
+	if apiKey != "" {
+		return verifyAPIKey(ctx, a.keys, apiKey)
+	}
+
+	if token, ok := strings.CutPrefix(authz, "Bearer "); ok {
+		if a.jwt == nil {
+			return nil, fmt.Errorf("%w: bearer tokens are not accepted", errInvalidToken)
+		}
+		return a.jwt.verify(strings.TrimSpace(token))
+	}
+
+	return nil, response.ErrUnauthorized
+}
+
+// IsUnauthenticated reports whether err is caused by missing or invalid credentials
+func IsUnauthenticated(err error) bool {
+	return errors.Is(err, errInvalidAPIKey) || errors.Is(err, errInvalidToken) || errors.Is(err, response.ErrUnauthorized)
+}
@@ -0,0 +1,298 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+const testUserID = "6d7750a1-c3f2-4765-bf8f-33bc80f3f809"
+
+type fakeKeyStore map[string]*model.APIKey
+
+func (f fakeKeyStore) GetAPIKey(ctx context.Context, keyID string) (*model.APIKey, error) {
+	k, ok := f[keyID]
+	if !ok {
+		return nil, repository.ErrAPIKeyNotFound
+	}
+	return k, nil
+}
+
+type fakeAuditLog struct {
+	events []*model.AuditEvent
+}
+
+func (f *fakeAuditLog) AppendAuditEvent(ctx context.Context, ev *model.AuditEvent) error {
+	f.events = append(f.events, ev)
+	return nil
+}
+
+type testEnv struct {
+	e      *echo.Echo
+	signer *rsa.PrivateKey
+	keys   fakeKeyStore
+	audit  *fakeAuditLog
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pk.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pk.E)).Bytes()),
+		}},
+	}
+	b, err := json.Marshal(jwks)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, b, 0o600))
+
+	keys := fakeKeyStore{}
+	a, err := NewAuthenticator(config.Auth{Enabled: true, JWKSFile: path, JWTIssuer: "https://issuer.test", JWTAudience: "your-money"}, keys)
+	assert.NoError(t, err)
+
+	audit := &fakeAuditLog{}
+	az := NewAuthorizer(a, audit)
+
+	ok := func(c echo.Context) error {
+		p, _ := GetPrincipal(c)
+		return c.String(http.StatusOK, p.Kind+":"+p.Subject)
+	}
+
+	e := echo.New()
+	g := e.Group("/users/:uid", az.Authenticate(), az.RequireOwnUser("uid"))
+	g.GET("/balance", ok, az.RequireScope(ScopeBalanceRead))
+	g.POST("/add", ok, az.RequireScope(ScopeBalanceCredit))
+	g.POST("/freeze", ok, az.RequireScope(ScopeUsersAdmin))
+
+	return &testEnv{e: e, signer: pk, keys: keys, audit: audit}
+}
+
+func (te *testEnv) apiKey(t *testing.T, role string, scopes ...string) (string, *model.APIKey) {
+	plain, key, err := NewAPIKey(role+"-service", role, scopes)
+	assert.NoError(t, err)
+	te.keys[key.KeyID] = key
+	return plain, key
+}
+
+func (te *testEnv) token(t *testing.T, claims jwt.RegisteredClaims) string {
+	tk := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tk.Header["kid"] = "test-key"
+
+	s, err := tk.SignedString(te.signer)
+	assert.NoError(t, err)
+	return s
+}
+
+func (te *testEnv) do(uid string, headers map[string]string) *httptest.ResponseRecorder {
+	return te.doRoute(http.MethodGet, "/users/"+uid+"/balance", headers)
+}
+
+func (te *testEnv) doRoute(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	te.e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	te := newTestEnv(t)
+
+	plain, key := te.apiKey(t, RoleSupport)
+
+	revokedPlain, revoked := te.apiKey(t, RoleSupport)
+	revoked.RevokedAt.Valid = true
+
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{name: "valid key", key: plain, want: http.StatusOK},
+		{name: "wrong secret", key: "ym_" + key.KeyID + "_" + strings.Repeat("0", 64), want: http.StatusUnauthorized},
+		{name: "unknown key", key: "ym_0000000000000000_abcd", want: http.StatusUnauthorized},
+		{name: "malformed key", key: "not-a-key", want: http.StatusUnauthorized},
+		{name: "revoked key", key: revokedPlain, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := te.do(testUserID, map[string]string{HeaderAPIKey: tt.key})
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+
+	// services may access any user
+	rec := te.do("another-user", map[string]string{HeaderAPIKey: plain})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "service:"+key.KeyID, rec.Body.String())
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	te := newTestEnv(t)
+
+	valid := jwt.RegisteredClaims{
+		Subject:   testUserID,
+		Issuer:    "https://issuer.test",
+		Audience:  jwt.ClaimStrings{"your-money"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+
+	wrongAudience := valid
+	wrongAudience.Audience = jwt.ClaimStrings{"other"}
+
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	noExpiry := valid
+	noExpiry.ExpiresAt = nil
+
+	tests := []struct {
+		name   string
+		uid    string
+		claims jwt.RegisteredClaims
+		want   int
+	}{
+		{name: "own user", uid: testUserID, claims: valid, want: http.StatusOK},
+		{name: "other user", uid: "another-user", claims: valid, want: http.StatusForbidden},
+		{name: "wrong audience", uid: testUserID, claims: wrongAudience, want: http.StatusUnauthorized},
+		{name: "expired", uid: testUserID, claims: expired, want: http.StatusUnauthorized},
+		{name: "no expiry", uid: testUserID, claims: noExpiry, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := te.do(tt.uid, map[string]string{echo.HeaderAuthorization: "Bearer " + te.token(t, tt.claims)})
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestMissingCredentials(t *testing.T) {
+	te := newTestEnv(t)
+
+	rec := te.do(testUserID, nil)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "Bearer, ApiKey", rec.Header().Get(echo.HeaderWWWAuthenticate))
+	assert.Equal(t, `{"success":false,"message":"authentication required","status_code":401,"error_code":"unauthorized"}`+"\n", rec.Body.String())
+}
+
+func TestScopes(t *testing.T) {
+	te := newTestEnv(t)
+
+	support, _ := te.apiKey(t, RoleSupport)
+	payments, _ := te.apiKey(t, RolePayments)
+	admin, _ := te.apiKey(t, RoleAdmin)
+	supportWithCredit, _ := te.apiKey(t, RoleSupport, ScopeBalanceCredit)
+
+	userToken := te.token(t, jwt.RegisteredClaims{
+		Subject:   testUserID,
+		Issuer:    "https://issuer.test",
+		Audience:  jwt.ClaimStrings{"your-money"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	})
+
+	base := "/users/" + testUserID
+	tests := []struct {
+		name    string
+		headers map[string]string
+		method  string
+		path    string
+		want    int
+	}{
+		{name: "support reads balance", headers: map[string]string{HeaderAPIKey: support}, method: http.MethodGet, path: base + "/balance", want: http.StatusOK},
+		{name: "support can't credit", headers: map[string]string{HeaderAPIKey: support}, method: http.MethodPost, path: base + "/add", want: http.StatusForbidden},
+		{name: "support with extra scope credits", headers: map[string]string{HeaderAPIKey: supportWithCredit}, method: http.MethodPost, path: base + "/add", want: http.StatusOK},
+		{name: "payments credits", headers: map[string]string{HeaderAPIKey: payments}, method: http.MethodPost, path: base + "/add", want: http.StatusOK},
+		{name: "payments can't read balance", headers: map[string]string{HeaderAPIKey: payments}, method: http.MethodGet, path: base + "/balance", want: http.StatusForbidden},
+		{name: "payments can't freeze", headers: map[string]string{HeaderAPIKey: payments}, method: http.MethodPost, path: base + "/freeze", want: http.StatusForbidden},
+		{name: "admin freezes", headers: map[string]string{HeaderAPIKey: admin}, method: http.MethodPost, path: base + "/freeze", want: http.StatusOK},
+		{name: "admin credits", headers: map[string]string{HeaderAPIKey: admin}, method: http.MethodPost, path: base + "/add", want: http.StatusOK},
+		{name: "user reads own balance", headers: map[string]string{echo.HeaderAuthorization: "Bearer " + userToken}, method: http.MethodGet, path: base + "/balance", want: http.StatusOK},
+		{name: "user can't credit", headers: map[string]string{echo.HeaderAuthorization: "Bearer " + userToken}, method: http.MethodPost, path: base + "/add", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := te.doRoute(tt.method, tt.path, tt.headers)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestDenialIsAudited(t *testing.T) {
+	te := newTestEnv(t)
+
+	support, key := te.apiKey(t, RoleSupport)
+
+	rec := te.doRoute(http.MethodPost, "/users/"+testUserID+"/add", map[string]string{HeaderAPIKey: support, echo.HeaderXRequestID: "req-1"})
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, `{"success":false,"message":"access to the resource is forbidden","status_code":403,"error_code":"forbidden"}`+"\n", rec.Body.String())
+
+	if assert.Len(t, te.audit.events, 1) {
+		ev := te.audit.events[0]
+		assert.Equal(t, ActionAccessDenied, ev.Action)
+		assert.Equal(t, model.AuditOutcomeDenied, ev.Outcome)
+		assert.Equal(t, KindService, ev.ActorKind)
+		assert.Equal(t, key.KeyID, ev.ActorID)
+		assert.Equal(t, "user:"+testUserID, ev.Resource)
+		assert.Contains(t, ev.Details, "balance:credit")
+	}
+}
+
+func TestAuthenticationDisabled(t *testing.T) {
+	az := NewAuthorizer(nil, nil)
+
+	e := echo.New()
+	g := e.Group("/users/:uid", az.Authenticate(), az.RequireOwnUser("uid"))
+	g.POST("/freeze", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, az.RequireScope(ScopeUsersAdmin))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users/"+testUserID+"/freeze", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,92 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+)
+
+// principal kinds
+const (
+	// KindService is a service-to-service caller authenticated by an api key
+	KindService = "service"
+	// KindUser is an end user authenticated by a jwt bearer token
+	KindUser = "user"
+)
+
+const principalKey = "auth.principal"
+
+type ctxKey struct{}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Kind string
+	// Subject is the api key id for services and the user id for end users
+	Subject string
+	Name    string
+	Role    string
+	Scopes  []string
+}
+
+// IsService reports whether the principal is a service-to-service caller
+func (p *Principal) IsService() bool {
+	return p.Kind == KindService
+}
+
+// HasScope reports whether the principal was granted scope. users:admin grants every scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeUsersAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPrincipal returns the authenticated principal of the request if there is any
+func GetPrincipal(c echo.Context) (*Principal, bool) {
+	p, ok := c.Get(principalKey).(*Principal)
+	return p, ok
+}
+
+// Actor returns the kind and subject of the authenticated principal
+func Actor(c echo.Context) (string, string, bool) {
+	p, ok := GetPrincipal(c)
+	if !ok {
+		return "", "", false
+	}
+	return p.Kind, p.Subject, true
+}
+
+// FromContext returns the authenticated principal stored in ctx if there is any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(*Principal)
+	return p, ok
+}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+func setPrincipal(c echo.Context, p *Principal) {
+	c.Set(principalKey, p)
+	c.SetRequest(c.Request().WithContext(WithPrincipal(c.Request().Context(), p)))
+}
@@ -0,0 +1,113 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// scopes
+const (
+	ScopeBalanceRead   = "balance:read"
+	ScopeHistoryRead   = "history:read"
+	ScopeBalanceCredit = "balance:credit"
+	ScopeAuditRead     = "audit:read"
+	ScopeWebhooksAdmin = "webhooks:admin"
+	ScopeReconcile     = "ledger:reconcile"
+	// ScopeUsersAdmin grants every other scope as well
+	ScopeUsersAdmin = "users:admin"
+)
+
+// roles
+const (
+	// RoleSupport is a read-only support agent
+	RoleSupport = "support"
+	// RolePayments is a payments service which may only credit
+	RolePayments = "payments"
+	// RoleAdmin may do everything including freezing accounts
+	RoleAdmin = "admin"
+	// RoleUser is an end user accessing their own account
+	RoleUser = "user"
+)
+
+var allScopes = map[string]bool{
+	ScopeBalanceRead:   true,
+	ScopeHistoryRead:   true,
+	ScopeBalanceCredit: true,
+	ScopeAuditRead:     true,
+	ScopeWebhooksAdmin: true,
+	ScopeReconcile:     true,
+	ScopeUsersAdmin:    true,
+}
+
+var roleScopes = map[string][]string{
+	RoleSupport:  {ScopeBalanceRead, ScopeHistoryRead},
+	RolePayments: {ScopeBalanceCredit},
+	RoleAdmin:    {ScopeUsersAdmin},
+	RoleUser:     {ScopeBalanceRead, ScopeHistoryRead},
+}
+
+// ValidateRole returns an error if role is unknown
+func ValidateRole(role string) error {
+	if _, ok := roleScopes[role]; !ok {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	return nil
+}
+
+// ValidateScopes returns an error if any of the scopes is unknown
+func ValidateScopes(scopes []string) error {
+	for _, s := range scopes {
+		if !allScopes[s] {
+			return fmt.Errorf("unknown scope %q", s)
+		}
+	}
+	return nil
+}
+
+// ParseScopes splits a space separated scope list
+func ParseScopes(s string) []string {
+	return strings.Fields(s)
+}
+
+// FormatScopes joins the scopes into a space separated list
+func FormatScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// resolveScopes returns the scopes granted by role together with the extra scopes
+func resolveScopes(role string, extra []string) []string {
+	set := map[string]bool{}
+	for _, s := range roleScopes[role] {
+		set[s] = true
+	}
+	for _, s := range extra {
+		if allScopes[s] {
+			set[s] = true
+		}
+	}
+
+	res := make([]string, 0, len(set))
+	for s := range set {
+		res = append(res, s)
+	}
+	sort.Strings(res)
+	return res
+}
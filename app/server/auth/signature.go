@@ -0,0 +1,232 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+	"github.com/diptomondal007/your-money/infrastructure/logger"
+)
+
+// signature headers
+const (
+	HeaderSignatureKeyID     = "X-Signature-Key-Id"
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+	HeaderSignatureNonce     = "X-Signature-Nonce"
+	HeaderSignature          = "X-Signature"
+)
+
+// maxSignedBodySize is the max size of a signed request body
+const maxSignedBodySize = 1 << 20
+
+var (
+	errSignatureMissing = response.WrapError(errors.New("request signature required"), http.StatusUnauthorized, response.CodeSignatureRequired)
+	errSignatureInvalid = response.WrapError(errors.New("invalid request signature"), http.StatusUnauthorized, response.CodeSignatureInvalid)
+	errSignatureExpired = response.WrapError(errors.New("request signature timestamp is out of tolerance"), http.StatusUnauthorized, response.CodeSignatureExpired)
+	errNonceReused      = response.WrapError(errors.New("request nonce was already used"), http.StatusUnauthorized, response.CodeNonceReused)
+)
+
+// NonceStore remembers the used nonces until they expire
+type NonceStore interface {
+	UseNonce(ctx context.Context, keyID, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// SignatureVerifier verifies hmac-sha256 signed requests
+type SignatureVerifier struct {
+	keys      map[string][]string
+	tolerance time.Duration
+	nonces    NonceStore
+	now       func() time.Time
+}
+
+// NewSignatureVerifier returns a new signature verifier
+func NewSignatureVerifier(cfg config.Signing, nonces NonceStore) (*SignatureVerifier, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, fmt.Errorf("request signing is enabled but no signing keys are configured")
+	}
+
+	return &SignatureVerifier{keys: cfg.Keys, tolerance: cfg.Tolerance, nonces: nonces, now: time.Now}, nil
+}
+
+// StringToSign returns the canonical string of a request which is signed by the caller.
+// the fields are separated by new lines: method, path with query, timestamp, nonce and the hex sha256 of the body
+func StringToSign(method, path, timestamp, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{strings.ToUpper(method), path, timestamp, nonce, hex.EncodeToString(sum[:])}, "\n")
+}
+
+// Sign returns the hex hmac-sha256 signature of the canonical string with secret
+func Sign(secret, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Middleware rejects the requests without a valid, fresh and unused signature
+func (v *SignatureVerifier) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := v.verify(c); err != nil {
+				if rejected(err) {
+					logger.FromContext(c.Request().Context()).Warn("request signature rejected", slog.Any("error", err))
+					return response.SendError(c, response.ErrUnauthorized, err)
+				}
+				return response.SendError(c, err)
+			}
+			return next(c)
+		}
+	}
+}
+
+// Signature is the signature of a request as sent by the caller
+type Signature struct {
+	KeyID     string
+	Timestamp string
+	Nonce     string
+	Value     string
+}
+
+func (v *SignatureVerifier) verify(c echo.Context) error {
+	req := c.Request()
+
+	sig := Signature{
+		KeyID:     req.Header.Get(HeaderSignatureKeyID),
+		Timestamp: req.Header.Get(HeaderSignatureTimestamp),
+		Nonce:     req.Header.Get(HeaderSignatureNonce),
+		Value:     req.Header.Get(HeaderSignature),
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxSignedBodySize+1))
+	if err != nil {
+		return errSignatureInvalid
+	}
+	if len(body) > maxSignedBodySize {
+		return errSignatureInvalid
+	}
+	// restore the body for the handler
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return v.Verify(req.Context(), sig, req.Method, req.URL.RequestURI(), body)
+}
+
+// Verify checks the signature of a request made of method, path and body, and consumes its nonce
+func (v *SignatureVerifier) Verify(ctx context.Context, sig Signature, method, path string, body []byte) error {
+	if sig.KeyID == "" || sig.Timestamp == "" || sig.Nonce == "" || sig.Value == "" {
+		return errSignatureMissing
+	}
+
+	secrets, ok := v.keys[sig.KeyID]
+	if !ok {
+		return errSignatureInvalid
+	}
+
+	unix, err := strconv.ParseInt(sig.Timestamp, 10, 64)
+	if err != nil {
+		return errSignatureInvalid
+	}
+
+	signedAt := time.Unix(unix, 0)
+	if d := v.now().Sub(signedAt); d > v.tolerance || d < -v.tolerance {
+		return errSignatureExpired
+	}
+
+	sts := StringToSign(method, path, sig.Timestamp, sig.Nonce, body)
+	if !matchAny(secrets, sts, strings.ToLower(sig.Value)) {
+		return errSignatureInvalid
+	}
+
+	// the nonce is only consumed by valid signatures, so that it can't be burnt by a forged request
+	fresh, err := v.nonces.UseNonce(ctx, sig.KeyID, sig.Nonce, signedAt.Add(v.tolerance))
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return errNonceReused
+	}
+
+	return nil
+}
+
+// rejected reports whether err is a rejected signature rather than a failure of the nonce store
+func rejected(err error) bool {
+	return errors.Is(err, errSignatureMissing) || errors.Is(err, errSignatureInvalid) ||
+		errors.Is(err, errSignatureExpired) || errors.Is(err, errNonceReused)
+}
+
+// matchAny reports whether any of the active secrets produced the signature
+func matchAny(secrets []string, stringToSign string, signature string) bool {
+	for _, s := range secrets {
+		if hmac.Equal([]byte(Sign(s, stringToSign)), []byte(signature)) {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryNonceStore keeps the used nonces in memory. it's only suitable for a single instance
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryNonceStore returns a new in-memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: map[string]time.Time{}, now: time.Now}
+}
+
+// UseNonce records the nonce of a key. it returns false if the nonce was already used
+func (m *MemoryNonceStore) UseNonce(ctx context.Context, keyID, nonce string, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) > time.Minute {
+		for k, exp := range m.nonces {
+			if exp.Before(now) {
+				delete(m.nonces, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	k := keyID + "\n" + nonce
+	if exp, ok := m.nonces[k]; ok && !exp.Before(now) {
+		return false, nil
+	}
+	m.nonces[k] = expiresAt
+	return true, nil
+}
@@ -0,0 +1,144 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+const signedPath = "/users/6d7750a1-c3f2-4765-bf8f-33bc80f3f809/add"
+
+func newSignedServer(t *testing.T, now time.Time) *echo.Echo {
+	v, err := NewSignatureVerifier(config.Signing{
+		Enabled:   true,
+		Keys:      map[string][]string{"payments": {"old-secret", "new-secret"}},
+		Tolerance: 5 * time.Minute,
+	}, NewMemoryNonceStore())
+	assert.NoError(t, err)
+	v.now = func() time.Time { return now }
+
+	e := echo.New()
+	e.POST("/users/:uid/add", func(c echo.Context) error {
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.JSONBlob(http.StatusAccepted, body)
+	}, v.Middleware())
+	return e
+}
+
+type signedReq struct {
+	keyID, secret, nonce, body string
+	ts                         time.Time
+	tamperBody                 string
+}
+
+func (s signedReq) do(e *echo.Echo) *httptest.ResponseRecorder {
+	ts := strconv.FormatInt(s.ts.Unix(), 10)
+	sig := Sign(s.secret, StringToSign(http.MethodPost, signedPath, ts, s.nonce, []byte(s.body)))
+
+	body := s.body
+	if s.tamperBody != "" {
+		body = s.tamperBody
+	}
+
+	req := httptest.NewRequest(http.MethodPost, signedPath, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(HeaderSignatureKeyID, s.keyID)
+	req.Header.Set(HeaderSignatureTimestamp, ts)
+	req.Header.Set(HeaderSignatureNonce, s.nonce)
+	req.Header.Set(HeaderSignature, sig)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSignatureVerification(t *testing.T) {
+	now := time.Now()
+	body := `{"amount":10,"transaction_id":"tx_1as4ndakda"}`
+
+	tests := []struct {
+		name    string
+		req     signedReq
+		want    int
+		message string
+	}{
+		{name: "valid with new secret", req: signedReq{keyID: "payments", secret: "new-secret", nonce: "n1", body: body, ts: now}, want: http.StatusAccepted},
+		{name: "valid with rotated out secret", req: signedReq{keyID: "payments", secret: "old-secret", nonce: "n2", body: body, ts: now}, want: http.StatusAccepted},
+		{name: "unknown key id", req: signedReq{keyID: "other", secret: "new-secret", nonce: "n3", body: body, ts: now}, want: http.StatusUnauthorized, message: "invalid request signature"},
+		{name: "wrong secret", req: signedReq{keyID: "payments", secret: "guess", nonce: "n4", body: body, ts: now}, want: http.StatusUnauthorized, message: "invalid request signature"},
+		{name: "tampered body", req: signedReq{keyID: "payments", secret: "new-secret", nonce: "n5", body: body, ts: now, tamperBody: `{"amount":1000,"transaction_id":"tx_1as4ndakda"}`}, want: http.StatusUnauthorized, message: "invalid request signature"},
+		{name: "stale timestamp", req: signedReq{keyID: "payments", secret: "new-secret", nonce: "n6", body: body, ts: now.Add(-10 * time.Minute)}, want: http.StatusUnauthorized, message: "request signature timestamp is out of tolerance"},
+		{name: "future timestamp", req: signedReq{keyID: "payments", secret: "new-secret", nonce: "n7", body: body, ts: now.Add(10 * time.Minute)}, want: http.StatusUnauthorized, message: "request signature timestamp is out of tolerance"},
+	}
+
+	e := newSignedServer(t, now)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tt.req.do(e)
+			assert.Equal(t, tt.want, rec.Code)
+			if tt.message != "" {
+				assert.Contains(t, rec.Body.String(), tt.message)
+			} else {
+				assert.JSONEq(t, body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSignatureNonceReuse(t *testing.T) {
+	now := time.Now()
+	e := newSignedServer(t, now)
+
+	req := signedReq{keyID: "payments", secret: "new-secret", nonce: "same", body: `{"amount":10}`, ts: now}
+
+	assert.Equal(t, http.StatusAccepted, req.do(e).Code)
+
+	rec := req.do(e)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "request nonce was already used")
+}
+
+func TestSignatureMissing(t *testing.T) {
+	e := newSignedServer(t, time.Now())
+
+	req := httptest.NewRequest(http.MethodPost, signedPath, strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "request signature required")
+}
+
+func TestNewSignatureVerifierWithoutKeys(t *testing.T) {
+	_, err := NewSignatureVerifier(config.Signing{Enabled: true}, NewMemoryNonceStore())
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fee
+
+import (
+	"fmt"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+// Policy picks the fee schedule of a user and the house account the fees are credited to. a nil policy charges
+// no fees
+type Policy struct {
+	Schedules map[string]*Schedule
+	// Default is the schedule of the users without a schedule of their own, none if empty
+	Default      string
+	HouseAccount string
+}
+
+// NewPolicy returns the policy of cfg, nil if no schedule is configured
+func NewPolicy(cfg config.Fees) (*Policy, error) {
+	schedules, err := ParseSchedules(cfg.Schedules)
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, nil
+	}
+
+	if _, ok := schedules[cfg.DefaultSchedule]; cfg.DefaultSchedule != "" && !ok {
+		return nil, fmt.Errorf("default fee schedule %s is not defined", cfg.DefaultSchedule)
+	}
+	if cfg.HouseAccount == "" {
+		return nil, fmt.Errorf("house account required to charge fees")
+	}
+	return &Policy{Schedules: schedules, Default: cfg.DefaultSchedule, HouseAccount: cfg.HouseAccount}, nil
+}
+
+// Has reports whether the schedule is defined
+func (p *Policy) Has(schedule string) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.Schedules[schedule]
+	return ok
+}
+
+// Charge returns the fee of crediting amount to user, nil if no fee is charged. a user assigned a schedule which
+// is no longer defined pays the default fees
+func (p *Policy) Charge(user *model.User, amount float64) *model.Fee {
+	if p == nil || user.ID == p.HouseAccount {
+		return nil
+	}
+
+	sc, ok := p.Schedules[user.FeeSchedule]
+	if !ok {
+		if sc, ok = p.Schedules[p.Default]; !ok {
+			return nil
+		}
+	}
+
+	f := sc.Fee(amount)
+	if f <= 0 {
+		return nil
+	}
+	return &model.Fee{Amount: f, Schedule: sc.Name, HouseAccount: p.HouseAccount}
+}
@@ -0,0 +1,197 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package fee computes the fees charged on the credits by the fee schedules of the users
+package fee
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxNameLength is the length of the fee schedule column of the users
+const maxNameLength = 64
+
+// Tier is the fee of the credits of at least From. the fee is Flat plus Percent of the credited amount
+type Tier struct {
+	From    float64
+	Flat    float64
+	Percent float64
+}
+
+// Schedule is a named fee schedule. the fee of a credit is the one of the highest tier the amount reaches, kept
+// within Min and Max
+type Schedule struct {
+	Name string
+	// Tiers are ordered by From, the first one starts at 0
+	Tiers []Tier
+	Min   float64
+	// Max caps the fee, 0 if the fee isn't capped
+	Max float64
+}
+
+// Fee returns the fee of crediting amount, rounded to cents. the fee never exceeds the amount
+func (s *Schedule) Fee(amount float64) float64 {
+	t := s.Tiers[0]
+	for _, tier := range s.Tiers[1:] {
+		if amount >= tier.From {
+			t = tier
+		}
+	}
+
+	f := max(t.Flat+amount*t.Percent/100, s.Min)
+	if s.Max > 0 {
+		f = min(f, s.Max)
+	}
+	return min(math.Round(f*100)/100, amount)
+}
+
+// ParseSchedules parses a semicolon separated list of schedules in the form name=term,term,.. where a term is
+//   - a fee, either a flat amount (0.3), a percentage (1.5%) or both (1.5%+0.3)
+//   - a tier, >=from:fee, ex - >=1000:1% charges 1% on the credits of 1000 and more
+//   - min:amount or max:amount, the bounds of the fee
+//
+// ex - standard=2.9%+0.3,min:0.5;tiered=2%,>=1000:1.5%,max:25. a schedule without a plain fee charges nothing
+// below its first tier
+func ParseSchedules(s string) (map[string]*Schedule, error) {
+	res := map[string]*Schedule{}
+	for _, part := range strings.Split(s, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		name, terms, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || len(name) > maxNameLength || strings.ContainsAny(name, " ,:") {
+			return nil, fmt.Errorf("invalid fee schedule %q, expected name=term,term", part)
+		}
+		if _, ok := res[name]; ok {
+			return nil, fmt.Errorf("fee schedule %s is defined twice", name)
+		}
+
+		sc, err := parseSchedule(name, terms)
+		if err != nil {
+			return nil, fmt.Errorf("fee schedule %s: %w", name, err)
+		}
+		res[name] = sc
+	}
+	return res, nil
+}
+
+func parseSchedule(name, terms string) (*Schedule, error) {
+	sc := &Schedule{Name: name}
+	tiers := map[float64]Tier{}
+
+	for _, term := range strings.Split(terms, ",") {
+		term = strings.TrimSpace(term)
+
+		if key, v, _ := strings.Cut(term, ":"); key == "min" || key == "max" {
+			n, err := parseAmount(v)
+			if err != nil {
+				return nil, err
+			}
+			if key == "min" {
+				sc.Min = n
+			} else {
+				sc.Max = n
+			}
+			continue
+		}
+
+		var (
+			from float64
+			fee  = term
+		)
+		if v, ok := strings.CutPrefix(term, ">="); ok {
+			f, rest, ok := strings.Cut(v, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid tier %q, expected >=from:fee", term)
+			}
+			n, err := parseAmount(f)
+			if err != nil {
+				return nil, err
+			}
+			from, fee = n, rest
+		}
+
+		t, err := parseTier(fee)
+		if err != nil {
+			return nil, err
+		}
+		t.From = from
+
+		if _, ok := tiers[from]; ok {
+			return nil, fmt.Errorf("tier from %v is defined twice", from)
+		}
+		tiers[from] = t
+	}
+
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("no fee defined")
+	}
+	if sc.Max > 0 && sc.Max < sc.Min {
+		return nil, fmt.Errorf("max %v is less than min %v", sc.Max, sc.Min)
+	}
+
+	if _, ok := tiers[0]; !ok {
+		tiers[0] = Tier{}
+	}
+	for _, t := range tiers {
+		sc.Tiers = append(sc.Tiers, t)
+	}
+	sort.Slice(sc.Tiers, func(i, j int) bool { return sc.Tiers[i].From < sc.Tiers[j].From })
+	return sc, nil
+}
+
+// parseTier parses a fee of the form flat, percent% or percent%+flat
+func parseTier(s string) (Tier, error) {
+	var t Tier
+
+	percent, flat, ok := strings.Cut(s, "%")
+	if !ok {
+		n, err := parseAmount(s)
+		t.Flat = n
+		return t, err
+	}
+
+	n, err := parseAmount(percent)
+	if err != nil {
+		return t, err
+	}
+	t.Percent = n
+
+	if flat != "" {
+		if !strings.HasPrefix(flat, "+") {
+			return t, fmt.Errorf("invalid fee %q, expected percent%%+flat", s)
+		}
+		if t.Flat, err = parseAmount(flat[1:]); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+func parseAmount(s string) (float64, error) {
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("invalid amount %q, expected a non negative number", s)
+	}
+	return n, nil
+}
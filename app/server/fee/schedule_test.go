@@ -0,0 +1,108 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fee
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+func TestParseSchedules(t *testing.T) {
+	ss, err := ParseSchedules("standard=2.9%+0.3,min:0.5; flat=0.25;tiered=>=1000:1.5%,2%,>=5000:1%,max:25")
+	require.NoError(t, err)
+	require.Len(t, ss, 3)
+
+	assert.Equal(t, &Schedule{Name: "standard", Tiers: []Tier{{Percent: 2.9, Flat: 0.3}}, Min: 0.5}, ss["standard"])
+	assert.Equal(t, &Schedule{Name: "flat", Tiers: []Tier{{Flat: 0.25}}}, ss["flat"])
+	assert.Equal(t, []Tier{{Percent: 2}, {From: 1000, Percent: 1.5}, {From: 5000, Percent: 1}}, ss["tiered"].Tiers)
+	assert.Equal(t, float64(25), ss["tiered"].Max)
+
+	// a schedule of tiers only charges nothing below the first one
+	ss, err = ParseSchedules("large=>=100:1")
+	require.NoError(t, err)
+	assert.Equal(t, []Tier{{}, {From: 100, Flat: 1}}, ss["large"].Tiers)
+
+	ss, err = ParseSchedules(" ")
+	require.NoError(t, err)
+	assert.Empty(t, ss)
+
+	for _, s := range []string{
+		"standard", "=1%", "a b=1%", "standard=", "standard=min:1", "standard=1%;standard=2%",
+		"standard=-1", "standard=1%+", "standard=1%0.3", "standard=x%", "standard=>=100", "standard=1%,2%",
+		"standard=1%,min:5,max:2", "standard=1%,max:x",
+	} {
+		_, err := ParseSchedules(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestScheduleFee(t *testing.T) {
+	ss, err := ParseSchedules("standard=2.9%+0.3,min:0.5;tiered=2%,>=1000:1.5%,>=5000:1%,max:60")
+	require.NoError(t, err)
+
+	tests := []struct {
+		schedule string
+		amount   float64
+		want     float64
+	}{
+		{schedule: "standard", amount: 100, want: 3.2},
+		{schedule: "standard", amount: 5, want: 0.5},
+		{schedule: "standard", amount: 0.2, want: 0.2},
+		{schedule: "standard", amount: 33.33, want: 1.27},
+		{schedule: "tiered", amount: 999, want: 19.98},
+		{schedule: "tiered", amount: 1000, want: 15},
+		{schedule: "tiered", amount: 5000, want: 50},
+		{schedule: "tiered", amount: 10000, want: 60},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ss[tt.schedule].Fee(tt.amount), "%s %v", tt.schedule, tt.amount)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p, err := NewPolicy(config.Fees{Schedules: "standard=1%;premium=0.5", DefaultSchedule: "standard", HouseAccount: "house"})
+	require.NoError(t, err)
+
+	assert.Equal(t, &model.Fee{Amount: 1, Schedule: "standard", HouseAccount: "house"}, p.Charge(&model.User{ID: "u1"}, 100))
+	assert.Equal(t, &model.Fee{Amount: 0.5, Schedule: "premium", HouseAccount: "house"}, p.Charge(&model.User{ID: "u1", FeeSchedule: "premium"}, 100))
+	assert.Equal(t, float64(1), p.Charge(&model.User{ID: "u1", FeeSchedule: "removed"}, 100).Amount)
+	assert.Nil(t, p.Charge(&model.User{ID: "house"}, 100))
+	assert.Nil(t, p.Charge(&model.User{ID: "u1"}, 0.001))
+	assert.True(t, p.Has("premium"))
+	assert.False(t, p.Has("removed"))
+
+	p, err = NewPolicy(config.Fees{Schedules: "standard=1%", HouseAccount: "house"})
+	require.NoError(t, err)
+	assert.Nil(t, p.Charge(&model.User{ID: "u1"}, 100))
+
+	p, err = NewPolicy(config.Fees{HouseAccount: "house"})
+	require.NoError(t, err)
+	assert.Nil(t, p)
+	assert.Nil(t, p.Charge(&model.User{ID: "u1"}, 100))
+	assert.False(t, p.Has("standard"))
+
+	_, err = NewPolicy(config.Fees{Schedules: "standard=1%", DefaultSchedule: "premium", HouseAccount: "house"})
+	assert.Error(t, err)
+	_, err = NewPolicy(config.Fees{Schedules: "standard=1%"})
+	assert.Error(t, err)
+}
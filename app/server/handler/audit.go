@@ -0,0 +1,104 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/ratelimit"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+type AuditHandler struct {
+	uc usecase.AuditUseCase
+}
+
+// NewAuditHandler registers the audit routes. reading the audit log requires the audit:read scope
+func NewAuditHandler(r *Router, uc usecase.AuditUseCase, az *auth.Authorizer, rl *ratelimit.Limiter) AuditHandler {
+	h := AuditHandler{uc: uc}
+
+	for _, ag := range r.V1("/audit") {
+		ag.GET("", h.list, az.Authenticate(), az.RequireScope(auth.ScopeAuditRead), rl.Limit("audit", ""))
+	}
+
+	return h
+}
+
+// ParseAuditFilter reads the audit filter from the query params. from and to are RFC 3339 timestamps
+func ParseAuditFilter(params func(name string) string) (model.AuditFilter, error) {
+	f := model.AuditFilter{
+		ActorKind: params("actor_kind"),
+		ActorID:   params("actor_id"),
+		Action:    params("action"),
+		Resource:  params("resource"),
+		Outcome:   params("outcome"),
+		RequestID: params("request_id"),
+	}
+
+	var err error
+	if v := params("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("from should be a RFC 3339 timestamp")
+		}
+	}
+	if v := params("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("to should be a RFC 3339 timestamp")
+		}
+	}
+	return f, nil
+}
+
+func (h *AuditHandler) list(c echo.Context) error {
+	filter, err := ParseAuditFilter(c.QueryParam)
+	if err != nil {
+		return response.SendError(c, response.ErrBadRequest, err)
+	}
+
+	pageSize := defaultAuditPageSize
+	if v := c.QueryParam("page_size"); v != "" {
+		pageSize, err = strconv.Atoi(v)
+		if err != nil {
+			return response.SendError(c, response.ErrBadRequest, fmt.Errorf("page size should be a valid integer"))
+		}
+	}
+
+	if pageSize < 1 || pageSize > maxAuditPageSize {
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("page size should be between 1 and %d", maxAuditPageSize))
+	}
+
+	ds, err := h.uc.List(c.Request().Context(), filter, int64(pageSize), c.QueryParam("page"))
+	if err != nil {
+		return response.SendError(c, err)
+	}
+
+	return c.JSON(response.RespondSuccess(http.StatusOK, "request successful!", ds))
+}
@@ -0,0 +1,86 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/app/utils"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+func newAuditTest() (*echo.Echo, sqlmock.Sqlmock) {
+	db, mock := utils.MockSqlxDB()
+
+	e := echo.New()
+	NewAuditHandler(newTestRouter(e), usecase.NewAuditUseCase(repository.NewAuditRepo(db)), auth.NewAuthorizer(nil, nil), nil)
+	return e, mock
+}
+
+func TestListAuditEvents(t *testing.T) {
+	e, mock := newAuditTest()
+
+	query := `SELECT "a".* FROM "audit_events" AS "a" WHERE (("action" = 'balance.credit') AND ("resource" = 'user:u1')) ORDER BY "a"."id" DESC LIMIT 50`
+	rows := sqlmock.NewRows([]string{"id", "actor_kind", "actor_id", "action", "resource", "outcome", "details", "before_balance", "after_balance", "payload"}).
+		AddRow(7, "service", "payments", "balance.credit", "user:u1", "success", `{"amount":10}`, 100, 110, `{"amount":10}`)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/audit?action=balance.credit&resource=user:u1", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"actor_id":"payments"`)
+	assert.Contains(t, rec.Body.String(), `"before_balance":100,"after_balance":110,"details":{"amount":10}`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListAuditEventsBadRequest(t *testing.T) {
+	e, _ := newAuditTest()
+
+	for _, q := range []string{"from=yesterday", "to=2026-01-01", "page_size=0", "page_size=501", "page_size=ten"} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/audit?"+q, nil))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, q)
+	}
+}
+
+func TestListAuditEventsRequiresAuth(t *testing.T) {
+	db, _ := utils.MockSqlxDB()
+
+	authn, err := auth.NewAuthenticator(config.Auth{Enabled: true}, repository.NewAPIKeyRepo(db))
+	assert.NoError(t, err)
+
+	e := echo.New()
+	NewAuditHandler(newTestRouter(e), usecase.NewAuditUseCase(repository.NewAuditRepo(db)), auth.NewAuthorizer(authn, nil), nil)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/audit", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,60 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handler
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// openAPISpec is the contract of the rest api. every registered route must be documented in it
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsPage renders the spec with redoc
+const docsPage = `<!DOCTYPE html>
+<html>
+  <head>
+    <title>your-money api</title>
+    <meta charset="utf-8"/>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+  </head>
+  <body>
+    <redoc spec-url="/openapi.json"></redoc>
+    <script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+  </body>
+</html>
+`
+
+// OpenAPISpec returns the OpenAPI 3 document of the rest api
+func OpenAPISpec() []byte {
+	return openAPISpec
+}
+
+// NewDocsHandler registers the public routes serving the api documentation
+func NewDocsHandler(e *echo.Echo) {
+	e.GET("/openapi.json", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, openAPISpec)
+	})
+	e.GET("/docs", func(c echo.Context) error {
+		return c.HTML(http.StatusOK, docsPage)
+	})
+}
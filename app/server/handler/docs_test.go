@@ -0,0 +1,152 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+// serverRoutes are documented routes registered by the server itself instead of a handler
+var serverRoutes = map[string]bool{
+	"GET /healthz": true,
+	"GET /readyz":  true,
+	"GET /metrics": true,
+}
+
+type openAPIDoc struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// registeredRoutes registers every handler and returns its routes as "METHOD /path/{param}"
+func registeredRoutes() map[string]bool {
+	e := echo.New()
+	az := auth.NewAuthorizer(nil, nil)
+
+	r := newTestRouter(e)
+	NewHandler(r, nil, az, nil)
+	NewAuditHandler(r, nil, az, nil)
+	NewWebhookHandler(r, nil, az, nil)
+	NewEventHandler(r, nil, az, nil, 0)
+	NewReconcileHandler(r, nil, config.Reconcile{}, az, nil)
+	NewDocsHandler(e)
+
+	routes := map[string]bool{}
+	for _, r := range e.Routes() {
+		// groups with middleware register catch-all routes answering 404
+		if strings.HasPrefix(r.Name, "github.com/labstack/echo/v4.") {
+			continue
+		}
+		routes[r.Method+" "+pathParam.ReplaceAllString(r.Path, "{$1}")] = true
+	}
+	return routes
+}
+
+func documentedRoutes(t *testing.T) map[string]bool {
+	var doc openAPIDoc
+	require.NoError(t, json.Unmarshal(OpenAPISpec(), &doc))
+	require.True(t, strings.HasPrefix(doc.OpenAPI, "3."))
+
+	routes := map[string]bool{}
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			if method == "parameters" {
+				continue
+			}
+			routes[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	return routes
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	registered := registeredRoutes()
+	documented := documentedRoutes(t)
+
+	var missing, stale []string
+	for r := range registered {
+		if !documented[r] {
+			missing = append(missing, r)
+		}
+	}
+	for r := range documented {
+		if !registered[r] && !serverRoutes[r] {
+			stale = append(stale, r)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+
+	assert.Empty(t, missing, "routes missing from openapi.json")
+	assert.Empty(t, stale, "routes documented in openapi.json but not registered")
+}
+
+func TestOpenAPIReferencesResolve(t *testing.T) {
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(OpenAPISpec(), &doc))
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				var target interface{} = doc
+				for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := target.(map[string]interface{})
+					target = m[part]
+				}
+				assert.NotNil(t, target, "unresolved reference %s", ref)
+			}
+			for _, e := range v {
+				walk(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	e := echo.New()
+	NewDocsHandler(e)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, echo.MIMEApplicationJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.JSONEq(t, string(OpenAPISpec()), rec.Body.String())
+}
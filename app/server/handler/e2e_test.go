@@ -19,6 +19,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -60,10 +61,10 @@ func TestE2ETestSuite(t *testing.T) {
 }
 
 func (s *e2eTestSuite) TestE2EGetBalance() {
-	user, err := s.repo.GetUserInfo("6d7750a1-c3f2-4765-bf8f-33bc80f3f809")
+	user, err := s.repo.GetUserInfo(context.Background(), "6d7750a1-c3f2-4765-bf8f-33bc80f3f809")
 	s.NoError(err)
 
-	response, err := s.req(echo.GET, fmt.Sprintf("http://localhost:%d/users/6d7750a1-c3f2-4765-bf8f-33bc80f3f809/balance", 8080), nil)
+	response, err := s.req(echo.GET, fmt.Sprintf("http://localhost:%d/v1/users/6d7750a1-c3f2-4765-bf8f-33bc80f3f809/balance", 8080), nil)
 	s.NoError(err)
 	s.Equal(http.StatusOK, response.StatusCode)
 
@@ -76,7 +77,7 @@ func (s *e2eTestSuite) TestE2EGetBalance() {
 }
 
 func (s *e2eTestSuite) TestE2EAddBalance() {
-	user, err := s.repo.GetUserInfo("6d7750a1-c3f2-4765-bf8f-33bc80f3f809")
+	user, err := s.repo.GetUserInfo(context.Background(), "6d7750a1-c3f2-4765-bf8f-33bc80f3f809")
 	s.NoError(err)
 
 	amount := 10
@@ -85,7 +86,7 @@ func (s *e2eTestSuite) TestE2EAddBalance() {
 		Amount:        float64(amount),
 	}
 
-	response, err := s.req(echo.POST, fmt.Sprintf("http://localhost:%d/users/6d7750a1-c3f2-4765-bf8f-33bc80f3f809/add", 8080), body)
+	response, err := s.req(echo.POST, fmt.Sprintf("http://localhost:%d/v1/users/6d7750a1-c3f2-4765-bf8f-33bc80f3f809/add", 8080), body)
 
 	s.NoError(err)
 	s.Equal(http.StatusAccepted, response.StatusCode)
@@ -93,13 +94,13 @@ func (s *e2eTestSuite) TestE2EAddBalance() {
 	byteBody, err := io.ReadAll(response.Body)
 	s.NoError(err)
 
-	s.Equal(fmt.Sprintf(`{"success":true,"message":"transaction successful!","status_code":202,"data":{"current_balance":%d}}`, int(user.Balance+float64(amount))), strings.Trim(string(byteBody), "\n"))
+	s.Equal(fmt.Sprintf(`{"success":true,"message":"transaction successful!","status_code":202,"data":{"current_balance":%d,"fee":0}}`, int(user.Balance+float64(amount))), strings.Trim(string(byteBody), "\n"))
 
 	response.Body.Close()
 }
 
 func (s *e2eTestSuite) TestE2EAddBalanceConcurrent() {
-	user, err := s.repo.GetUserInfo("6d7750a1-c3f2-4765-bf8f-33bc80f3f809")
+	user, err := s.repo.GetUserInfo(context.Background(), "6d7750a1-c3f2-4765-bf8f-33bc80f3f809")
 	s.NoError(err)
 
 	ids := []string{
@@ -121,7 +122,7 @@ func (s *e2eTestSuite) TestE2EAddBalanceConcurrent() {
 				Amount:        float64(amount),
 			}
 
-			_, _ = s.req(echo.POST, fmt.Sprintf("http://localhost:%d/users/6d7750a1-c3f2-4765-bf8f-33bc80f3f809/add", 8080), body)
+			_, _ = s.req(echo.POST, fmt.Sprintf("http://localhost:%d/v1/users/6d7750a1-c3f2-4765-bf8f-33bc80f3f809/add", 8080), body)
 
 			wg.Done()
 		}(wg, tx)
@@ -130,12 +131,12 @@ func (s *e2eTestSuite) TestE2EAddBalanceConcurrent() {
 
 	wg.Wait()
 
-	u, err := s.repo.GetUserInfo("6d7750a1-c3f2-4765-bf8f-33bc80f3f809")
+	u, err := s.repo.GetUserInfo(context.Background(), "6d7750a1-c3f2-4765-bf8f-33bc80f3f809")
 	s.Equal(user.Balance+(5*10), u.Balance)
 }
 
 func (s *e2eTestSuite) TestE2ETransactionList() {
-	response, err := s.req(echo.GET, fmt.Sprintf("http://localhost:%d/users/6d7750a1-c3f2-4765-bf8f-33bc80f3f809/history?page_size=10", 8080), nil)
+	response, err := s.req(echo.GET, fmt.Sprintf("http://localhost:%d/v1/users/6d7750a1-c3f2-4765-bf8f-33bc80f3f809/history?page_size=10", 8080), nil)
 
 	s.NoError(err)
 	s.Equal(http.StatusOK, response.StatusCode)
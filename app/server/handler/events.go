@@ -0,0 +1,142 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/ratelimit"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/logger"
+)
+
+const (
+	// HeaderLastEventID is sent by reconnecting clients with the id of the last received event
+	HeaderLastEventID = "Last-Event-ID"
+	// EventTransaction is the name of the events carrying a posted transaction
+	EventTransaction = "transaction"
+	// streamRetry is the reconnection delay suggested to the clients
+	streamRetry = 3 * time.Second
+)
+
+type EventHandler struct {
+	uc        usecase.EventUseCase
+	heartbeat time.Duration
+}
+
+// NewEventHandler registers the server-sent event stream of the balance changes of a user
+func NewEventHandler(r *Router, uc usecase.EventUseCase, az *auth.Authorizer, rl *ratelimit.Limiter, heartbeat time.Duration) EventHandler {
+	h := EventHandler{uc: uc, heartbeat: heartbeat}
+
+	for _, ug := range r.V1("/users/:uid") {
+		ug.GET("/events", h.events, az.Authenticate(), az.RequireOwnUser("uid"),
+			az.RequireScope(auth.ScopeBalanceRead), az.RequireScope(auth.ScopeHistoryRead), rl.Limit("events", "uid"))
+	}
+
+	return h
+}
+
+// events streams every transaction posted to a user and the resulting balance until the client disconnects
+func (h *EventHandler) events(c echo.Context) error {
+	userID := c.Param("uid")
+	if userID == "" {
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("not a valid user id"))
+	}
+
+	var lastEventID *uint
+	if v := c.Request().Header.Get(HeaderLastEventID); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return response.SendError(c, response.ErrBadRequest, fmt.Errorf("last event id should be a valid integer"))
+		}
+		last := uint(id)
+		lastEventID = &last
+	}
+
+	ctx := c.Request().Context()
+	s, err := h.uc.Open(ctx, userID, lastEventID)
+	if err != nil {
+		return response.SendError(c, err)
+	}
+	defer s.Close()
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set(echo.HeaderConnection, "keep-alive")
+	// disables response buffering of nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+		return nil
+	}
+	w.Flush()
+
+	t := time.NewTicker(h.heartbeat)
+	defer t.Stop()
+
+	l := logger.FromContext(ctx).With(slog.String("user_id", userID))
+	for {
+		// the stream is caught up on every wake up and heartbeat, so a missed notification only delays an event
+		evs, err := s.Next(ctx)
+		if err != nil {
+			// the headers are sent already, the client reconnects with the last event id
+			l.Error("failed to read balance events", slog.Any("error", err))
+			return nil
+		}
+
+		for _, ev := range evs {
+			if err := writeEvent(w, ev); err != nil {
+				return nil
+			}
+		}
+		w.Flush()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.Done():
+			return nil
+		case <-s.Wake():
+		case <-t.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// writeEvent writes a balance event in the event stream format
+func writeEvent(w *echo.Response, ev *usecase.BalanceEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, EventTransaction, data)
+	return err
+}
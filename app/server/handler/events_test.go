@@ -0,0 +1,133 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/stream"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+)
+
+// newEventTest returns an event handler whose streams end right after catching up
+func newEventTest(e *echo.Echo) (EventHandler, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		panic(err)
+	}
+
+	broker := stream.NewBroker()
+	broker.Close()
+
+	ur := repository.NewUserRepo(sqlx.NewDb(db, "postgres"))
+	return NewEventHandler(newTestRouter(e), usecase.NewEventUseCase(ur, broker), auth.NewAuthorizer(nil, nil), nil, time.Minute), mock
+}
+
+func newEventContext(e *echo.Echo, lastEventID string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if lastEventID != "" {
+		req.Header.Set(HeaderLastEventID, lastEventID)
+	}
+	rec := httptest.NewRecorder()
+
+	c := e.NewContext(req, rec)
+	c.SetPath("/users/:uid/events")
+	c.SetParamNames("uid")
+	c.SetParamValues("6d7750a1-c3f2-4765-bf8f-33bc80f3f809")
+	return c, rec
+}
+
+func TestEventsResume(t *testing.T) {
+	s := echo.New()
+	h, mock := newEventTest(s)
+
+	query := `SELECT "u"."opening_balance" + (SELECT COALESCE(SUM("t"."amount"), 0) FROM "transactions" AS "t" WHERE (("t"."id" <= 3) AND ("t"."user_id" = "u"."id"))) FROM "users" AS "u" WHERE ("u"."id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100))
+
+	createdAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	query = `SELECT "t".* FROM "transactions" AS "t" WHERE (("id" > 3) AND ("user_id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')) ORDER BY "t"."id" ASC LIMIT 100`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "amount", "transaction_id", "user_id"}).
+		AddRow(4, createdAt, 10, "tx_4", "6d7750a1-c3f2-4765-bf8f-33bc80f3f809").
+		AddRow(5, createdAt, 5.5, "tx_5", "6d7750a1-c3f2-4765-bf8f-33bc80f3f809"))
+
+	c, rec := newEventContext(s, "3")
+
+	if assert.NoError(t, h.events(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "retry: 3000\n\n"+
+			"id: 4\nevent: transaction\ndata: {\"id\":4,\"transaction_id\":\"tx_4\",\"amount\":10,\"balance\":110,\"created_at\":\"2026-01-01T00:00:00Z\"}\n\n"+
+			"id: 5\nevent: transaction\ndata: {\"id\":5,\"transaction_id\":\"tx_5\",\"amount\":5.5,\"balance\":115.5,\"created_at\":\"2026-01-01T00:00:00Z\"}\n\n",
+			rec.Body.String())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventsStartAtLastTransaction(t *testing.T) {
+	s := echo.New()
+	h, mock := newEventTest(s)
+
+	query := `SELECT COALESCE(MAX("t"."id"), 0) FROM "transactions" AS "t" WHERE ("user_id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(7))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`("t"."id" <= 7)`)).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100))
+	mock.ExpectQuery(regexp.QuoteMeta(`("id" > 7)`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	c, rec := newEventContext(s, "")
+
+	if assert.NoError(t, h.events(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "retry: 3000\n\n", rec.Body.String())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventsBadLastEventID(t *testing.T) {
+	s := echo.New()
+	h, _ := newEventTest(s)
+
+	c, rec := newEventContext(s, "abc")
+
+	if assert.NoError(t, h.events(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestEventsUserNotFound(t *testing.T) {
+	s := echo.New()
+	h, mock := newEventTest(s)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`("t"."id" <= 3)`)).WillReturnRows(sqlmock.NewRows([]string{"balance"}))
+
+	c, rec := newEventContext(s, "3")
+
+	if assert.NoError(t, h.events(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
@@ -20,6 +20,9 @@ package handler
 import (
 	"github.com/labstack/echo/v4"
 
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/ratelimit"
 	"github.com/diptomondal007/your-money/app/server/usecase"
 )
 
@@ -28,15 +31,26 @@ type Handler struct {
 	uc usecase.UserUseCase
 }
 
-func NewHandler(e *echo.Echo, uc usecase.UserUseCase) Handler {
-	h := Handler{e: e, uc: uc}
+// NewHandler registers the routes. every route requires its own scope and is rate limited by its name.
+// the versions share the use case, each binds and renders its own dtos
+func NewHandler(r *Router, uc usecase.UserUseCase, az *auth.Authorizer, rl *ratelimit.Limiter) Handler {
+	h := Handler{e: r.e, uc: uc}
 
-	// user group
-	ug := e.Group("/users/:uid")
+	for _, g := range r.V1("/users") {
+		g.POST("", h.createUser, az.Authenticate(), audit.Capture(auth.Actor), az.RequireScope(auth.ScopeUsersAdmin), rl.Limit("create_user", ""))
+	}
 
-	ug.POST("/add", h.addBalance)
-	ug.GET("/balance", h.checkBalance)
-	ug.GET("/history", h.history)
+	// user group
+	for _, ug := range r.V1("/users/:uid", az.Authenticate(), audit.Capture(auth.Actor), az.RequireOwnUser("uid")) {
+		ug.POST("/add", h.addBalance, az.RequireScope(auth.ScopeBalanceCredit), rl.Limit("add", "uid"), az.RequireSignature())
+		ug.GET("/balance", h.checkBalance, az.RequireScope(auth.ScopeBalanceRead), rl.Limit("balance", "uid"))
+		ug.GET("/history", h.history, az.RequireScope(auth.ScopeHistoryRead), rl.Limit("history", "uid"))
+
+		// admin actions
+		ug.POST("/freeze", h.freeze, az.RequireScope(auth.ScopeUsersAdmin), rl.Limit("freeze", "uid"))
+		ug.POST("/unfreeze", h.unfreeze, az.RequireScope(auth.ScopeUsersAdmin), rl.Limit("unfreeze", "uid"))
+		ug.PUT("/fee-schedule", h.setFeeSchedule, az.RequireScope(auth.ScopeUsersAdmin), rl.Limit("fee_schedule", "uid"))
+	}
 
 	return h
 }
@@ -0,0 +1,127 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/ratelimit"
+	"github.com/diptomondal007/your-money/app/server/reconcile"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+// maxSettlementFileSize is the largest settlement file accepted by the api
+const maxSettlementFileSize = 32 << 20
+
+type ReconcileHandler struct {
+	uc  usecase.ReconcileUseCase
+	cfg config.Reconcile
+}
+
+// NewReconcileHandler registers the reconciliation routes. reconciling requires the ledger:reconcile scope
+func NewReconcileHandler(r *Router, uc usecase.ReconcileUseCase, cfg config.Reconcile, az *auth.Authorizer, rl *ratelimit.Limiter) ReconcileHandler {
+	h := ReconcileHandler{uc: uc, cfg: cfg}
+
+	for _, g := range r.V1("/reconciliations") {
+		g.POST("", h.reconcile, az.Authenticate(), audit.Capture(auth.Actor), az.RequireScope(auth.ScopeReconcile), rl.Limit("reconcile", ""))
+	}
+
+	return h
+}
+
+// ParseReconcileParams reads the format, the tolerance and the period of a settlement file from params, falling
+// back to the defaults of cfg. tolerance is a duration (ex - 48h), from and to are RFC 3339 timestamps
+func ParseReconcileParams(params func(name string) string, cfg config.Reconcile) (*usecase.ReconcileReq, error) {
+	param := func(name, fallback string) string {
+		if v := params(name); v != "" {
+			return v
+		}
+		return fallback
+	}
+
+	req := &usecase.ReconcileReq{Format: reconcile.Format{DateLayout: param("date_format", cfg.DateFormat)}}
+
+	var err error
+	if req.Format.Mapping, err = reconcile.ParseMapping(param("columns", cfg.Columns)); err != nil {
+		return nil, err
+	}
+	if req.Format.Delimiter, err = reconcile.ParseDelimiter(param("delimiter", cfg.Delimiter)); err != nil {
+		return nil, err
+	}
+
+	req.Tolerance = cfg.DateTolerance
+	if v := params("tolerance"); v != "" {
+		if req.Tolerance, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("tolerance should be a duration, ex - 48h")
+		}
+	}
+
+	if v := params("from"); v != "" {
+		if req.Window.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("from should be a RFC 3339 timestamp")
+		}
+	}
+	if v := params("to"); v != "" {
+		if req.Window.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("to should be a RFC 3339 timestamp")
+		}
+	}
+	return req, nil
+}
+
+// reconcile reconciles the settlement file sent as the request body. the report is answered as csv if the client
+// accepts text/csv, otherwise as json
+func (h *ReconcileHandler) reconcile(c echo.Context) error {
+	req, err := ParseReconcileParams(c.QueryParam, h.cfg)
+	if err != nil {
+		return response.SendError(c, response.ErrBadRequest, err)
+	}
+
+	file, err := io.ReadAll(io.LimitReader(c.Request().Body, maxSettlementFileSize+1))
+	if err != nil {
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("the settlement file could not be read"))
+	}
+	if len(file) > maxSettlementFileSize {
+		return response.SendError(c, response.WrapError(fmt.Errorf("the settlement file is larger than %d MiB", maxSettlementFileSize>>20), http.StatusBadRequest, response.CodeInvalidSettlement))
+	}
+	req.File = bytes.NewReader(file)
+
+	report, err := h.uc.Reconcile(c.Request().Context(), req)
+	if err != nil {
+		return response.SendError(c, err)
+	}
+
+	if strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/csv") {
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		c.Response().WriteHeader(http.StatusOK)
+		return report.WriteCSV(c.Response())
+	}
+
+	return c.JSON(response.RespondSuccess(http.StatusOK, "reconciliation completed!", report))
+}
@@ -0,0 +1,169 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/reconcile"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+var testReconcileConfig = config.Reconcile{
+	Columns:       "transaction_id=transaction_id,amount=amount,date=date",
+	DateFormat:    "2006-01-02",
+	Delimiter:     ",",
+	DateTolerance: 48 * time.Hour,
+}
+
+// newReconcileTest returns an echo serving the reconciliation of a ledger holding the credits tx_a 10, tx_b 5 and tx_c 2
+func newReconcileTest(t *testing.T) *echo.Echo {
+	return newAuditedReconcileTest(t, nil)
+}
+
+// newAuditedReconcileTest is newReconcileTest recording the runs to ar
+func newAuditedReconcileTest(t *testing.T, ar repository.AuditRepository) *echo.Echo {
+	ur := repository.NewMemoryUserRepo(repository.SeedUsers()...)
+	for id, amount := range map[string]float64{"tx_a": 10, "tx_b": 5, "tx_c": 2} {
+		_, err := ur.AddBalance(context.Background(), repository.SeedUsers()[0].ID, id, amount, nil)
+		require.NoError(t, err)
+	}
+
+	e := echo.New()
+	NewReconcileHandler(newTestRouter(e), usecase.NewReconcileUseCase(ur, ar), testReconcileConfig, auth.NewAuthorizer(nil, nil), nil)
+	return e
+}
+
+func TestReconcile(t *testing.T) {
+	e := newReconcileTest(t)
+
+	now := time.Now().UTC()
+	q := url.Values{
+		"columns":   {"transaction_id=Reference,amount=Amount,date=Settled"},
+		"delimiter": {";"},
+		"from":      {now.Add(-time.Hour).Format(time.RFC3339)},
+		"to":        {now.Add(time.Hour).Format(time.RFC3339)},
+	}
+	date := now.Format("2006-01-02")
+	file := "Reference;Amount;Settled\ntx_a;10;" + date + "\ntx_b;6;" + date + "\ntx_x;1;" + date + "\n"
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/reconciliations?"+q.Encode(), strings.NewReader(file))
+	req.Header.Set(echo.HeaderContentType, "text/csv")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var res struct {
+		Data reconcile.Report `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, reconcile.Summary{Lines: 3, Transactions: 3, Matched: 1, MissingInLedger: 1, MissingInFile: 1, AmountMismatch: 1}, res.Data.Summary)
+	require.Len(t, res.Data.Entries, 4)
+	assert.Equal(t, reconcile.StatusAmountMismatch, res.Data.Entries[1].Status)
+	assert.Equal(t, "tx_c", res.Data.Entries[3].TransactionID)
+	assert.Equal(t, reconcile.StatusMissingInFile, res.Data.Entries[3].Status)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/reconciliations?"+q.Encode(), strings.NewReader(file))
+	req.Header.Set(echo.HeaderAccept, "text/csv")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "status,row,transaction_id,"))
+	assert.Contains(t, rec.Body.String(), "\nmissing_in_ledger,4,tx_x,,1.00,,")
+}
+
+// fakeAuditRepo keeps the appended audit events
+type fakeAuditRepo struct {
+	repository.AuditRepository
+	events []*model.AuditEvent
+}
+
+func (f *fakeAuditRepo) AppendAuditEvent(ctx context.Context, ev *model.AuditEvent) error {
+	f.events = append(f.events, ev)
+	return nil
+}
+
+func TestReconcileIsAudited(t *testing.T) {
+	ar := &fakeAuditRepo{}
+	e := newAuditedReconcileTest(t, ar)
+
+	date := time.Now().UTC().Format("2006-01-02")
+	for _, file := range []string{"transaction_id,amount,date\ntx_a,10," + date + "\n", "transaction_id,amount\ntx_a,10\n"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/reconciliations", strings.NewReader(file))
+		req.Header.Set(echo.HeaderContentType, "text/csv")
+		req.Header.Set(echo.HeaderXRequestID, "req-1")
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	require.Len(t, ar.events, 2)
+	assert.Equal(t, audit.ActionLedgerReconcile, ar.events[0].Action)
+	assert.Equal(t, model.AuditOutcomeSuccess, ar.events[0].Outcome)
+	assert.Equal(t, auth.KindService, ar.events[0].ActorKind)
+	assert.Contains(t, ar.events[0].Details, `"summary":{"lines":1,`)
+
+	assert.Equal(t, model.AuditOutcomeFailed, ar.events[1].Outcome)
+	assert.Contains(t, ar.events[1].Details, `"error":"invalid settlement file:`)
+}
+
+func TestReconcileBadRequest(t *testing.T) {
+	e := newReconcileTest(t)
+
+	tests := []struct {
+		name  string
+		query string
+		file  string
+		body  string
+	}{
+		{name: "invalid columns", query: "?columns=transaction_id=ref", file: "ref\n", body: `"error_code":"bad_request"`},
+		{name: "invalid tolerance", query: "?tolerance=2d", file: "", body: `"message":"tolerance should be a duration, ex - 48h"`},
+		{name: "invalid from", query: "?from=yesterday", file: "", body: `"message":"from should be a RFC 3339 timestamp"`},
+		{name: "from without to", query: "?from=2024-02-01T00:00:00Z", file: "transaction_id,amount,date\n", body: `"details":[{"field":"from","code":"required","message":"from and to should be given together"}]`},
+		{name: "missing column", query: "", file: "transaction_id,amount\n", body: `"error_code":"invalid_settlement_file"`},
+		{name: "invalid row", query: "", file: "transaction_id,amount,date\ntx_a,ten,2024-02-01\n", body: `"message":"invalid settlement file: row 2: amount \"ten\" is not a number"`},
+		{name: "no lines", query: "", file: "transaction_id,amount,date\n", body: `"message":"invalid settlement file: the file has no lines, from and to should be given"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/reconciliations"+tt.query, strings.NewReader(tt.file))
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.body)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+const (
+	// PrefixV1 is the path prefix of the v1 routes
+	PrefixV1 = "/v1"
+
+	// HeaderDeprecation is the rfc 9745 header telling the legacy routes are deprecated
+	HeaderDeprecation = "Deprecation"
+	// HeaderSunset is the rfc 8594 header telling when the legacy routes stop being served
+	HeaderSunset = "Sunset"
+	// HeaderLink links the legacy routes to their successor version
+	HeaderLink = "Link"
+)
+
+// Router mounts the routes of the api versions. every version has its own path prefix, so a breaking
+// change gets a new version while the clients of the older ones keep working
+type Router struct {
+	e   *echo.Echo
+	cfg config.API
+}
+
+// NewRouter returns a new router of e
+func NewRouter(e *echo.Echo, cfg config.API) *Router {
+	return &Router{e: e, cfg: cfg}
+}
+
+// V1 returns the groups the v1 routes under prefix are registered on. the routes are served under /v1 and,
+// if the legacy routes are enabled, at the root as deprecated aliases
+func (r *Router) V1(prefix string, m ...echo.MiddlewareFunc) []*echo.Group {
+	groups := []*echo.Group{r.e.Group(PrefixV1+prefix, m...)}
+	if r.cfg.LegacyRoutes {
+		groups = append(groups, r.e.Group(prefix, append([]echo.MiddlewareFunc{r.deprecated(PrefixV1)}, m...)...))
+	}
+	return groups
+}
+
+// deprecated marks the responses of a legacy route as deprecated and links them to the route under successor
+func (r *Router) deprecated(successor string) echo.MiddlewareFunc {
+	deprecation := fmt.Sprintf("@%d", r.cfg.Deprecation.Unix())
+	sunset := r.cfg.Sunset.UTC().Format(http.TimeFormat)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			h := c.Response().Header()
+			h.Set(HeaderDeprecation, deprecation)
+			h.Set(HeaderSunset, sunset)
+			h.Add(HeaderLink, fmt.Sprintf(`<%s%s>; rel="successor-version"`, successor, c.Request().URL.EscapedPath()))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+// newTestRouter returns a router serving the legacy routes
+func newTestRouter(e *echo.Echo) *Router {
+	return NewRouter(e, config.API{
+		LegacyRoutes: true,
+		Deprecation:  time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC),
+		Sunset:       time.Date(2027, time.May, 1, 0, 0, 0, 0, time.UTC),
+	})
+}
+
+func TestRouterV1(t *testing.T) {
+	e := echo.New()
+	for _, g := range newTestRouter(e).V1("/users/:uid") {
+		g.GET("/balance", func(c echo.Context) error {
+			return c.String(http.StatusOK, c.Param("uid"))
+		})
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		deprecated bool
+	}{
+		{name: "versioned", path: "/v1/users/u1/balance"},
+		{name: "legacy", path: "/users/u1/balance", deprecated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "u1", rec.Body.String())
+
+			if !tt.deprecated {
+				assert.Empty(t, rec.Header().Get(HeaderDeprecation))
+				assert.Empty(t, rec.Header().Get(HeaderSunset))
+				return
+			}
+			assert.Equal(t, "@1793491200", rec.Header().Get(HeaderDeprecation))
+			assert.Equal(t, "Sat, 01 May 2027 00:00:00 GMT", rec.Header().Get(HeaderSunset))
+			assert.Equal(t, `</v1/users/u1/balance>; rel="successor-version"`, rec.Header().Get(HeaderLink))
+		})
+	}
+}
+
+func TestRouterWithoutLegacyRoutes(t *testing.T) {
+	e := echo.New()
+	for _, g := range NewRouter(e, config.API{}).V1("/audit") {
+		g.GET("", func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/audit", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/audit", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
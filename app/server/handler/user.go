@@ -19,97 +19,154 @@ package handler
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
+	v1 "github.com/diptomondal007/your-money/app/server/handler/v1"
+	"github.com/diptomondal007/your-money/app/server/model"
 	"github.com/diptomondal007/your-money/app/server/usecase"
 	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/logger"
 )
 
-func validateAddReq(req *usecase.AddBalanceReq) error {
-	if req.Amount <= 0 {
-		return fmt.Errorf("not a valid amount. amount should be a positive value")
+func (h *Handler) createUser(c echo.Context) error {
+	var body *v1.CreateUserReq
+	if err := c.Bind(&body); err != nil || body == nil {
+		logger.FromContext(c.Request().Context()).Warn("bad request body", slog.Any("error", err))
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("not a valid request body"))
 	}
 
-	if req.TransactionID == "" {
-		return fmt.Errorf("valid transaction id required")
-	}
-
-	if !strings.HasPrefix(req.TransactionID, "tx_") {
-		return fmt.Errorf("valid transaction id should have prefix tx_")
+	u, err := h.uc.CreateUser(c.Request().Context(), body.UseCase())
+	if err != nil {
+		return response.SendError(c, err)
 	}
 
-	return nil
+	return c.JSON(response.RespondSuccess(http.StatusCreated, "user created!", v1.NewUser(u)))
 }
 
 func (h *Handler) addBalance(c echo.Context) error {
 	userID := c.Param("uid")
 	if userID == "" {
-		return c.JSON(response.RespondError(response.ErrBadRequest, fmt.Errorf("not a valid user id")))
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("not a valid user id"))
 	}
 
-	var req *usecase.AddBalanceReq
-	err := c.Bind(&req)
-	if err != nil {
-		log.Println("bad request body", err)
-		return c.JSON(response.RespondError(response.ErrBadRequest, fmt.Errorf("not a valid request body")))
+	var body *v1.AddBalanceReq
+	err := c.Bind(&body)
+	if err != nil || body == nil {
+		logger.FromContext(c.Request().Context()).Warn("bad request body", slog.Any("error", err))
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("not a valid request body"))
 	}
 
-	err = validateAddReq(req)
+	req := body.UseCase()
+	err = req.Validate()
 	if err != nil {
-		log.Println("bad request data, req: ", req)
-		return c.JSON(response.RespondError(response.ErrBadRequest, err))
+		logger.FromContext(c.Request().Context()).Warn("bad request data", slog.Any("req", *req), slog.Any("error", err))
+		return response.SendError(c, response.ErrBadRequest, err)
 	}
 
-	u, err := h.uc.AddBalance(userID, req)
+	u, err := h.uc.AddBalance(c.Request().Context(), userID, req)
 	if err != nil {
-		return c.JSON(response.RespondError(err))
+		return response.SendError(c, err)
 	}
 
-	return c.JSON(response.RespondSuccess(http.StatusAccepted, "transaction successful!", u))
+	return c.JSON(response.RespondSuccess(http.StatusAccepted, "transaction successful!", v1.NewAddBalanceResp(u)))
 }
 
 func (h *Handler) checkBalance(c echo.Context) error {
 	userID := c.Param("uid")
 	if userID == "" {
-		return c.JSON(response.RespondError(response.ErrBadRequest, fmt.Errorf("not a valid user id")))
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("not a valid user id"))
 	}
 
-	ds, err := h.uc.CheckBalance(userID)
+	var (
+		ds  *usecase.CheckBalanceResp
+		err error
+	)
+	if asOf := c.QueryParam("as_of"); asOf != "" {
+		t, parseErr := time.Parse(time.RFC3339Nano, asOf)
+		if parseErr != nil {
+			return response.SendError(c, response.ErrBadRequest, fmt.Errorf("as of should be a valid rfc 3339 time"))
+		}
+		ds, err = h.uc.CheckBalanceAt(c.Request().Context(), userID, t)
+	} else {
+		ds, err = h.uc.CheckBalance(c.Request().Context(), userID)
+	}
 	if err != nil {
-		return c.JSON(response.RespondError(err))
+		return response.SendError(c, err)
 	}
 
-	return c.JSON(response.RespondSuccess(http.StatusOK, "request successful!", ds))
+	return c.JSON(response.RespondSuccess(http.StatusOK, "request successful!", v1.NewCheckBalanceResp(ds)))
 }
 
 func (h *Handler) history(c echo.Context) error {
 	userID := c.Param("uid")
 	if userID == "" {
-		return c.JSON(response.RespondError(response.ErrBadRequest, fmt.Errorf("not a valid user id")))
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("not a valid user id"))
 	}
 
 	pageSize := c.QueryParam("page_size")
 
 	pageSizeInt, err := strconv.Atoi(pageSize)
 	if err != nil {
-		return c.JSON(response.RespondError(response.ErrBadRequest, fmt.Errorf("page size should be a valid integer")))
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("page size should be a valid integer"))
 	}
 
 	if pageSizeInt < 1 {
-		return c.JSON(response.RespondError(response.ErrBadRequest, fmt.Errorf("page size should be greater than 0")))
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("page size should be greater than 0"))
 	}
 
 	cursor := c.QueryParam("page")
 
-	ds, err := h.uc.ListHistory(userID, int64(pageSizeInt), cursor)
+	ds, err := h.uc.ListHistory(c.Request().Context(), userID, int64(pageSizeInt), cursor)
+	if err != nil {
+		return response.SendError(c, err)
+	}
+
+	return c.JSON(response.RespondSuccess(http.StatusOK, "request successful!", v1.NewListHistory(ds)))
+}
+
+func (h *Handler) freeze(c echo.Context) error {
+	return h.setStatus(c, model.UserStatusFrozen)
+}
+
+func (h *Handler) unfreeze(c echo.Context) error {
+	return h.setStatus(c, model.UserStatusActive)
+}
+
+func (h *Handler) setStatus(c echo.Context, status string) error {
+	userID := c.Param("uid")
+	if userID == "" {
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("not a valid user id"))
+	}
+
+	ds, err := h.uc.SetStatus(c.Request().Context(), userID, status)
+	if err != nil {
+		return response.SendError(c, err)
+	}
+
+	return c.JSON(response.RespondSuccess(http.StatusOK, "request successful!", v1.NewUserStatusResp(ds)))
+}
+
+func (h *Handler) setFeeSchedule(c echo.Context) error {
+	userID := c.Param("uid")
+	if userID == "" {
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("not a valid user id"))
+	}
+
+	var body *v1.FeeScheduleReq
+	if err := c.Bind(&body); err != nil || body == nil {
+		logger.FromContext(c.Request().Context()).Warn("bad request body", slog.Any("error", err))
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("not a valid request body"))
+	}
+
+	ds, err := h.uc.SetFeeSchedule(c.Request().Context(), userID, body.FeeSchedule)
 	if err != nil {
-		return c.JSON(response.RespondError(err))
+		return response.SendError(c, err)
 	}
 
-	return c.JSON(response.RespondSuccess(http.StatusOK, "request successful!", ds))
+	return c.JSON(response.RespondSuccess(http.StatusOK, "request successful!", v1.NewFeeScheduleResp(ds)))
 }
@@ -18,8 +18,9 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
@@ -32,10 +33,14 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/fee"
+	"github.com/diptomondal007/your-money/app/server/ledger"
+	"github.com/diptomondal007/your-money/app/server/model"
 	"github.com/diptomondal007/your-money/app/server/repository"
 	"github.com/diptomondal007/your-money/app/server/usecase"
 	"github.com/diptomondal007/your-money/app/utils"
-	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/config"
 )
 
 func TestAddBalanceBadRequest(t *testing.T) {
@@ -67,10 +72,71 @@ func TestAddBalanceBadRequest(t *testing.T) {
 	}
 }
 
+func TestAddBalanceBadRequestHasRequestID(t *testing.T) {
+	s := echo.New()
+
+	h, _, err := newTest(s)
+	if err != nil {
+		panic(err)
+	}
+
+	res := `{"success":false,"message":"valid transaction id required","status_code":400,"error_code":"validation_failed","request_id":"req-123",` +
+		`"details":[{"field":"transaction_id","code":"required","message":"valid transaction id required"}]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"amount": 10}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set(echo.HeaderXRequestID, "req-123")
+
+	c := s.NewContext(req, rec)
+	c.SetPath("/users/:uid/add")
+
+	// params
+	c.SetParamNames("uid")
+	c.SetParamValues("6d7750a1-c3f2-4765-bf8f-33bc80f3f809")
+
+	if assert.NoError(t, h.addBalance(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, res+"\n", rec.Body.String())
+	}
+}
+
+func TestAddBalanceTransactionIDTooLong(t *testing.T) {
+	s := echo.New()
+
+	h, _, err := newTest(s)
+	if err != nil {
+		panic(err)
+	}
+
+	// the id of the house fee transaction, fee_house_<id>, would overflow the column
+	txID := "tx_" + strings.Repeat("a", ledger.MaxTransactionIDLength-2)
+	res := `{"success":false,"message":"transaction id should be at most 70 characters","status_code":400,"error_code":"validation_failed",` +
+		`"details":[{"field":"transaction_id","code":"invalid_value","message":"transaction id should be at most 70 characters"}]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"amount": 10, "transaction_id": "`+txID+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec := httptest.NewRecorder()
+
+	c := s.NewContext(req, rec)
+	c.SetPath("/users/:uid/add")
+
+	// params
+	c.SetParamNames("uid")
+	c.SetParamValues("6d7750a1-c3f2-4765-bf8f-33bc80f3f809")
+
+	if assert.NoError(t, h.addBalance(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, res+"\n", rec.Body.String())
+	}
+}
+
 func TestAddBalanceSuccessful(t *testing.T) {
 	s := echo.New()
 
-	res := `{"success":true,"message":"transaction successful!","status_code":202,"data":{"current_balance":110.1}}`
+	res := `{"success":true,"message":"transaction successful!","status_code":202,"data":{"current_balance":110.1,"fee":0}}`
 	purchaseBody := `{
     					"amount": 10,
 						"transaction_id": "tx_1as4ndakda"
@@ -89,7 +155,7 @@ func TestAddBalanceSuccessful(t *testing.T) {
 	db, mock := utils.MockSqlxDB()
 	ur := repository.NewUserRepo(db)
 
-	us := usecase.NewUserUseCase(ur)
+	us := usecase.NewUserUseCase(ur, nil)
 
 	uRows := sqlmock.NewRows([]string{"id", "name", "balance"}).AddRow("6d7750a1-c3f2-4765-bf8f-33bc80f3f809", "Test", 100.10)
 
@@ -102,19 +168,28 @@ func TestAddBalanceSuccessful(t *testing.T) {
 	//mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(uRows)
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(sql.ErrNoRows)
 
+	query = `SELECT "t".* FROM "transactions" AS "t" WHERE ("user_id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809') ORDER BY "t"."id" DESC LIMIT 1`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(sql.ErrNoRows)
+
 	query = `UPDATE "users" SET "balance"=balance + 10 WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`
 	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 1))
 
-	query = `INSERT INTO "transactions" ("amount", "created_at", "transaction_id", "user_id")`
+	query = `INSERT INTO "transactions" ("amount", "created_at", "fee", "hash", "prev_hash", "transaction_id", "user_id")`
 	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewErrorResult(nil))
 
 	query = `SELECT "u".* FROM "users" AS "u" WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`
 	uRows = sqlmock.NewRows([]string{"id", "name", "balance"}).AddRow("6d7750a1-c3f2-4765-bf8f-33bc80f3f809", "Test", 110.10)
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(uRows)
 
+	mock.ExpectExec(`INSERT INTO "outbox_events" .* VALUES \('6d7750a1-c3f2-4765-bf8f-33bc80f3f809', '[^']+', NULL, 'balance\.credited', '{"user_id":"6d7750a1-c3f2-4765-bf8f-33bc80f3f809","transaction_id":"tx_1as4ndakda","amount":10,"balance":110.1}'\)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	query = `INSERT INTO "audit_events"`
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(1, 1))
+
 	mock.ExpectCommit()
 
-	h := NewHandler(s, us)
+	h := NewHandler(newTestRouter(s), us, auth.NewAuthorizer(nil, nil), nil)
 
 	if assert.NoError(t, h.addBalance(c)) {
 		assert.Equal(t, http.StatusAccepted, rec.Code)
@@ -125,7 +200,7 @@ func TestAddBalanceSuccessful(t *testing.T) {
 func TestAddBalanceUnSuccessfulUserNotFound(t *testing.T) {
 	s := echo.New()
 
-	res := `{"success":false,"message":"user not found","status_code":404}`
+	res := `{"success":false,"message":"user not found","status_code":404,"error_code":"user_not_found"}`
 
 	body := `{"amount": 10, "transaction_id": "tx_1as4ndakda"}`
 
@@ -150,9 +225,10 @@ func TestAddBalanceUnSuccessfulUserNotFound(t *testing.T) {
 
 	mock.ExpectBegin()
 	query := `SELECT "u".* FROM "users" AS "u" WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809') FOR UPDATE`
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(response.WrapError(fmt.Errorf("user not found"), http.StatusNotFound, ""))
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(sql.ErrNoRows)
 
 	mock.ExpectRollback()
+	mock.ExpectExec(`INSERT INTO "audit_events" .*'failed'`).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	if assert.NoError(t, h.addBalance(c)) {
 		assert.Equal(t, http.StatusNotFound, rec.Code)
@@ -163,7 +239,7 @@ func TestAddBalanceUnSuccessfulUserNotFound(t *testing.T) {
 func TestAddBalanceUnSuccessfulTransactionIDExists(t *testing.T) {
 	s := echo.New()
 
-	res := `{"success":false,"message":"transaction was already processed","status_code":422}`
+	res := `{"success":false,"message":"transaction was already processed","status_code":422,"error_code":"transaction_already_processed"}`
 	body := `{
     					"amount": 10,
 						"transaction_id": "tx_1as4ndakda"
@@ -182,7 +258,7 @@ func TestAddBalanceUnSuccessfulTransactionIDExists(t *testing.T) {
 	db, mock := utils.MockSqlxDB()
 	ur := repository.NewUserRepo(db)
 
-	us := usecase.NewUserUseCase(ur)
+	us := usecase.NewUserUseCase(ur, nil)
 
 	uRows := sqlmock.NewRows([]string{"id", "name", "balance"}).AddRow("6d7750a1-c3f2-4765-bf8f-33bc80f3f809", "Test", 100.10)
 
@@ -198,7 +274,7 @@ func TestAddBalanceUnSuccessfulTransactionIDExists(t *testing.T) {
 	query = `UPDATE "users" SET "balance"=balance + 10 WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`
 	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 1))
 
-	query = `INSERT INTO "transactions" ("amount", "created_at", "transaction_id", "user_id")`
+	query = `INSERT INTO "transactions" ("amount", "created_at", "fee", "hash", "prev_hash", "transaction_id", "user_id")`
 	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewErrorResult(nil))
 
 	query = `SELECT "u".* FROM "users" AS "u" WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`
@@ -207,7 +283,7 @@ func TestAddBalanceUnSuccessfulTransactionIDExists(t *testing.T) {
 
 	mock.ExpectCommit()
 
-	h := NewHandler(s, us)
+	h := NewHandler(newTestRouter(s), us, auth.NewAuthorizer(nil, nil), nil)
 
 	if assert.NoError(t, h.addBalance(c)) {
 		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
@@ -215,6 +291,88 @@ func TestAddBalanceUnSuccessfulTransactionIDExists(t *testing.T) {
 	}
 }
 
+func TestCreateUser(t *testing.T) {
+	e := echo.New()
+	ur := repository.NewMemoryUserRepo()
+	NewHandler(newTestRouter(e), usecase.NewUserUseCase(ur, nil), auth.NewAuthorizer(nil, nil), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{"name": "New", "opening_balance": 50}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var res struct {
+		Data struct {
+			ID      string  `json:"id"`
+			Name    string  `json:"name"`
+			Balance float64 `json:"balance"`
+			Status  string  `json:"status"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "New", res.Data.Name)
+	assert.Equal(t, float64(50), res.Data.Balance)
+	assert.Equal(t, "active", res.Data.Status)
+
+	u, err := ur.GetUserInfo(req.Context(), res.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(50), u.OpeningBalance)
+}
+
+func TestCreateUserBadRequest(t *testing.T) {
+	e := echo.New()
+	NewHandler(newTestRouter(e), usecase.NewUserUseCase(repository.NewMemoryUserRepo(), nil), auth.NewAuthorizer(nil, nil), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{"name": " ", "opening_balance": -1}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"details":[{"field":"name","code":"required","message":"name required"},{"field":"opening_balance","code":"invalid_value","message":"opening balance should not be negative"}]`)
+}
+
+func TestCheckBalanceAsOf(t *testing.T) {
+	e := echo.New()
+	ur := repository.NewMemoryUserRepo(repository.SeedUsers()...)
+	NewHandler(newTestRouter(e), usecase.NewUserUseCase(ur, nil), auth.NewAuthorizer(nil, nil), nil)
+
+	id := repository.SeedUsers()[0].ID
+	_, err := ur.AddBalance(context.Background(), id, "tx_as_of", 20, nil)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		userID string
+		query  string
+		code   int
+		body   string
+	}{
+		{name: "current", query: "", code: http.StatusOK, body: `"data":{"balance":120}`},
+		{name: "before the credit", query: "?as_of=2000-01-01T00:00:00Z", code: http.StatusOK, body: `"data":{"balance":100,"as_of":"2000-01-01T00:00:00Z"}`},
+		{name: "after the credit", query: "?as_of=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339), code: http.StatusOK, body: `"balance":120`},
+		{name: "invalid", query: "?as_of=yesterday", code: http.StatusBadRequest, body: `"message":"as of should be a valid rfc 3339 time"`},
+		{name: "date only", query: "?as_of=2024-02-01", code: http.StatusBadRequest, body: `"message":"as of should be a valid rfc 3339 time"`},
+		{name: "unknown user", userID: "unknown", query: "?as_of=2024-02-01T00:00:00Z", code: http.StatusNotFound, body: `"error_code":"user_not_found"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := id
+			if tt.userID != "" {
+				userID = tt.userID
+			}
+			req := httptest.NewRequest(http.MethodGet, "/v1/users/"+userID+"/balance"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.code, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.body)
+		})
+	}
+}
+
 func newTest(e *echo.Echo) (Handler, sqlmock.Sqlmock, error) {
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
 	if err != nil {
@@ -227,8 +385,51 @@ func newTest(e *echo.Echo) (Handler, sqlmock.Sqlmock, error) {
 	ur := repository.NewUserRepo(dbp)
 
 	// use cases
-	us := usecase.NewUserUseCase(ur)
+	us := usecase.NewUserUseCase(ur, nil)
 
-	h := NewHandler(e, us)
+	h := NewHandler(newTestRouter(e), us, auth.NewAuthorizer(nil, nil), nil)
 	return h, mock, nil
 }
+
+func TestAddBalanceWithFee(t *testing.T) {
+	e := echo.New()
+	ur := repository.NewMemoryUserRepo(repository.SeedUsers()...)
+	fees, err := fee.NewPolicy(config.Fees{Schedules: "standard=2%;premium=0", DefaultSchedule: "standard", HouseAccount: "house"})
+	assert.NoError(t, err)
+	assert.NoError(t, ur.CreateUser(context.Background(), &model.User{ID: "house", Name: "House", Status: model.UserStatusActive}))
+	NewHandler(newTestRouter(e), usecase.NewUserUseCase(ur, fees), auth.NewAuthorizer(nil, nil), nil)
+
+	id := repository.SeedUsers()[0].ID
+	credit := func(txID string) string {
+		req := httptest.NewRequest(http.MethodPost, "/v1/users/"+id+"/add", strings.NewReader(`{"transaction_id": "`+txID+`", "amount": 50}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		return rec.Body.String()
+	}
+
+	assert.Contains(t, credit("tx_fee_1"), `"data":{"current_balance":149,"fee":1,"fee_schedule":"standard"}`)
+
+	house, err := ur.GetUserInfo(context.Background(), "house")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), house.Balance)
+
+	setSchedule := func(schedule string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/v1/users/"+id+"/fee-schedule", strings.NewReader(`{"fee_schedule": "`+schedule+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := setSchedule("gold")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"message":"fee schedule gold is not defined"`)
+
+	rec = setSchedule("premium")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"data":{"id":"`+id+`","fee_schedule":"premium"}`)
+
+	assert.Contains(t, credit("tx_fee_2"), `"data":{"current_balance":199,"fee":0}`)
+}
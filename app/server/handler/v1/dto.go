@@ -0,0 +1,132 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package v1 holds the request and response dtos of the v1 rest api. they are converted from and to the
+// dtos of the use cases, so the use cases may change without breaking the v1 clients
+package v1
+
+import (
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/usecase"
+)
+
+// AddBalanceReq is the body of the add balance request
+type AddBalanceReq struct {
+	TransactionID string  `json:"transaction_id"`
+	Amount        float64 `json:"amount"`
+}
+
+// UseCase returns the use case request of r
+func (r AddBalanceReq) UseCase() *usecase.AddBalanceReq {
+	return &usecase.AddBalanceReq{TransactionID: r.TransactionID, Amount: r.Amount}
+}
+
+// CreateUserReq is the body of the create user request
+type CreateUserReq struct {
+	Name           string  `json:"name"`
+	OpeningBalance float64 `json:"opening_balance"`
+	FeeSchedule    string  `json:"fee_schedule"`
+}
+
+// UseCase returns the use case request of r
+func (r CreateUserReq) UseCase() *usecase.CreateUserReq {
+	return &usecase.CreateUserReq{Name: r.Name, OpeningBalance: r.OpeningBalance, FeeSchedule: r.FeeSchedule}
+}
+
+// FeeScheduleReq is the body of the fee schedule request, an empty schedule applies the default schedule
+type FeeScheduleReq struct {
+	FeeSchedule string `json:"fee_schedule"`
+}
+
+type User struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Balance     float64   `json:"balance"`
+	Status      string    `json:"status"`
+	FeeSchedule string    `json:"fee_schedule,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewUser returns the v1 response of u
+func NewUser(u *usecase.User) User {
+	return User{ID: u.ID, Name: u.Name, Balance: u.Balance, Status: u.Status, FeeSchedule: u.FeeSchedule, CreatedAt: u.CreatedAt}
+}
+
+type AddBalanceResp struct {
+	Balance     float64 `json:"current_balance"`
+	Fee         float64 `json:"fee"`
+	FeeSchedule string  `json:"fee_schedule,omitempty"`
+}
+
+// NewAddBalanceResp returns the v1 response of r
+func NewAddBalanceResp(r *usecase.AddBalanceResp) AddBalanceResp {
+	return AddBalanceResp{Balance: r.Balance, Fee: r.Fee, FeeSchedule: r.FeeSchedule}
+}
+
+type CheckBalanceResp struct {
+	Balance float64    `json:"balance"`
+	AsOf    *time.Time `json:"as_of,omitempty"`
+}
+
+// NewCheckBalanceResp returns the v1 response of r
+func NewCheckBalanceResp(r *usecase.CheckBalanceResp) CheckBalanceResp {
+	return CheckBalanceResp{Balance: r.Balance, AsOf: r.AsOf}
+}
+
+type UserStatusResp struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// NewUserStatusResp returns the v1 response of r
+func NewUserStatusResp(r *usecase.UserStatusResp) UserStatusResp {
+	return UserStatusResp{ID: r.ID, Status: r.Status}
+}
+
+type FeeScheduleResp struct {
+	ID          string `json:"id"`
+	FeeSchedule string `json:"fee_schedule"`
+}
+
+// NewFeeScheduleResp returns the v1 response of r
+func NewFeeScheduleResp(r *usecase.FeeScheduleResp) FeeScheduleResp {
+	return FeeScheduleResp{ID: r.ID, FeeSchedule: r.FeeSchedule}
+}
+
+type ListHistory struct {
+	Total     int64     `json:"total"`
+	PageSize  int64     `json:"page_size"`
+	NextPage  string    `json:"next_page"`
+	Histories []History `json:"histories"`
+}
+
+type History struct {
+	CreatedAt     time.Time `json:"created_at"`
+	Amount        float64   `json:"amount"`
+	TransactionID string    `json:"transaction_id"`
+	Fee           float64   `json:"fee,omitempty"`
+}
+
+// NewListHistory returns the v1 response of r
+func NewListHistory(r *usecase.ListHistory) ListHistory {
+	res := ListHistory{Total: r.Total, PageSize: r.PageSize, NextPage: r.NextPage, Histories: make([]History, 0, len(r.Histories))}
+	for _, h := range r.Histories {
+		res.Histories = append(res.Histories, History{CreatedAt: h.CreatedAt, Amount: h.Amount, TransactionID: h.TransactionID, Fee: h.Fee})
+	}
+	return res
+}
@@ -0,0 +1,206 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/ratelimit"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/logger"
+)
+
+const (
+	defaultDeliveryPageSize = 50
+	maxDeliveryPageSize     = 500
+)
+
+type WebhookHandler struct {
+	uc usecase.WebhookUseCase
+}
+
+// NewWebhookHandler registers the webhook subscription routes. they require the webhooks:admin scope
+func NewWebhookHandler(r *Router, uc usecase.WebhookUseCase, az *auth.Authorizer, rl *ratelimit.Limiter) WebhookHandler {
+	h := WebhookHandler{uc: uc}
+
+	for _, wg := range r.V1("/webhooks", az.Authenticate(), audit.Capture(auth.Actor), az.RequireScope(auth.ScopeWebhooksAdmin), rl.Limit("webhooks", "")) {
+		wg.POST("", h.create)
+		wg.GET("", h.list)
+		wg.GET("/:id", h.get)
+		wg.PUT("/:id", h.update)
+		wg.DELETE("/:id", h.delete)
+
+		// deliveries
+		wg.GET("/:id/deliveries", h.deliveries)
+		wg.POST("/:id/replay", h.replay)
+		wg.POST("/:id/deliveries/:did/replay", h.replay)
+	}
+
+	return h
+}
+
+// idParam parses a numeric id path param
+func idParam(c echo.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("not a valid %s", name)
+	}
+	return uint(id), nil
+}
+
+func bindWebhookReq(c echo.Context) (*usecase.WebhookReq, error) {
+	var req *usecase.WebhookReq
+	if err := c.Bind(&req); err != nil || req == nil {
+		logger.FromContext(c.Request().Context()).Warn("bad request body", slog.Any("error", err))
+		return nil, fmt.Errorf("not a valid request body")
+	}
+	return req, nil
+}
+
+func (h *WebhookHandler) create(c echo.Context) error {
+	req, err := bindWebhookReq(c)
+	if err != nil {
+		return response.SendError(c, response.ErrBadRequest, err)
+	}
+
+	wh, err := h.uc.Create(c.Request().Context(), req)
+	if err != nil {
+		return response.SendError(c, err)
+	}
+
+	return c.JSON(response.RespondSuccess(http.StatusCreated, "webhook created! store the secret, it's not shown again", wh))
+}
+
+func (h *WebhookHandler) list(c echo.Context) error {
+	whs, err := h.uc.List(c.Request().Context())
+	if err != nil {
+		return response.SendError(c, err)
+	}
+
+	return c.JSON(response.RespondSuccess(http.StatusOK, "request successful!", whs))
+}
+
+func (h *WebhookHandler) get(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return response.SendError(c, response.ErrBadRequest, err)
+	}
+
+	wh, err := h.uc.Get(c.Request().Context(), id)
+	if err != nil {
+		return response.SendError(c, err)
+	}
+
+	return c.JSON(response.RespondSuccess(http.StatusOK, "request successful!", wh))
+}
+
+func (h *WebhookHandler) update(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return response.SendError(c, response.ErrBadRequest, err)
+	}
+
+	req, err := bindWebhookReq(c)
+	if err != nil {
+		return response.SendError(c, response.ErrBadRequest, err)
+	}
+
+	wh, err := h.uc.Update(c.Request().Context(), id, req)
+	if err != nil {
+		return response.SendError(c, err)
+	}
+
+	return c.JSON(response.RespondSuccess(http.StatusOK, "webhook updated!", wh))
+}
+
+func (h *WebhookHandler) delete(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return response.SendError(c, response.ErrBadRequest, err)
+	}
+
+	if err := h.uc.Delete(c.Request().Context(), id); err != nil {
+		return response.SendError(c, err)
+	}
+
+	return c.JSON(response.RespondSuccess(http.StatusOK, "webhook deleted!", nil))
+}
+
+func (h *WebhookHandler) deliveries(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return response.SendError(c, response.ErrBadRequest, err)
+	}
+
+	status := c.QueryParam("status")
+	switch status {
+	case "", model.DeliveryStatusPending, model.DeliveryStatusDelivered, model.DeliveryStatusDead:
+	default:
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("status should be one of pending, delivered, dead"))
+	}
+
+	pageSize := defaultDeliveryPageSize
+	if v := c.QueryParam("page_size"); v != "" {
+		pageSize, err = strconv.Atoi(v)
+		if err != nil {
+			return response.SendError(c, response.ErrBadRequest, fmt.Errorf("page size should be a valid integer"))
+		}
+	}
+
+	if pageSize < 1 || pageSize > maxDeliveryPageSize {
+		return response.SendError(c, response.ErrBadRequest, fmt.Errorf("page size should be between 1 and %d", maxDeliveryPageSize))
+	}
+
+	ds, err := h.uc.ListDeliveries(c.Request().Context(), id, status, int64(pageSize), c.QueryParam("page"))
+	if err != nil {
+		return response.SendError(c, err)
+	}
+
+	return c.JSON(response.RespondSuccess(http.StatusOK, "request successful!", ds))
+}
+
+// replay replays a single delivery, or every dead delivery of the webhook if no delivery is given
+func (h *WebhookHandler) replay(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return response.SendError(c, response.ErrBadRequest, err)
+	}
+
+	var deliveryID uint
+	if c.Param("did") != "" {
+		if deliveryID, err = idParam(c, "did"); err != nil {
+			return response.SendError(c, response.ErrBadRequest, err)
+		}
+	}
+
+	res, err := h.uc.Replay(c.Request().Context(), id, deliveryID)
+	if err != nil {
+		return response.SendError(c, err)
+	}
+
+	return c.JSON(response.RespondSuccess(http.StatusAccepted, "deliveries scheduled!", res))
+}
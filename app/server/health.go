@@ -0,0 +1,66 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// health serves the liveness and readiness probes
+type health struct {
+	ready atomic.Bool
+	ping  func(ctx context.Context) error
+}
+
+func newHealth(e *echo.Echo, ping func(ctx context.Context) error) *health {
+	h := &health{ping: ping}
+
+	e.GET("/healthz", h.live)
+	e.GET("/readyz", h.readiness)
+
+	return h
+}
+
+// setReady marks the server as ready or not ready to receive traffic
+func (h *health) setReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+func (h *health) live(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *health) readiness(c echo.Context) error {
+	if !h.ready.Load() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	if err := h.ping(ctx); err != nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"status": "db unavailable"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "ready"})
+}
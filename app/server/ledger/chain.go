@@ -0,0 +1,174 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ledger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+)
+
+// balanceTolerance is the max difference allowed between a stored and a recomputed balance.
+// balances are stored as float4, so sums drift slightly
+const balanceTolerance = 0.01
+
+// AdjustmentPrefix is the prefix of the transaction ids of the adjusting transactions. an adjusting transaction
+// records the drift of a balance in the history without changing the balance
+const AdjustmentPrefix = "adj_"
+
+// FeePrefix is the prefix of the transaction ids of the fee transactions
+const FeePrefix = "fee_"
+
+// feeHousePrefix is the prefix of the transaction ids crediting a fee to the house account
+const feeHousePrefix = FeePrefix + "house_"
+
+// transactionIDLength is the length of the transaction id column
+const transactionIDLength = 80
+
+// MaxTransactionIDLength is the max length of the id of a credit, the ids of its fee transactions extend it
+const MaxTransactionIDLength = transactionIDLength - len(feeHousePrefix)
+
+// FeeTransactionIDs returns the ids of the transactions debiting the fee of the credit transactionID from the user
+// and crediting it to the house account
+func FeeTransactionIDs(transactionID string) (debit, credit string) {
+	return FeePrefix + transactionID, feeHousePrefix + transactionID
+}
+
+// Internal reports whether transactionID is the id of a transaction written by the ledger itself, an adjusting or
+// a fee transaction, which no settlement file lists
+func Internal(transactionID string) bool {
+	return strings.HasPrefix(transactionID, AdjustmentPrefix) || strings.HasPrefix(transactionID, FeePrefix)
+}
+
+// Timestamp returns t in the precision stored by the db, so that the hash of a transaction can be recomputed after reading it back
+func Timestamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// Hash returns the hash of a transaction chained to the previous transaction of the user by t.PrevHash. the fee of
+// a credit is hashed if it's charged, so the transactions without a fee, which include every transaction made before
+// fees were introduced, keep their hash
+func Hash(t *model.Transaction) string {
+	fields := []string{
+		t.PrevHash,
+		t.UserID,
+		t.TransactionID,
+		formatAmount(t.Amount),
+		Timestamp(t.CreatedAt).Format(time.RFC3339Nano),
+	}
+	if t.Fee != 0 {
+		fields = append(fields, formatAmount(t.Fee))
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// formatAmount formats an amount in the precision stored by the db, amounts are stored as float4
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(float64(float32(amount)), 'f', -1, 32)
+}
+
+// Issue is an inconsistency found in the history of a user
+type Issue struct {
+	UserID string
+	// TransactionID is the transaction where the chain breaks, empty if the balance doesn't match
+	TransactionID string
+	Reason        string
+}
+
+func (i Issue) String() string {
+	if i.TransactionID == "" {
+		return fmt.Sprintf("user %s: %s", i.UserID, i.Reason)
+	}
+	return fmt.Sprintf("user %s: transaction %s: %s", i.UserID, i.TransactionID, i.Reason)
+}
+
+// Chain walks the transactions of a user in insertion order, verifying every link and summing up the balance
+type Chain struct {
+	user    *model.User
+	balance float64
+	prev    string
+	// Legacy is the number of transactions made before the chain was introduced
+	Legacy int
+	// Verified is the number of chained transactions verified
+	Verified int
+}
+
+// NewChain returns a chain for the transactions of user
+func NewChain(user *model.User) *Chain {
+	return &Chain{user: user, balance: user.OpeningBalance}
+}
+
+// Add verifies the next transaction of the user. it returns an issue for the first broken link
+func (c *Chain) Add(t *model.Transaction) *Issue {
+	c.balance += t.Amount
+
+	if t.Hash == "" {
+		// transactions without a hash predate the chain and may only precede it
+		if c.prev != "" {
+			return c.issue(t, "transaction is not chained")
+		}
+		c.Legacy++
+		return nil
+	}
+
+	// the walk goes on from the stored hash, so that a broken link is reported only once
+	prev := c.prev
+	c.prev = t.Hash
+
+	if t.PrevHash != prev {
+		return c.issue(t, "previous hash doesn't match, a transaction was removed or inserted")
+	}
+	if Hash(t) != t.Hash {
+		return c.issue(t, "hash doesn't match, the transaction was modified")
+	}
+
+	c.Verified++
+	return nil
+}
+
+// Close compares the balance recomputed from the history with the stored balance of the user
+func (c *Chain) Close() *Issue {
+	if math.Abs(c.balance-c.user.Balance) > balanceTolerance {
+		return &Issue{
+			UserID: c.user.ID,
+			Reason: fmt.Sprintf("balance %v doesn't match %v recomputed from the history", c.user.Balance, c.balance),
+		}
+	}
+	return nil
+}
+
+func (c *Chain) issue(t *model.Transaction, reason string) *Issue {
+	return &Issue{UserID: c.user.ID, TransactionID: t.TransactionID, Reason: reason}
+}
+
+// Drift returns how much the stored balance of a check drifted from the history, 0 if the difference is within
+// the tolerance
+func Drift(c *model.BalanceCheck) float64 {
+	if d := c.Balance - c.Expected; math.Abs(d) > balanceTolerance {
+		return d
+	}
+	return 0
+}
@@ -0,0 +1,176 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+)
+
+// history returns a chained history of user u1 crediting each of amounts
+func history(amounts ...float64) []*model.Transaction {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 123456789, time.UTC)
+
+	prev := ""
+	res := make([]*model.Transaction, 0, len(amounts))
+	for i, a := range amounts {
+		t := &model.Transaction{
+			ID:            uint(i + 1),
+			CreatedAt:     Timestamp(created.Add(time.Duration(i) * time.Minute)),
+			Amount:        a,
+			TransactionID: "tx_" + string(rune('a'+i)),
+			UserID:        "u1",
+			PrevHash:      prev,
+		}
+		t.Hash = Hash(t)
+		prev = t.Hash
+		res = append(res, t)
+	}
+	return res
+}
+
+func verify(user *model.User, ts []*model.Transaction) []*Issue {
+	c := NewChain(user)
+
+	var issues []*Issue
+	for _, t := range ts {
+		if issue := c.Add(t); issue != nil {
+			issues = append(issues, issue)
+		}
+	}
+	if issue := c.Close(); issue != nil {
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+func TestHashIsStableAfterRoundTrip(t *testing.T) {
+	tx := &model.Transaction{
+		CreatedAt:     Timestamp(time.Date(2026, 1, 1, 0, 0, 0, 123456789, time.UTC)),
+		Amount:        10.123456789,
+		TransactionID: "tx_a",
+		UserID:        "u1",
+	}
+	h := Hash(tx)
+
+	// the db stores the amount as float4 and returns the time in its own zone
+	tx.Amount = float64(float32(tx.Amount))
+	tx.CreatedAt = tx.CreatedAt.In(time.FixedZone("+06", 6*60*60))
+
+	assert.Equal(t, h, Hash(tx))
+}
+
+func TestChainValid(t *testing.T) {
+	ts := history(10, 20.5, 5)
+
+	issues := verify(&model.User{ID: "u1", OpeningBalance: 100, Balance: 135.5}, ts)
+
+	assert.Empty(t, issues)
+}
+
+func TestChainModifiedTransaction(t *testing.T) {
+	ts := history(10, 20.5, 5)
+	ts[1].Amount = 2000
+
+	issues := verify(&model.User{ID: "u1", OpeningBalance: 100, Balance: 135.5}, ts)
+
+	assert.Len(t, issues, 2)
+	assert.Equal(t, "tx_b", issues[0].TransactionID)
+	assert.Contains(t, issues[0].Reason, "modified")
+	// the balance was not changed together with the history
+	assert.Empty(t, issues[1].TransactionID)
+}
+
+func TestChainModifiedFee(t *testing.T) {
+	ts := history(10, 20.5, 5)
+	ts[1].Fee = 0.41
+	ts[1].Hash = Hash(ts[1])
+	ts[2].PrevHash = ts[1].Hash
+	ts[2].Hash = Hash(ts[2])
+
+	for _, fee := range []float64{0, 4.1} {
+		ts[1].Fee = fee
+
+		issues := verify(&model.User{ID: "u1", OpeningBalance: 100, Balance: 135.5}, ts)
+
+		assert.Len(t, issues, 1)
+		assert.Equal(t, "tx_b", issues[0].TransactionID)
+		assert.Contains(t, issues[0].Reason, "modified")
+	}
+}
+
+func TestHashWithoutFee(t *testing.T) {
+	tx := &model.Transaction{
+		CreatedAt:     Timestamp(time.Date(2026, 1, 1, 0, 0, 0, 123456789, time.UTC)),
+		Amount:        10,
+		TransactionID: "tx_a",
+		UserID:        "u1",
+	}
+
+	// the hash of a transaction made before fees were introduced
+	assert.Equal(t, "74d5a3c65e5df6e74822fee11174f674ba124a40038842e0ea65c76bb1e720ef", Hash(tx))
+}
+
+func TestChainRemovedTransaction(t *testing.T) {
+	ts := history(10, 20.5, 5)
+	ts = append(ts[:1], ts[2:]...)
+
+	issues := verify(&model.User{ID: "u1", OpeningBalance: 100, Balance: 115}, ts)
+
+	assert.Len(t, issues, 1)
+	assert.Equal(t, "tx_c", issues[0].TransactionID)
+	assert.Contains(t, issues[0].Reason, "removed")
+}
+
+func TestChainBalanceMismatch(t *testing.T) {
+	issues := verify(&model.User{ID: "u1", OpeningBalance: 100, Balance: 1000}, history(10))
+
+	assert.Len(t, issues, 1)
+	assert.Equal(t, "user u1: balance 1000 doesn't match 110 recomputed from the history", issues[0].String())
+}
+
+func TestChainLegacyTransactions(t *testing.T) {
+	legacy := &model.Transaction{TransactionID: "tx_old", UserID: "u1", Amount: 1}
+	ts := append([]*model.Transaction{legacy}, history(10)...)
+
+	c := NewChain(&model.User{ID: "u1", Balance: 11})
+	for _, tx := range ts {
+		assert.Nil(t, c.Add(tx))
+	}
+	assert.Nil(t, c.Close())
+	assert.Equal(t, 1, c.Legacy)
+	assert.Equal(t, 1, c.Verified)
+
+	// unchained transactions after the chain started are not allowed
+	assert.NotNil(t, c.Add(&model.Transaction{TransactionID: "tx_new", UserID: "u1"}))
+}
+
+func TestInternal(t *testing.T) {
+	debit, credit := FeeTransactionIDs("tx_1")
+	assert.Equal(t, "fee_tx_1", debit)
+	assert.Equal(t, "fee_house_tx_1", credit)
+
+	assert.True(t, Internal(debit))
+	assert.True(t, Internal(credit))
+	assert.True(t, Internal(AdjustmentPrefix+"1"))
+	assert.False(t, Internal("tx_1"))
+}
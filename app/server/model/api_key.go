@@ -0,0 +1,34 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+type APIKey struct {
+	ID           uint         `db:"id" goqu:"skipinsert"`
+	KeyID        string       `db:"key_id"`
+	Name         string       `db:"name"`
+	HashedSecret string       `db:"hashed_secret"`
+	Role         string       `db:"role"`
+	Scopes       string       `db:"scopes"`
+	CreatedAt    time.Time    `db:"created_at"`
+	RevokedAt    sql.NullTime `db:"revoked_at"`
+}
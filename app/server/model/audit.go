@@ -0,0 +1,60 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// audit event outcomes
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeDenied  = "denied"
+	AuditOutcomeFailed  = "failed"
+)
+
+type AuditEvent struct {
+	ID        uint           `db:"id" goqu:"skipinsert"`
+	CreatedAt time.Time      `db:"created_at"`
+	RequestID sql.NullString `db:"request_id"`
+	ActorKind string         `db:"actor_kind"`
+	ActorID   string         `db:"actor_id"`
+	Action    string         `db:"action"`
+	Resource  string         `db:"resource"`
+	Outcome   string         `db:"outcome"`
+	SourceIP  sql.NullString `db:"source_ip"`
+	Details   string         `db:"details"`
+	// BeforeBalance and AfterBalance are set on the events which touch a balance
+	BeforeBalance sql.NullFloat64 `db:"before_balance"`
+	AfterBalance  sql.NullFloat64 `db:"after_balance"`
+	// Payload is the body of the request which made the change
+	Payload string `db:"payload"`
+}
+
+// AuditFilter narrows down the listed audit events. empty fields match everything
+type AuditFilter struct {
+	ActorKind string
+	ActorID   string
+	Action    string
+	Resource  string
+	Outcome   string
+	RequestID string
+	From      time.Time
+	To        time.Time
+}
@@ -18,6 +18,18 @@
 package model
 
 const (
-	TableUsers        = "users"
-	TableTransactions = "transactions"
+	TableUsers         = "users"
+	TableTransactions  = "transactions"
+	TableSnapshots     = "balance_snapshots"
+	TableAPIKeys       = "api_keys"
+	TableAuditEvents   = "audit_events"
+	TableRequestNonces = "request_nonces"
+	TableRateLimits    = "rate_limit_buckets"
+	TableOutboxEvents  = "outbox_events"
+	TableWebhooks      = "webhooks"
+	TableDeliveries    = "webhook_deliveries"
 )
+
+// ChannelTransactionPosted is the postgres notification channel a {"user_id", "id"} payload is sent to
+// when a transaction is committed
+const ChannelTransactionPosted = "transaction_posted"
@@ -21,12 +21,23 @@ import (
 	"time"
 )
 
+// user statuses
+const (
+	UserStatusActive = "active"
+	UserStatusFrozen = "frozen"
+)
+
 type User struct {
 	ID        string    `db:"id"`
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
 	Name      string    `db:"name"`
 	Balance   float64   `db:"balance"`
+	Status    string    `db:"status"`
+	// OpeningBalance is the balance before the first transaction of the user
+	OpeningBalance float64 `db:"opening_balance"`
+	// FeeSchedule is the name of the fee schedule of the user, the default schedule applies if it's empty
+	FeeSchedule string `db:"fee_schedule"`
 }
 
 type Transaction struct {
@@ -35,4 +46,46 @@ type Transaction struct {
 	Amount        float64   `db:"amount"`
 	TransactionID string    `db:"transaction_id"`
 	UserID        string    `db:"user_id"`
+	// PrevHash is the hash of the previous transaction of the user, empty for the first one
+	PrevHash string `db:"prev_hash"`
+	// Hash chains the transaction to the previous one, see ledger.Hash
+	Hash string `db:"hash"`
+	// Fee is the fee charged on a credit. the fee is debited by a transaction of its own
+	Fee float64 `db:"fee"`
+}
+
+// Fee is the fee charged on a credit, debited from the user and credited to the house account
+type Fee struct {
+	Amount       float64
+	Schedule     string
+	HouseAccount string
+}
+
+// GetAmount returns the amount of the fee, 0 if f is nil
+func (f *Fee) GetAmount() float64 {
+	if f == nil {
+		return 0
+	}
+	return f.Amount
+}
+
+// BalanceSnapshot is the balance of a user as of a point in time. the balance as of a later time is the balance of
+// the snapshot plus the transactions after TransactionSeq created until then
+type BalanceSnapshot struct {
+	ID     uint      `db:"id" goqu:"skipinsert"`
+	UserID string    `db:"user_id"`
+	AsOf   time.Time `db:"as_of"`
+	// TransactionSeq is the id of the last transaction of the user included in the balance, 0 if there is none
+	TransactionSeq uint      `db:"transaction_seq"`
+	Balance        float64   `db:"balance"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+// BalanceCheck is the stored balance of a user next to the balance recomputed from its opening balance and history
+type BalanceCheck struct {
+	UserID   string  `db:"user_id"`
+	Balance  float64 `db:"balance"`
+	Expected float64 `db:"expected"`
+	// TransactionSeq is the id of the last transaction of the user, 0 if there is none
+	TransactionSeq uint `db:"transaction_seq"`
 }
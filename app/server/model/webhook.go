@@ -0,0 +1,84 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// event types
+const (
+	EventBalanceCredited = "balance.credited"
+)
+
+// webhook delivery statuses
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	// DeliveryStatusDead is a delivery which failed every attempt. it's only retried if replayed
+	DeliveryStatusDead = "dead"
+)
+
+// OutboxEvent is an event written in the same db transaction as the change it describes
+type OutboxEvent struct {
+	ID           uint         `db:"id" goqu:"skipinsert"`
+	CreatedAt    time.Time    `db:"created_at"`
+	EventType    string       `db:"event_type"`
+	AggregateID  string       `db:"aggregate_id"`
+	Payload      string       `db:"payload"`
+	DispatchedAt sql.NullTime `db:"dispatched_at"`
+}
+
+type Webhook struct {
+	ID  uint   `db:"id" goqu:"skipinsert"`
+	URL string `db:"url"`
+	// Secret signs the deliveries
+	Secret string `db:"secret"`
+	// EventTypes is a space separated list of the subscribed event types, empty subscribes to every event
+	EventTypes string    `db:"event_types"`
+	Active     bool      `db:"active"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uint           `db:"id" goqu:"skipinsert"`
+	WebhookID      uint           `db:"webhook_id"`
+	EventID        uint           `db:"event_id"`
+	Status         string         `db:"status"`
+	Attempts       int            `db:"attempts"`
+	NextAttemptAt  time.Time      `db:"next_attempt_at"`
+	LastStatusCode sql.NullInt64  `db:"last_status_code"`
+	LastError      sql.NullString `db:"last_error"`
+	CreatedAt      time.Time      `db:"created_at"`
+	UpdatedAt      time.Time      `db:"updated_at"`
+	DeliveredAt    sql.NullTime   `db:"delivered_at"`
+}
+
+// DeliveryJob is a claimed delivery together with what's needed to send it
+type DeliveryJob struct {
+	ID        uint      `db:"id"`
+	Attempts  int       `db:"attempts"`
+	URL       string    `db:"url"`
+	Secret    string    `db:"secret"`
+	EventID   uint      `db:"event_id"`
+	EventType string    `db:"event_type"`
+	Payload   string    `db:"payload"`
+	CreatedAt time.Time `db:"created_at"`
+}
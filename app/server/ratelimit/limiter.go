@@ -0,0 +1,190 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+	"github.com/diptomondal007/your-money/infrastructure/logger"
+)
+
+const (
+	// DimensionKey limits every caller, identified by its api key or user, separately
+	DimensionKey = "key"
+	// DimensionUser limits every target user separately
+	DimensionUser = "user"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+)
+
+// Limiter limits the requests of the routes with token buckets
+type Limiter struct {
+	rules map[string][]config.RateLimitRule
+	store Store
+}
+
+// NewLimiter returns a new limiter of rules backed by store
+func NewLimiter(rules []config.RateLimitRule, store Store) *Limiter {
+	l := &Limiter{rules: map[string][]config.RateLimitRule{}, store: store}
+	for _, r := range rules {
+		l.rules[r.Route] = append(l.rules[r.Route], r)
+	}
+	return l
+}
+
+// status is the state of a bucket after a request
+type status struct {
+	rule    config.RateLimitRule
+	allowed bool
+	tokens  float64
+}
+
+// remaining returns the number of whole tokens left
+func (s status) remaining() int {
+	return int(math.Max(0, math.Floor(s.tokens)))
+}
+
+// reset returns the seconds until the bucket is full again
+func (s status) reset() int {
+	return int(math.Ceil((float64(s.rule.Burst) - s.tokens) / s.rule.Rate))
+}
+
+// retryAfter returns the seconds until a token is available
+func (s status) retryAfter() int {
+	return int(math.Max(1, math.Ceil((1-s.tokens)/s.rule.Rate)))
+}
+
+// Limit limits the requests of route. the target user is read from the path param. a nil limiter doesn't limit
+// anything. if the store fails the request is let through, an outage of the store should not take the api down
+func (l *Limiter) Limit(route, param string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if l == nil || len(l.rules[route]) == 0 {
+				return next(c)
+			}
+
+			tightest := l.take(c.Request().Context(), route, func(dimension string) string {
+				return l.subject(c, dimension, param)
+			})
+			if tightest == nil {
+				return next(c)
+			}
+
+			h := c.Response().Header()
+			h.Set(HeaderRateLimitLimit, strconv.Itoa(tightest.rule.Burst))
+			h.Set(HeaderRateLimitRemaining, strconv.Itoa(tightest.remaining()))
+			h.Set(HeaderRateLimitReset, strconv.Itoa(tightest.reset()))
+
+			if !tightest.allowed {
+				h.Set(echo.HeaderRetryAfter, strconv.Itoa(tightest.retryAfter()))
+				return response.SendError(c, response.ErrTooManyRequests)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// Allow takes a token of route for the caller of ctx and the target user. it returns false with the seconds to
+// wait before retrying once a bucket is empty. it's the Limit check of the transports other than http, callers
+// without a principal are identified by ip
+func (l *Limiter) Allow(ctx context.Context, route, userID, ip string) (bool, int) {
+	if l == nil || len(l.rules[route]) == 0 {
+		return true, 0
+	}
+
+	tightest := l.take(ctx, route, func(dimension string) string {
+		if dimension == DimensionUser {
+			return userID
+		}
+		if p, ok := auth.FromContext(ctx); ok {
+			return p.Kind + ":" + p.Subject
+		}
+		return "ip:" + ip
+	})
+	if tightest == nil || tightest.allowed {
+		return true, 0
+	}
+	return false, tightest.retryAfter()
+}
+
+// take takes a token from the bucket of every rule of route, subject returns who is limited by a dimension.
+// it returns the rejecting bucket, or else the one with the fewest tokens left, nil if the store failed for all.
+// a rejected request gets back the tokens it took from the other buckets, so it isn't counted by any of them
+func (l *Limiter) take(ctx context.Context, route string, subject func(dimension string) string) *status {
+	var (
+		tightest *status
+		taken    []config.RateLimitRule
+		keys     []string
+	)
+	for _, r := range l.rules[route] {
+		key := fmt.Sprintf("%s:%s:%s", route, r.Dimension, subject(r.Dimension))
+
+		allowed, tokens, err := l.store.Take(ctx, key, r.Rate, r.Burst)
+		if err != nil {
+			logger.FromContext(ctx).Error("rate limit store failed", slog.String("key", key), slog.Any("error", err))
+			continue
+		}
+
+		s := &status{rule: r, allowed: allowed, tokens: tokens}
+		if !allowed {
+			logger.FromContext(ctx).Warn("rate limited",
+				slog.String("route", route),
+				slog.String("dimension", r.Dimension),
+			)
+			for i, k := range keys {
+				if err := l.store.Refund(ctx, k, taken[i].Burst); err != nil {
+					logger.FromContext(ctx).Error("rate limit store failed", slog.String("key", k), slog.Any("error", err))
+				}
+			}
+			return s
+		}
+		taken = append(taken, r)
+		keys = append(keys, key)
+		if tightest == nil || s.remaining() < tightest.remaining() {
+			tightest = s
+		}
+	}
+	return tightest
+}
+
+// subject returns who is limited by the dimension. callers are identified by the authenticated principal
+// and fall back to the client ip
+func (l *Limiter) subject(c echo.Context, dimension, param string) string {
+	if dimension == DimensionUser {
+		return c.Param(param)
+	}
+
+	if p, ok := auth.GetPrincipal(c); ok {
+		return p.Kind + ":" + p.Subject
+	}
+	return "ip:" + c.RealIP()
+}
@@ -0,0 +1,164 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+func newLimitedServer(t *testing.T, rules string, store Store) *echo.Echo {
+	rs, err := config.ParseRateLimitRules(rules)
+	assert.NoError(t, err)
+
+	l := NewLimiter(rs, store)
+
+	e := echo.New()
+	e.GET("/users/:uid/balance", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, l.Limit("balance", "uid"))
+	return e
+}
+
+func get(e *echo.Echo, uid, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/users/"+uid+"/balance", nil)
+	req.Header.Set(echo.HeaderXRealIP, ip)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemoryStore()
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		ok, _, _ := m.Take(context.Background(), "k", 1, 2)
+		assert.True(t, ok)
+	}
+
+	ok, tokens, _ := m.Take(context.Background(), "k", 1, 2)
+	assert.False(t, ok)
+	assert.Equal(t, 0.0, tokens)
+
+	now = now.Add(1500 * time.Millisecond)
+	ok, tokens, _ = m.Take(context.Background(), "k", 1, 2)
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, tokens)
+
+	// the bucket never holds more than the burst
+	now = now.Add(time.Hour)
+	ok, tokens, _ = m.Take(context.Background(), "k", 1, 2)
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, tokens)
+}
+
+func TestLimitPerUser(t *testing.T) {
+	e := newLimitedServer(t, "balance:user=2/m", NewMemoryStore())
+
+	rec := get(e, "u1", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(HeaderRateLimitLimit))
+	assert.Equal(t, "1", rec.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, "30", rec.Header().Get(HeaderRateLimitReset))
+
+	assert.Equal(t, http.StatusOK, get(e, "u1", "10.0.0.2").Code)
+
+	rec = get(e, "u1", "10.0.0.3")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, "30", rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Contains(t, rec.Body.String(), `"status_code":429`)
+
+	// other users have their own bucket
+	assert.Equal(t, http.StatusOK, get(e, "u2", "10.0.0.1").Code)
+}
+
+func TestLimitPerCaller(t *testing.T) {
+	e := newLimitedServer(t, "balance:key=1/h,balance:user=10/s", NewMemoryStore())
+
+	rec := get(e, "u1", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	// the most restrictive bucket is reported
+	assert.Equal(t, "1", rec.Header().Get(HeaderRateLimitLimit))
+	assert.Equal(t, "0", rec.Header().Get(HeaderRateLimitRemaining))
+
+	rec = get(e, "u2", "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "3600", rec.Header().Get(echo.HeaderRetryAfter))
+
+	assert.Equal(t, http.StatusOK, get(e, "u2", "10.0.0.2").Code)
+}
+
+func TestLimitRefundsOnReject(t *testing.T) {
+	e := newLimitedServer(t, "balance:key=2/h,balance:user=1/h", NewMemoryStore())
+
+	assert.Equal(t, http.StatusOK, get(e, "u1", "10.0.0.1").Code)
+
+	// the rejected request doesn't use up the token of the caller
+	assert.Equal(t, http.StatusTooManyRequests, get(e, "u1", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get(e, "u1", "10.0.0.1").Code)
+
+	rec := get(e, "u2", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get(HeaderRateLimitRemaining))
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+func (failingStore) Refund(ctx context.Context, key string, burst int) error {
+	return errors.New("connection refused")
+}
+
+func TestLimitStoreFailureLetsThrough(t *testing.T) {
+	e := newLimitedServer(t, "balance:key=1/h", failingStore{})
+
+	for i := 0; i < 3; i++ {
+		rec := get(e, "u1", "10.0.0.1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get(HeaderRateLimitLimit))
+	}
+}
+
+func TestParseRateLimitRules(t *testing.T) {
+	rules, err := config.ParseRateLimitRules("add:key=10/s@20, history:user=60/m")
+	assert.NoError(t, err)
+	assert.Equal(t, []config.RateLimitRule{
+		{Route: "add", Dimension: DimensionKey, Rate: 10, Burst: 20},
+		{Route: "history", Dimension: DimensionUser, Rate: 1, Burst: 60},
+	}, rules)
+
+	for _, s := range []string{"add=10/s", "add:ip=10/s", "add:key=10", "add:key=0/s", "add:key=10/d", "add:key=10/s@0"} {
+		_, err := config.ParseRateLimitRules(s)
+		assert.Error(t, err, s)
+	}
+}
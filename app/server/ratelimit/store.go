@@ -0,0 +1,97 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store keeps the token buckets. Take takes a token from the bucket of the key refilled with rate tokens per
+// second up to burst. it returns whether the token was taken and the tokens left in the bucket. Refund puts a
+// taken token back
+type Store interface {
+	Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error)
+	Refund(ctx context.Context, key string, burst int) error
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// fullAt is the time when the bucket is full again, the bucket can be dropped after that
+	fullAt time.Time
+}
+
+// MemoryStore keeps the token buckets in memory. it's only suitable for a single instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore returns a new in-memory bucket store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Take takes a token from the bucket of the key
+func (m *MemoryStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) > time.Minute {
+		for k, b := range m.buckets {
+			if !b.fullAt.After(now) {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), updatedAt: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.updatedAt).Seconds()*rate)
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.fullAt = now.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+
+	return allowed, b.tokens, nil
+}
+
+// Refund puts a token back to the bucket of the key
+func (m *MemoryStore) Refund(ctx context.Context, key string, burst int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if b, ok := m.buckets[key]; ok {
+		b.tokens = math.Min(float64(burst), b.tokens+1)
+	}
+	return nil
+}
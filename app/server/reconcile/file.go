@@ -0,0 +1,203 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package reconcile matches the settlement files of the payment processor against the transactions of the ledger
+package reconcile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// the fields of a settlement line
+const (
+	FieldTransactionID = "transaction_id"
+	FieldAmount        = "amount"
+	FieldDate          = "date"
+)
+
+// Mapping names the columns of a settlement file holding the fields of a line
+type Mapping struct {
+	TransactionID string
+	Amount        string
+	Date          string
+}
+
+// ParseMapping parses a column mapping of the form transaction_id=<column>,amount=<column>,date=<column>.
+// every field must be mapped
+func ParseMapping(s string) (Mapping, error) {
+	var m Mapping
+	for _, part := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(part, "=")
+		field, column = strings.TrimSpace(field), strings.TrimSpace(column)
+		if !ok || column == "" {
+			return m, fmt.Errorf("invalid column mapping %q, expected field=column", part)
+		}
+
+		switch field {
+		case FieldTransactionID:
+			m.TransactionID = column
+		case FieldAmount:
+			m.Amount = column
+		case FieldDate:
+			m.Date = column
+		default:
+			return m, fmt.Errorf("unknown field %q in the column mapping", field)
+		}
+	}
+
+	for field, column := range map[string]string{FieldTransactionID: m.TransactionID, FieldAmount: m.Amount, FieldDate: m.Date} {
+		if column == "" {
+			return m, fmt.Errorf("field %s is not mapped to a column", field)
+		}
+	}
+	return m, nil
+}
+
+// String returns the mapping in the form parsed by ParseMapping
+func (m Mapping) String() string {
+	return fmt.Sprintf("%s=%s,%s=%s,%s=%s", FieldTransactionID, m.TransactionID, FieldAmount, m.Amount, FieldDate, m.Date)
+}
+
+// Format describes how a settlement file is read
+type Format struct {
+	Mapping Mapping
+	// DateLayout is the go time layout of the dates. dates without a zone are utc
+	DateLayout string
+	// Delimiter separates the columns
+	Delimiter rune
+}
+
+// ParseDelimiter returns the single character delimiter of s. `\t` and `tab` stand for a tab
+func ParseDelimiter(s string) (rune, error) {
+	if s == `\t` || s == "tab" {
+		return '\t', nil
+	}
+
+	r, n := utf8.DecodeRuneInString(s)
+	if n == 0 || n != len(s) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q, expected a single character", s)
+	}
+	return r, nil
+}
+
+// Line is a deposit listed in a settlement file
+type Line struct {
+	// Row is the line of the file the deposit starts at, the header is line 1
+	Row           int
+	TransactionID string
+	Amount        float64
+	Date          time.Time
+}
+
+// ReadLines reads the deposits of a settlement file. the first row is the header naming the columns, the columns
+// are looked up case-insensitively. blank lines are skipped
+func ReadLines(r io.Reader, f Format) ([]Line, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = f.Delimiter
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// files saved by spreadsheets may start with a byte order mark
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	index := func(column string) (int, error) {
+		i, ok := columns[strings.ToLower(column)]
+		if !ok {
+			return 0, fmt.Errorf("the file has no column %q", column)
+		}
+		return i, nil
+	}
+
+	var idx [3]int
+	for i, column := range []string{f.Mapping.TransactionID, f.Mapping.Amount, f.Mapping.Date} {
+		if idx[i], err = index(column); err != nil {
+			return nil, err
+		}
+	}
+
+	res := make([]Line, 0)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		row, _ := cr.FieldPos(0)
+
+		l, err := readLine(record, idx, f.DateLayout)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		l.Row = row
+		res = append(res, l)
+	}
+}
+
+// readLine reads the fields of a line from the columns idx of record
+func readLine(record []string, idx [3]int, layout string) (Line, error) {
+	var l Line
+
+	value := func(i int) string {
+		if i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	if l.TransactionID = value(idx[0]); l.TransactionID == "" {
+		return l, fmt.Errorf("transaction id is empty")
+	}
+
+	amount, err := strconv.ParseFloat(value(idx[1]), 64)
+	if err != nil {
+		return l, fmt.Errorf("amount %q is not a number", value(idx[1]))
+	}
+	l.Amount = amount
+
+	date, err := time.Parse(layout, value(idx[2]))
+	if err != nil {
+		return l, fmt.Errorf("date %q doesn't match the layout %s", value(idx[2]), layout)
+	}
+	l.Date = date.UTC()
+
+	return l, nil
+}
@@ -0,0 +1,99 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package reconcile
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMapping(t *testing.T) {
+	m, err := ParseMapping("transaction_id=Reference, amount=Net Amount,date=Settled")
+	require.NoError(t, err)
+	assert.Equal(t, Mapping{TransactionID: "Reference", Amount: "Net Amount", Date: "Settled"}, m)
+	assert.Equal(t, "transaction_id=Reference,amount=Net Amount,date=Settled", m.String())
+
+	for _, s := range []string{"", "transaction_id=ref,amount=amount", "transaction_id=ref,amount=amount,date=", "ref,amount=amount,date=date", "transaction_id=ref,amount=amount,date=date,fee=fee"} {
+		_, err := ParseMapping(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	for s, want := range map[string]rune{",": ',', ";": ';', `\t`: '\t', "tab": '\t', "|": '|'} {
+		d, err := ParseDelimiter(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, d, s)
+	}
+
+	for _, s := range []string{"", ",;", `"`, "\n"} {
+		_, err := ParseDelimiter(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	file := "\ufeffSettled;Reference;Net Amount;Fee\n" +
+		"2024-02-01;tx_1;10.50;0.1\n" +
+		"\n" +
+		"2024-02-02; tx_2 ;7;0\n"
+
+	f := Format{
+		Mapping:    Mapping{TransactionID: "reference", Amount: "net amount", Date: "SETTLED"},
+		DateLayout: "2006-01-02",
+		Delimiter:  ';',
+	}
+
+	lines, err := ReadLines(strings.NewReader(file), f)
+	require.NoError(t, err)
+	assert.Equal(t, []Line{
+		{Row: 2, TransactionID: "tx_1", Amount: 10.5, Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{Row: 4, TransactionID: "tx_2", Amount: 7, Date: time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+	}, lines)
+}
+
+func TestReadLinesInvalid(t *testing.T) {
+	f := Format{
+		Mapping:    Mapping{TransactionID: "id", Amount: "amount", Date: "date"},
+		DateLayout: time.RFC3339,
+		Delimiter:  ',',
+	}
+
+	tests := []struct {
+		name string
+		file string
+		err  string
+	}{
+		{name: "empty", file: "", err: "the file is empty"},
+		{name: "missing column", file: "id,amount\n", err: `the file has no column "date"`},
+		{name: "empty transaction id", file: "id,amount,date\n,1,2024-02-01T00:00:00Z\n", err: "row 2: transaction id is empty"},
+		{name: "invalid amount", file: "id,amount,date\ntx_1,1,2024-02-01T00:00:00Z\ntx_2,ten,2024-02-01T00:00:00Z\n", err: `row 3: amount "ten" is not a number`},
+		{name: "invalid date", file: "id,amount,date\ntx_1,1,2024-02-01\n", err: `row 2: date "2024-02-01" doesn't match the layout 2006-01-02T15:04:05Z07:00`},
+		{name: "missing field", file: "id,amount,date\ntx_1,1\n", err: `row 2: date "" doesn't match the layout 2006-01-02T15:04:05Z07:00`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadLines(strings.NewReader(tt.file), f)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package reconcile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+)
+
+// amountTolerance is the max difference between the amounts of a matched line and transaction.
+// the amounts are stored as float4, so they may differ from the file slightly
+const amountTolerance = 0.005
+
+// the statuses of an entry
+const (
+	// StatusMatched is a line matching a transaction by transaction id, amount and date
+	StatusMatched = "matched"
+	// StatusMissingInLedger is a line without a transaction, or whose transaction was posted outside the date tolerance
+	StatusMissingInLedger = "missing_in_ledger"
+	// StatusMissingInFile is a transaction of the period which no line matches
+	StatusMissingInFile = "missing_in_file"
+	// StatusAmountMismatch is a line whose transaction has another amount
+	StatusAmountMismatch = "amount_mismatch"
+	// StatusDuplicateInFile is a line repeating the transaction id of an earlier line
+	StatusDuplicateInFile = "duplicate_in_file"
+)
+
+// Window is the period of a settlement file. the transactions posted in the period are expected in the file
+type Window struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Contains reports whether t is in [From, To)
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.From) && t.Before(w.To)
+}
+
+// DefaultWindow returns the utc days from the day of the earliest line until the day of the latest line
+func DefaultWindow(lines []Line) Window {
+	var w Window
+	for i, l := range lines {
+		day := l.Date.UTC().Truncate(24 * time.Hour)
+		if i == 0 || day.Before(w.From) {
+			w.From = day
+		}
+		if end := day.Add(24 * time.Hour); end.After(w.To) {
+			w.To = end
+		}
+	}
+	return w
+}
+
+// Entry is the result of matching a line of the file or a transaction of the ledger
+type Entry struct {
+	Status string `json:"status"`
+	// Row is the row of the line in the file, 0 for a transaction missing in the file
+	Row           int        `json:"row,omitempty"`
+	TransactionID string     `json:"transaction_id"`
+	UserID        string     `json:"user_id,omitempty"`
+	FileAmount    *float64   `json:"file_amount,omitempty"`
+	LedgerAmount  *float64   `json:"ledger_amount,omitempty"`
+	FileDate      *time.Time `json:"file_date,omitempty"`
+	LedgerDate    *time.Time `json:"ledger_date,omitempty"`
+	Detail        string     `json:"detail,omitempty"`
+}
+
+// Summary counts the lines, the transactions and the entries of a report by status
+type Summary struct {
+	Lines           int `json:"lines"`
+	Transactions    int `json:"transactions"`
+	Matched         int `json:"matched"`
+	MissingInLedger int `json:"missing_in_ledger"`
+	MissingInFile   int `json:"missing_in_file"`
+	AmountMismatch  int `json:"amount_mismatch"`
+	DuplicateInFile int `json:"duplicate_in_file"`
+}
+
+// Discrepancies returns the number of entries which aren't matched
+func (s Summary) Discrepancies() int {
+	return s.MissingInLedger + s.MissingInFile + s.AmountMismatch + s.DuplicateInFile
+}
+
+// Report is the result of a reconciliation. the entries of the lines come first in the order of the file, followed
+// by the transactions missing in the file in the order of posting
+type Report struct {
+	Window    Window        `json:"window"`
+	Tolerance time.Duration `json:"-"`
+	Summary   Summary       `json:"summary"`
+	Entries   []Entry       `json:"entries"`
+}
+
+// Match matches the lines of a file against the transactions of the ledger by transaction id. a line matches
+// if its amount equals the amount of the transaction and its date is within tolerance of the posting. txs must
+// hold the transactions of the transaction ids of the lines and the transactions posted in the window
+func Match(lines []Line, txs []*model.Transaction, w Window, tolerance time.Duration) *Report {
+	r := &Report{Window: w, Tolerance: tolerance, Entries: make([]Entry, 0, len(lines))}
+	r.Summary.Lines = len(lines)
+
+	byTxID := make(map[string]*model.Transaction, len(txs))
+	for _, t := range txs {
+		byTxID[t.TransactionID] = t
+		if w.Contains(t.CreatedAt) {
+			r.Summary.Transactions++
+		}
+	}
+
+	claimed := map[string]int{}
+	for _, l := range lines {
+		e := Entry{Row: l.Row, TransactionID: l.TransactionID, FileAmount: ptr(l.Amount), FileDate: ptr(l.Date)}
+
+		t, ok := byTxID[l.TransactionID]
+		if ok {
+			e.UserID = t.UserID
+			e.LedgerAmount = ptr(t.Amount)
+			e.LedgerDate = ptr(t.CreatedAt)
+		}
+
+		switch row, dup := claimed[l.TransactionID]; {
+		case dup:
+			e.Status = StatusDuplicateInFile
+			e.Detail = fmt.Sprintf("transaction id is listed in row %d already", row)
+		case !ok:
+			e.Status = StatusMissingInLedger
+			e.Detail = "no transaction with the transaction id"
+		case absDuration(t.CreatedAt.Sub(l.Date)) > tolerance:
+			e.Status = StatusMissingInLedger
+			e.Detail = fmt.Sprintf("transaction was posted %s from the date, outside the tolerance of %s", absDuration(t.CreatedAt.Sub(l.Date)), tolerance)
+		case math.Abs(t.Amount-l.Amount) > amountTolerance:
+			e.Status = StatusAmountMismatch
+			e.Detail = fmt.Sprintf("amounts differ by %s", formatAmount(l.Amount-t.Amount))
+		default:
+			e.Status = StatusMatched
+		}
+
+		if e.Status != StatusDuplicateInFile {
+			claimed[l.TransactionID] = l.Row
+		}
+		r.add(e)
+	}
+
+	missing := make([]*model.Transaction, 0)
+	for _, t := range txs {
+		if _, ok := claimed[t.TransactionID]; !ok && w.Contains(t.CreatedAt) {
+			missing = append(missing, t)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].ID < missing[j].ID })
+
+	for _, t := range missing {
+		r.add(Entry{
+			Status:        StatusMissingInFile,
+			TransactionID: t.TransactionID,
+			UserID:        t.UserID,
+			LedgerAmount:  ptr(t.Amount),
+			LedgerDate:    ptr(t.CreatedAt),
+			Detail:        "no line with the transaction id",
+		})
+	}
+	return r
+}
+
+// add appends e to the entries and counts it
+func (r *Report) add(e Entry) {
+	switch e.Status {
+	case StatusMatched:
+		r.Summary.Matched++
+	case StatusMissingInLedger:
+		r.Summary.MissingInLedger++
+	case StatusMissingInFile:
+		r.Summary.MissingInFile++
+	case StatusAmountMismatch:
+		r.Summary.AmountMismatch++
+	case StatusDuplicateInFile:
+		r.Summary.DuplicateInFile++
+	}
+	r.Entries = append(r.Entries, e)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
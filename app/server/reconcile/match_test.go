@@ -0,0 +1,105 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package reconcile
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+)
+
+var day = time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+func TestDefaultWindow(t *testing.T) {
+	w := DefaultWindow([]Line{
+		{Date: day.Add(30 * time.Hour)},
+		{Date: day.Add(5 * time.Hour)},
+	})
+	assert.Equal(t, Window{From: day, To: day.Add(48 * time.Hour)}, w)
+	assert.True(t, w.Contains(day))
+	assert.False(t, w.Contains(day.Add(48*time.Hour)))
+}
+
+func TestMatch(t *testing.T) {
+	txs := []*model.Transaction{
+		{ID: 1, TransactionID: "tx_matched", UserID: "u1", Amount: 10, CreatedAt: day.Add(10 * time.Hour)},
+		{ID: 2, TransactionID: "tx_amount", UserID: "u1", Amount: 5, CreatedAt: day.Add(11 * time.Hour)},
+		{ID: 3, TransactionID: "tx_late", UserID: "u2", Amount: 3, CreatedAt: day.Add(-72 * time.Hour)},
+		{ID: 4, TransactionID: "tx_not_settled", UserID: "u2", Amount: 8, CreatedAt: day.Add(12 * time.Hour)},
+		// posted after the period, expected in the next file
+		{ID: 5, TransactionID: "tx_next_day", UserID: "u2", Amount: 1, CreatedAt: day.Add(25 * time.Hour)},
+	}
+	lines := []Line{
+		{Row: 2, TransactionID: "tx_matched", Amount: 10.001, Date: day},
+		{Row: 3, TransactionID: "tx_amount", Amount: 6, Date: day},
+		{Row: 4, TransactionID: "tx_unknown", Amount: 1, Date: day},
+		{Row: 5, TransactionID: "tx_late", Amount: 3, Date: day},
+		{Row: 6, TransactionID: "tx_matched", Amount: 10, Date: day},
+	}
+
+	r := Match(lines, txs, Window{From: day, To: day.Add(24 * time.Hour)}, 24*time.Hour)
+
+	assert.Equal(t, Summary{Lines: 5, Transactions: 3, Matched: 1, MissingInLedger: 2, MissingInFile: 1, AmountMismatch: 1, DuplicateInFile: 1}, r.Summary)
+	assert.Equal(t, 5, r.Summary.Discrepancies())
+
+	statuses := make([]string, 0, len(r.Entries))
+	for _, e := range r.Entries {
+		statuses = append(statuses, e.Status+" "+e.TransactionID)
+	}
+	assert.Equal(t, []string{
+		"matched tx_matched",
+		"amount_mismatch tx_amount",
+		"missing_in_ledger tx_unknown",
+		"missing_in_ledger tx_late",
+		"duplicate_in_file tx_matched",
+		"missing_in_file tx_not_settled",
+	}, statuses)
+
+	assert.Equal(t, "u1", r.Entries[1].UserID)
+	assert.Equal(t, "amounts differ by 1.00", r.Entries[1].Detail)
+	assert.Equal(t, "transaction was posted 72h0m0s from the date, outside the tolerance of 24h0m0s", r.Entries[3].Detail)
+	assert.Equal(t, "transaction id is listed in row 2 already", r.Entries[4].Detail)
+	assert.Nil(t, r.Entries[5].FileAmount)
+	assert.Equal(t, float64(8), *r.Entries[5].LedgerAmount)
+}
+
+func TestReportOutput(t *testing.T) {
+	txs := []*model.Transaction{
+		{ID: 1, TransactionID: "tx_1", UserID: "u1", Amount: 10, CreatedAt: day.Add(time.Hour)},
+		{ID: 2, TransactionID: "tx_2", UserID: "u1", Amount: 2, CreatedAt: day.Add(2 * time.Hour)},
+	}
+	r := Match([]Line{{Row: 2, TransactionID: "tx_1", Amount: 10, Date: day}}, txs, Window{From: day, To: day.Add(24 * time.Hour)}, time.Hour)
+
+	var text bytes.Buffer
+	require.NoError(t, r.Print(&text))
+	assert.Contains(t, text.String(), "reconciled 1 lines against 2 transactions posted from 2024-02-01T00:00:00Z until 2024-02-02T00:00:00Z, date tolerance 1h0m0s")
+	assert.Contains(t, text.String(), "missing in file: 1\n")
+	assert.Regexp(t, `missing_in_file +- +tx_2 +u1 +- +2\.00 +no line with the transaction id`, text.String())
+	assert.NotRegexp(t, `matched +2`, text.String())
+
+	var csv bytes.Buffer
+	require.NoError(t, r.WriteCSV(&csv))
+	assert.Equal(t, "status,row,transaction_id,user_id,file_amount,ledger_amount,file_date,ledger_date,detail\n"+
+		"matched,2,tx_1,u1,10.00,10.00,2024-02-01T00:00:00Z,2024-02-01T01:00:00Z,\n"+
+		"missing_in_file,,tx_2,u1,,2.00,,2024-02-01T02:00:00Z,no line with the transaction id\n", csv.String())
+}
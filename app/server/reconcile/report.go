@@ -0,0 +1,114 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package reconcile
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// csvHeader is the header of the csv form of a report
+var csvHeader = []string{"status", "row", "transaction_id", "user_id", "file_amount", "ledger_amount", "file_date", "ledger_date", "detail"}
+
+// Print writes the summary and the discrepancies of the report in a human readable form
+func (r *Report) Print(w io.Writer) error {
+	s := r.Summary
+	fmt.Fprintf(w, "reconciled %d lines against %d transactions posted from %s until %s, date tolerance %s\n\n",
+		s.Lines, s.Transactions, r.Window.From.Format(time.RFC3339), r.Window.To.Format(time.RFC3339), r.Tolerance)
+	fmt.Fprintf(w, "matched: %d\nmissing in ledger: %d\nmissing in file: %d\namount mismatch: %d\nduplicate in file: %d\n",
+		s.Matched, s.MissingInLedger, s.MissingInFile, s.AmountMismatch, s.DuplicateInFile)
+
+	if s.Discrepancies() == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tROW\tTRANSACTION ID\tUSER\tFILE AMOUNT\tLEDGER AMOUNT\tDETAIL")
+	for _, e := range r.Entries {
+		if e.Status == StatusMatched {
+			continue
+		}
+
+		row := "-"
+		if e.Row > 0 {
+			row = strconv.Itoa(e.Row)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Status, row, e.TransactionID, orDash(e.UserID),
+			formatAmountPtr(e.FileAmount), formatAmountPtr(e.LedgerAmount), e.Detail)
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes every entry of the report as a csv row, ex - to be opened in a spreadsheet
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, e := range r.Entries {
+		row := ""
+		if e.Row > 0 {
+			row = strconv.Itoa(e.Row)
+		}
+
+		record := []string{e.Status, row, e.TransactionID, e.UserID, "", "", "", "", e.Detail}
+		if e.FileAmount != nil {
+			record[4] = formatAmount(*e.FileAmount)
+		}
+		if e.LedgerAmount != nil {
+			record[5] = formatAmount(*e.LedgerAmount)
+		}
+		if e.FileDate != nil {
+			record[6] = e.FileDate.Format(time.RFC3339Nano)
+		}
+		if e.LedgerDate != nil {
+			record[7] = e.LedgerDate.Format(time.RFC3339Nano)
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatAmount(a float64) string {
+	return fmt.Sprintf("%.2f", a)
+}
+
+func formatAmountPtr(a *float64) string {
+	if a == nil {
+		return "-"
+	}
+	return formatAmount(*a)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,167 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+var (
+	// ErrAPIKeyNotFound is returned when no api key exists with the given key id
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+// apiKeyRepository ...
+type apiKeyRepository struct {
+	db *sqlx.DB
+}
+
+// APIKeyRepository ...
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *model.APIKey) error
+	GetAPIKey(ctx context.Context, keyID string) (*model.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]*model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID string) error
+}
+
+// NewAPIKeyRepo returns a new api key repo instance
+func NewAPIKeyRepo(db *sqlx.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+// CreateAPIKey stores a new api key and records it to the audit log. the secret is never audited
+func (a apiKeyRepository) CreateAPIKey(ctx context.Context, key *model.APIKey) (err error) {
+	details := map[string]interface{}{"name": key.Name, "role": key.Role, "scopes": key.Scopes}
+	defer func() {
+		if err != nil {
+			auditFailure(ctx, a.db, audit.ActionAPIKeyCreate, "api_key:"+key.KeyID, details, err)
+		}
+	}()
+
+	tx, err := a.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q, _, err := goqu.Insert(goqu.T(model.TableAPIKeys)).Rows(key).ToSQL()
+	if err != nil {
+		return err
+	}
+
+	if err = exec(ctx, tx, "insert api key", q); err != nil {
+		return err
+	}
+
+	ev := audit.NewEvent(ctx, audit.ActionAPIKeyCreate, "api_key:"+key.KeyID, model.AuditOutcomeSuccess, details)
+	if err = appendAuditEvent(ctx, tx, ev); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetAPIKey fetches an api key by its public key id
+func (a apiKeyRepository) GetAPIKey(ctx context.Context, keyID string) (*model.APIKey, error) {
+	key := &model.APIKey{}
+
+	q, _, err := goqu.From(goqu.T(model.TableAPIKeys).As("k")).
+		Select("k.*").
+		Where(goqu.Ex{"key_id": goqu.Op{"eq": keyID}}).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := get(ctx, a.db, "get api key", key, q); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, response.WrapError(ErrAPIKeyNotFound, http.StatusNotFound, response.CodeAPIKeyNotFound)
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns all the api keys including the revoked ones
+func (a apiKeyRepository) ListAPIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	res := make([]*model.APIKey, 0)
+
+	q, _, err := goqu.From(goqu.T(model.TableAPIKeys).As("k")).
+		Select("k.*").
+		Order(goqu.I("k.id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = selectAll(ctx, a.db, "list api keys", &res, q); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RevokeAPIKey marks an api key as revoked and records it to the audit log. revoking an already revoked key is a no-op
+func (a apiKeyRepository) RevokeAPIKey(ctx context.Context, keyID string) (err error) {
+	defer func() {
+		if err != nil {
+			auditFailure(ctx, a.db, audit.ActionAPIKeyRevoke, "api_key:"+keyID, map[string]interface{}{}, err)
+		}
+	}()
+
+	key, err := a.GetAPIKey(ctx, keyID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q, _, err := goqu.Update(model.TableAPIKeys).
+		Set(goqu.Record{"revoked_at": time.Now().UTC()}).
+		Where(goqu.Ex{"key_id": goqu.Op{"eq": keyID}, "revoked_at": goqu.Op{"is": nil}}).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+
+	if err = exec(ctx, tx, "revoke api key", q); err != nil {
+		return err
+	}
+
+	ev := audit.NewEvent(ctx, audit.ActionAPIKeyRevoke, "api_key:"+keyID, model.AuditOutcomeSuccess,
+		map[string]interface{}{"name": key.Name, "already_revoked": key.RevokedAt.Valid})
+	if err = appendAuditEvent(ctx, tx, ev); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,93 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/utils"
+)
+
+func TestGetAPIKey(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ar := NewAPIKeyRepo(db)
+
+	query := `SELECT "k".* FROM "api_keys" AS "k" WHERE ("key_id" = '0a1b2c3d4e5f6071')`
+
+	rows := sqlmock.NewRows([]string{"id", "key_id", "name", "hashed_secret", "created_at", "revoked_at"}).
+		AddRow(1, "0a1b2c3d4e5f6071", "payments", "hash", time.Now().UTC(), nil)
+
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows)
+
+	key, err := ar.GetAPIKey(context.Background(), "0a1b2c3d4e5f6071")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "payments", key.Name)
+	assert.False(t, key.RevokedAt.Valid)
+}
+
+func TestRevokeAPIKeyNotFound(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ar := NewAPIKeyRepo(db)
+
+	query := `SELECT "k".* FROM "api_keys" AS "k" WHERE ("key_id" = 'unknown')`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(`INSERT INTO "audit_events" .* VALUES \('api_key\.revoke', 'system', 'system', .*api key not found.*'failed'`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := ar.RevokeAPIKey(context.Background(), "unknown")
+
+	assert.True(t, errors.Is(err, ErrAPIKeyNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateAPIKeyAudited(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ar := NewAPIKeyRepo(db)
+
+	key := &model.APIKey{KeyID: "0a1b2c3d4e5f6071", Name: "payments", HashedSecret: "secret-hash", Role: "payments", CreatedAt: time.Now().UTC()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "api_keys"`)).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "audit_events" .* VALUES \('api_key\.create', 'ops', 'cli', NULL, NULL, .*"name":"payments","role":"payments".*'success', '', NULL, 'api_key:0a1b2c3d4e5f6071', NULL\)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	ctx := audit.WithCall(context.Background(), audit.Call{ActorKind: audit.ActorKindCLI, ActorID: "ops"})
+
+	err := ar.CreateAPIKey(ctx, key)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,170 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/logger"
+)
+
+// exportBatchSize is the number of audit events fetched at once while exporting
+const exportBatchSize = 500
+
+// auditRepository ...
+type auditRepository struct {
+	db *sqlx.DB
+}
+
+// AuditRepository ...
+type AuditRepository interface {
+	AppendAuditEvent(ctx context.Context, ev *model.AuditEvent) error
+	ListAuditEvents(ctx context.Context, filter model.AuditFilter, pageSize int64, cursor string) ([]*model.AuditEvent, error)
+	ExportAuditEvents(ctx context.Context, filter model.AuditFilter, fn func(ev *model.AuditEvent) error) error
+}
+
+// NewAuditRepo returns a new audit repo instance
+func NewAuditRepo(db *sqlx.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// AppendAuditEvent stores a new audit event. audit events are never updated or deleted
+func (a auditRepository) AppendAuditEvent(ctx context.Context, ev *model.AuditEvent) error {
+	return appendAuditEvent(ctx, a.db, ev)
+}
+
+// ListAuditEvents returns the audit events matching filter, newest first
+func (a auditRepository) ListAuditEvents(ctx context.Context, filter model.AuditFilter, pageSize int64, cursor string) ([]*model.AuditEvent, error) {
+	res := make([]*model.AuditEvent, 0)
+
+	d := filterAuditEvents(filter)
+
+	if cursor != "" {
+		c, err := response.ParseCursor(cursor)
+		if err != nil {
+			return res, response.WrapError(fmt.Errorf("invalid pagination cursor"), http.StatusBadRequest, response.CodeInvalidCursor)
+		}
+
+		d = d.Where(goqu.Ex{"id": goqu.Op{"lt": c.ID}})
+	}
+
+	q, _, err := d.Order(goqu.I("a.id").Desc()).Limit(uint(pageSize)).ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = selectAll(ctx, a.db, "list audit events", &res, q); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ExportAuditEvents calls fn for every audit event matching filter, oldest first
+func (a auditRepository) ExportAuditEvents(ctx context.Context, filter model.AuditFilter, fn func(ev *model.AuditEvent) error) error {
+	var lastID uint
+	for {
+		q, _, err := filterAuditEvents(filter).
+			Where(goqu.Ex{"id": goqu.Op{"gt": lastID}}).
+			Order(goqu.I("a.id").Asc()).
+			Limit(exportBatchSize).
+			ToSQL()
+		if err != nil {
+			return err
+		}
+
+		evs := make([]*model.AuditEvent, 0, exportBatchSize)
+		if err = selectAll(ctx, a.db, "export audit events", &evs, q); err != nil {
+			return err
+		}
+
+		for _, ev := range evs {
+			if err := fn(ev); err != nil {
+				return err
+			}
+			lastID = ev.ID
+		}
+
+		if len(evs) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// filterAuditEvents returns the query of the audit events matching filter
+func filterAuditEvents(filter model.AuditFilter) *goqu.SelectDataset {
+	d := goqu.From(goqu.T(model.TableAuditEvents).As("a")).Select("a.*")
+
+	ex := goqu.Ex{}
+	for col, v := range map[string]string{
+		"actor_kind": filter.ActorKind,
+		"actor_id":   filter.ActorID,
+		"action":     filter.Action,
+		"resource":   filter.Resource,
+		"outcome":    filter.Outcome,
+		"request_id": filter.RequestID,
+	} {
+		if v != "" {
+			ex[col] = v
+		}
+	}
+	if len(ex) > 0 {
+		d = d.Where(ex)
+	}
+
+	if !filter.From.IsZero() {
+		d = d.Where(goqu.Ex{"created_at": goqu.Op{"gte": filter.From}})
+	}
+	if !filter.To.IsZero() {
+		d = d.Where(goqu.Ex{"created_at": goqu.Op{"lt": filter.To}})
+	}
+	return d
+}
+
+// appendAuditEvent stores an audit event with e, so that it can be part of the transaction making the change
+func appendAuditEvent(ctx context.Context, e sqlx.ExecerContext, ev *model.AuditEvent) error {
+	q, _, err := goqu.Insert(goqu.T(model.TableAuditEvents)).Rows(ev).ToSQL()
+	if err != nil {
+		return err
+	}
+
+	return exec(ctx, e, "insert audit event", q)
+}
+
+// auditFailure records a mutation which failed. the transaction of the mutation is rolled back by then,
+// so the event is stored on its own
+func auditFailure(ctx context.Context, db *sqlx.DB, action, resource string, details map[string]interface{}, cause error) {
+	details["error"] = cause.Error()
+
+	ev := audit.NewEvent(ctx, action, resource, model.AuditOutcomeFailed, details)
+	if err := appendAuditEvent(ctx, db, ev); err != nil {
+		logger.FromContext(ctx).Error("failed to record failed mutation",
+			slog.String("action", action),
+			slog.String("resource", resource),
+			slog.Any("error", err),
+		)
+	}
+}
@@ -0,0 +1,83 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/utils"
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+func TestListAuditEvents(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ar := NewAuditRepo(db)
+
+	query := `SELECT "a".* FROM "audit_events" AS "a" WHERE ((("action" = 'balance.credit') AND ("resource" = 'user:u1')) AND ("created_at" >= '2026-01-01T00:00:00Z') AND ("id" < 42)) ORDER BY "a"."id" DESC LIMIT 10`
+
+	rows := sqlmock.NewRows([]string{"id", "action", "resource", "outcome", "before_balance", "after_balance"}).
+		AddRow(41, "balance.credit", "user:u1", "success", 100, 110)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows)
+
+	filter := model.AuditFilter{Action: "balance.credit", Resource: "user:u1", From: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	evs, err := ar.ListAuditEvents(context.Background(), filter, 10, (&response.Cursor{ID: 42}).ToBase64String())
+
+	assert.NoError(t, err)
+	assert.Len(t, evs, 1)
+	assert.Equal(t, 110.0, evs[0].AfterBalance.Float64)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportAuditEvents(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ar := NewAuditRepo(db)
+
+	rows := sqlmock.NewRows([]string{"id", "action"})
+	for i := 1; i <= exportBatchSize; i++ {
+		rows.AddRow(i, "balance.credit")
+	}
+
+	query := `SELECT "a".* FROM "audit_events" AS "a" WHERE (("actor_id" = 'payments') AND ("id" > 0)) ORDER BY "a"."id" ASC LIMIT 500`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows)
+
+	query = `SELECT "a".* FROM "audit_events" AS "a" WHERE (("actor_id" = 'payments') AND ("id" > 500)) ORDER BY "a"."id" ASC LIMIT 500`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"id", "action"}).AddRow(501, "user.status_change"))
+
+	var ids []uint
+	err := ar.ExportAuditEvents(context.Background(), model.AuditFilter{ActorID: "payments"}, func(ev *model.AuditEvent) error {
+		ids = append(ids, ev.ID)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, ids, exportBatchSize+1)
+	assert.Equal(t, uint(501), ids[exportBatchSize])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,73 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+)
+
+// nonceRepository ...
+type nonceRepository struct {
+	db *sqlx.DB
+}
+
+// NonceRepository ...
+type NonceRepository interface {
+	UseNonce(ctx context.Context, keyID, nonce string, expiresAt time.Time) (bool, error)
+	DeleteExpiredNonces(ctx context.Context, now time.Time) (int64, error)
+}
+
+// NewNonceRepo returns a new nonce repo instance
+func NewNonceRepo(db *sqlx.DB) NonceRepository {
+	return &nonceRepository{db: db}
+}
+
+// UseNonce records the nonce of a key. it returns false if the nonce was already used
+func (n nonceRepository) UseNonce(ctx context.Context, keyID, nonce string, expiresAt time.Time) (bool, error) {
+	q, _, err := goqu.Insert(goqu.T(model.TableRequestNonces)).
+		Rows(goqu.Record{"key_id": keyID, "nonce": nonce, "expires_at": expiresAt}).
+		OnConflict(goqu.DoNothing()).
+		ToSQL()
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := execAffected(ctx, n.db, "insert nonce", q)
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// DeleteExpiredNonces removes the nonces which can't be replayed anymore
+func (n nonceRepository) DeleteExpiredNonces(ctx context.Context, now time.Time) (int64, error) {
+	q, _, err := goqu.Delete(goqu.T(model.TableRequestNonces)).
+		Where(goqu.Ex{"expires_at": goqu.Op{"lt": now}}).
+		ToSQL()
+	if err != nil {
+		return 0, err
+	}
+
+	return execAffected(ctx, n.db, "delete expired nonces", q)
+}
@@ -0,0 +1,51 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/app/utils"
+)
+
+func TestUseNonce(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	nr := NewNonceRepo(db)
+
+	query := `INSERT INTO "request_nonces" ("expires_at", "key_id", "nonce") VALUES ('2026-01-01T00:05:00Z', 'payments', 'n1') ON CONFLICT DO NOTHING`
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	expiresAt := time.Date(2026, 1, 1, 0, 5, 0, 0, time.UTC)
+
+	fresh, err := nr.UseNonce(context.Background(), "payments", "n1", expiresAt)
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+
+	fresh, err = nr.UseNonce(context.Background(), "payments", "n1", expiresAt)
+	assert.NoError(t, err)
+	assert.False(t, fresh)
+}
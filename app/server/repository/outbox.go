@@ -0,0 +1,59 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+)
+
+// BalanceCredited is the payload of the balance.credited event
+type BalanceCredited struct {
+	UserID        string  `json:"user_id"`
+	TransactionID string  `json:"transaction_id"`
+	Amount        float64 `json:"amount"`
+	// Fee is the fee charged on the credit, the balance is net of it
+	Fee     float64 `json:"fee,omitempty"`
+	Balance float64 `json:"balance"`
+}
+
+// appendOutboxEvent writes an event to the outbox with e, so that it's published only if the transaction making the change commits
+func appendOutboxEvent(ctx context.Context, e sqlx.ExecerContext, eventType, aggregateID string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	q, _, err := goqu.Insert(goqu.T(model.TableOutboxEvents)).Rows(model.OutboxEvent{
+		CreatedAt:   time.Now().UTC(),
+		EventType:   eventType,
+		AggregateID: aggregateID,
+		Payload:     string(payload),
+	}).ToSQL()
+	if err != nil {
+		return err
+	}
+
+	return exec(ctx, e, "insert outbox event", q)
+}
@@ -0,0 +1,103 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/diptomondal007/your-money/infrastructure/tracing"
+)
+
+// startQuerySpan starts a client span for a single sql statement run by db
+func startQuerySpan(ctx context.Context, db interface{}, name, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			dbSystem(db),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// dbSystem returns the db system of a connection pool or a transaction by its driver
+func dbSystem(db interface{}) attribute.KeyValue {
+	if d, ok := db.(interface{ DriverName() string }); ok && d.DriverName() == "sqlite3" {
+		return semconv.DBSystemNameSQLite
+	}
+	return semconv.DBSystemNamePostgreSQL
+}
+
+// endQuerySpan ends the span of a statement. no rows is not considered as a failure
+func endQuerySpan(span trace.Span, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+// isUniqueViolation reports whether err is the violation of a unique index of postgres
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation"
+}
+
+// get runs a single row query in its own span
+func get(ctx context.Context, q sqlx.QueryerContext, name string, dest interface{}, query string) error {
+	ctx, span := startQuerySpan(ctx, q, name, query)
+	err := sqlx.GetContext(ctx, q, dest, query)
+	endQuerySpan(span, err)
+	return err
+}
+
+// selectAll runs a multi row query in its own span
+func selectAll(ctx context.Context, q sqlx.QueryerContext, name string, dest interface{}, query string) error {
+	ctx, span := startQuerySpan(ctx, q, name, query)
+	err := sqlx.SelectContext(ctx, q, dest, query)
+	endQuerySpan(span, err)
+	return err
+}
+
+// exec runs a statement in its own span
+func exec(ctx context.Context, e sqlx.ExecerContext, name string, query string) error {
+	ctx, span := startQuerySpan(ctx, e, name, query)
+	_, err := e.ExecContext(ctx, query)
+	endQuerySpan(span, err)
+	return err
+}
+
+// execAffected runs a statement in its own span and returns the number of affected rows
+func execAffected(ctx context.Context, e sqlx.ExecerContext, name string, query string) (int64, error) {
+	ctx, span := startQuerySpan(ctx, e, name, query)
+	res, err := e.ExecContext(ctx, query)
+	if err != nil {
+		endQuerySpan(span, err)
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	endQuerySpan(span, err)
+	return n, err
+}
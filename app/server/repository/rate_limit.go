@@ -0,0 +1,118 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+)
+
+// rateLimitRepository ...
+type rateLimitRepository struct {
+	db *sqlx.DB
+}
+
+// RateLimitRepository ...
+type RateLimitRepository interface {
+	Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error)
+	Refund(ctx context.Context, key string, burst int) error
+	DeleteIdleBuckets(ctx context.Context, before time.Time) (int64, error)
+}
+
+// NewRateLimitRepo returns a new rate limit repo instance
+func NewRateLimitRepo(db *sqlx.DB) RateLimitRepository {
+	return &rateLimitRepository{db: db}
+}
+
+// refill returns the token level of a bucket refilled until now, capped to the burst.
+// the clock of the database is used so that every instance sees the same time
+func refill(tokens, updatedAt exp.IdentifierExpression, rate float64, burst int) exp.LiteralExpression {
+	return goqu.L("LEAST(?, ? + EXTRACT(EPOCH FROM (NOW() - ?)) * ?)", float64(burst), tokens, updatedAt, rate)
+}
+
+// Take takes a token from the bucket of the key in a single statement. it returns whether the token was taken
+// and the tokens left in the bucket
+func (r rateLimitRepository) Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error) {
+	level := refill(goqu.T(model.TableRateLimits).Col("tokens"), goqu.T(model.TableRateLimits).Col("updated_at"), rate, burst)
+
+	q, _, err := goqu.Insert(goqu.T(model.TableRateLimits)).
+		Rows(goqu.Record{"key": key, "tokens": float64(burst - 1), "updated_at": goqu.L("NOW()")}).
+		OnConflict(goqu.DoUpdate("key", goqu.Record{"tokens": goqu.L("? - 1", level), "updated_at": goqu.L("NOW()")}).
+			Where(goqu.L("? >= 1", level))).
+		Returning("tokens").
+		ToSQL()
+	if err != nil {
+		return false, 0, err
+	}
+
+	var tokens float64
+	err = get(ctx, r.db, "take rate limit token", &tokens, q)
+	if err == nil {
+		return true, tokens, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, 0, err
+	}
+
+	// the bucket is empty, the current level is needed to tell the caller when to retry
+	q, _, err = goqu.From(goqu.T(model.TableRateLimits)).
+		Select(refill(goqu.C("tokens"), goqu.C("updated_at"), rate, burst)).
+		Where(goqu.Ex{"key": key}).
+		ToSQL()
+	if err != nil {
+		return false, 0, err
+	}
+
+	if err := get(ctx, r.db, "get rate limit level", &tokens, q); err != nil {
+		return false, 0, err
+	}
+	return false, tokens, nil
+}
+
+// Refund puts a token back to the bucket of the key
+func (r rateLimitRepository) Refund(ctx context.Context, key string, burst int) error {
+	q, _, err := goqu.Update(goqu.T(model.TableRateLimits)).
+		Set(goqu.Record{"tokens": goqu.L("LEAST(?, tokens + 1)", float64(burst))}).
+		Where(goqu.Ex{"key": key}).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+
+	return exec(ctx, r.db, "refund rate limit token", q)
+}
+
+// DeleteIdleBuckets removes the buckets which were not used since before. they are full again anyway
+func (r rateLimitRepository) DeleteIdleBuckets(ctx context.Context, before time.Time) (int64, error) {
+	q, _, err := goqu.Delete(goqu.T(model.TableRateLimits)).
+		Where(goqu.Ex{"updated_at": goqu.Op{"lt": before}}).
+		ToSQL()
+	if err != nil {
+		return 0, err
+	}
+
+	return execAffected(ctx, r.db, "delete idle rate limit buckets", q)
+}
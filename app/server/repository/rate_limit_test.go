@@ -0,0 +1,69 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/app/utils"
+)
+
+func TestTakeRateLimitToken(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	rr := NewRateLimitRepo(db)
+
+	take := `INSERT INTO "rate_limit_buckets" ("key", "tokens", "updated_at") VALUES ('add:user:u1', 19, NOW()) ON CONFLICT (key) DO UPDATE SET "tokens"=LEAST(20, "rate_limit_buckets"."tokens" + EXTRACT(EPOCH FROM (NOW() - "rate_limit_buckets"."updated_at")) * 2.5) - 1,"updated_at"=NOW() WHERE LEAST(20, "rate_limit_buckets"."tokens" + EXTRACT(EPOCH FROM (NOW() - "rate_limit_buckets"."updated_at")) * 2.5) >= 1 RETURNING "tokens"`
+	level := `SELECT LEAST(20, "tokens" + EXTRACT(EPOCH FROM (NOW() - "updated_at")) * 2.5) FROM "rate_limit_buckets" WHERE ("key" = 'add:user:u1')`
+
+	mock.ExpectQuery(regexp.QuoteMeta(take)).WillReturnRows(sqlmock.NewRows([]string{"tokens"}).AddRow(7.5))
+
+	ok, tokens, err := rr.Take(context.Background(), "add:user:u1", 2.5, 20)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 7.5, tokens)
+
+	mock.ExpectQuery(regexp.QuoteMeta(take)).WillReturnRows(sqlmock.NewRows([]string{"tokens"}))
+	mock.ExpectQuery(regexp.QuoteMeta(level)).WillReturnRows(sqlmock.NewRows([]string{"least"}).AddRow(0.25))
+
+	ok, tokens, err = rr.Take(context.Background(), "add:user:u1", 2.5, 20)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 0.25, tokens)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefundRateLimitToken(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	rr := NewRateLimitRepo(db)
+
+	query := `UPDATE "rate_limit_buckets" SET "tokens"=LEAST(20, tokens + 1) WHERE ("key" = 'add:user:u1')`
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, rr.Refund(context.Background(), "add:user:u1", 20))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
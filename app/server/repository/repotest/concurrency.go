@@ -0,0 +1,304 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repotest
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diptomondal007/your-money/app/server/ledger"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+const (
+	// concurrentCredits is the number of credits made at once to a single user
+	concurrentCredits = 1000
+	// racedTransactions is the number of transaction ids raced for, each by racers callers
+	racedTransactions = 100
+	racers            = 10
+	// pagedCredits is the number of credits made while the history is paged
+	pagedCredits = 300
+)
+
+// Concurrency runs the concurrency tests of the user repositories against the repositories of newRepo. every
+// scenario is followed by a check of the invariants of the users it wrote to. the repositories must allow at least
+// a few concurrent calls, the callers exceeding a connection limit are expected to wait
+func Concurrency(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, f *fixture)
+	}{
+		{name: "concurrent credits to one user", fn: testConcurrentCreditsToOneUser},
+		{name: "duplicate transaction id races", fn: testDuplicateTransactionIDRaces},
+		{name: "pagination while writing", fn: testPaginationWhileWriting},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newFixture(t, newRepo))
+		})
+	}
+}
+
+func testConcurrentCreditsToOneUser(t *testing.T, f *fixture) {
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentCredits; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := f.repo.AddBalance(f.ctx, f.a.ID, f.tx(strconv.Itoa(i)), float64(i%7+1), nil)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	ts := checkInvariants(t, f, f.a)
+	assert.Equal(t, concurrentCredits, len(ts))
+}
+
+func testDuplicateTransactionIDRaces(t *testing.T, f *fixture) {
+	// every transaction id is raced for by callers crediting both users
+	var succeeded [racedTransactions]atomic.Int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < racedTransactions; i++ {
+		for j := 0; j < racers; j++ {
+			wg.Add(1)
+			go func(i, j int) {
+				defer wg.Done()
+
+				user := f.a
+				if j%2 == 1 {
+					user = f.b
+				}
+
+				_, err := f.repo.AddBalance(f.ctx, user.ID, f.tx(strconv.Itoa(i)), 1, nil)
+				if err == nil {
+					succeeded[i].Add(1)
+					return
+				}
+				assert.ErrorIs(t, err, repository.ErrTransactionProcessed)
+			}(i, j)
+		}
+	}
+	wg.Wait()
+
+	for i := range succeeded {
+		assert.Equal(t, int32(1), succeeded[i].Load(), "transaction id %s was processed %d times", f.tx(strconv.Itoa(i)), succeeded[i].Load())
+	}
+
+	ts := append(checkInvariants(t, f, f.a), checkInvariants(t, f, f.b)...)
+	assert.Equal(t, racedTransactions, len(ts))
+}
+
+func testPaginationWhileWriting(t *testing.T, f *fixture) {
+	done := make(chan struct{})
+
+	// the credits are made by a few writers, so they are committed out of the order they were started in
+	var writers sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := w; i < pagedCredits; i += 4 {
+				_, err := f.repo.AddBalance(f.ctx, f.a.ID, f.tx(strconv.Itoa(i)), 1, nil)
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+
+	var (
+		mu    sync.Mutex
+		walks [][]uint
+	)
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				walk, err := walkHistory(f, f.a.ID, 7)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				mu.Lock()
+				walks = append(walks, walk)
+				mu.Unlock()
+
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		}()
+	}
+
+	// the tail follows the history oldest first, like the balance events
+	var tail []uint
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		var last uint
+		for {
+			// the tail is caught up only if it's empty after the writers were done
+			finished := false
+			select {
+			case <-done:
+				finished = true
+			default:
+			}
+
+			ts, err := f.repo.ListTransactionsAfter(f.ctx, f.a.ID, last, 5)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if len(ts) == 0 && finished {
+				return
+			}
+
+			for _, tr := range ts {
+				tail = append(tail, tr.ID)
+				last = tr.ID
+			}
+		}
+	}()
+
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	ts := checkInvariants(t, f, f.a)
+	require.Equal(t, pagedCredits, len(ts))
+
+	ids := make([]uint, len(ts))
+	for i, tr := range ts {
+		ids[i] = tr.ID
+	}
+	assert.Equal(t, ids, tail, "the tail skipped or repeated transactions")
+
+	// a walk sees every transaction committed before its first page, newest first. the later ones are above its cursor
+	require.NotEmpty(t, walks)
+	for _, walk := range walks {
+		var want []uint
+		if len(walk) > 0 {
+			for i := len(ids) - 1; i >= 0; i-- {
+				if ids[i] <= walk[0] {
+					want = append(want, ids[i])
+				}
+			}
+		}
+		assert.Equal(t, want, walk, "a walk of the history skipped or repeated transactions")
+	}
+}
+
+// walkHistory returns the ids of the history of a user paged by pageSize, newest first
+func walkHistory(f *fixture, userID string, pageSize int64) ([]uint, error) {
+	var ids []uint
+	cursor := ""
+	for {
+		ts, err := f.repo.GetHistoryList(f.ctx, userID, pageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		if len(ts) == 0 {
+			return ids, nil
+		}
+
+		for _, tr := range ts {
+			ids = append(ids, tr.ID)
+		}
+		cursor = (&response.Cursor{ID: ts[len(ts)-1].ID}).ToBase64String()
+	}
+}
+
+// checkInvariants checks the history of user once the writes are done and returns it oldest first:
+//   - the balance equals the opening balance plus the sum of the transactions, at the last transaction as well
+//   - the ids and the transaction ids are unique, the ids grow in the order of the chain
+//   - every transaction is chained to the previous one
+//   - the count and the pages of the history hold every transaction exactly once
+func checkInvariants(t *testing.T, f *fixture, user *model.User) []*model.Transaction {
+	t.Helper()
+
+	var ts []*model.Transaction
+	err := f.repo.ExportTransactions(f.ctx, user.ID, func(tr *model.Transaction) error {
+		ts = append(ts, tr)
+		return nil
+	})
+	require.NoError(t, err)
+
+	sum := user.OpeningBalance
+	prev := ""
+	ids := map[uint]bool{}
+	txIDs := map[string]bool{}
+	for i, tr := range ts {
+		assert.False(t, ids[tr.ID], "duplicate id %d", tr.ID)
+		assert.False(t, txIDs[tr.TransactionID], "duplicate transaction id %s", tr.TransactionID)
+		ids[tr.ID] = true
+		txIDs[tr.TransactionID] = true
+
+		if i > 0 {
+			assert.Greater(t, tr.ID, ts[i-1].ID, "ids are not increasing")
+		}
+		assert.Equal(t, user.ID, tr.UserID)
+		assert.Equal(t, prev, tr.PrevHash, "transaction %s is not chained to the previous one", tr.TransactionID)
+		assert.Equal(t, ledger.Hash(tr), tr.Hash, "hash of transaction %s doesn't match", tr.TransactionID)
+
+		prev = tr.Hash
+		sum += tr.Amount
+	}
+
+	u, err := f.repo.GetUserInfo(f.ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sum, u.Balance, "balance of %s is not the sum of its history", user.ID)
+
+	var last uint
+	if len(ts) > 0 {
+		last = ts[len(ts)-1].ID
+	}
+
+	balance, err := f.repo.GetBalanceAt(f.ctx, user.ID, last)
+	require.NoError(t, err)
+	assert.Equal(t, sum, balance)
+
+	lastID, err := f.repo.GetLastTransactionID(f.ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, last, lastID)
+
+	count, err := f.repo.GetHistoryCount(f.ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(ts)), count)
+
+	walk, err := walkHistory(f, user.ID, 9)
+	require.NoError(t, err)
+
+	want := make([]uint, len(ts))
+	for i, tr := range ts {
+		want[len(ts)-1-i] = tr.ID
+	}
+	assert.Equal(t, want, walk, "the history of %s skipped or repeated transactions", user.ID)
+
+	return ts
+}
@@ -0,0 +1,640 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package repotest holds the conformance tests every implementation of the repositories must pass
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diptomondal007/your-money/app/server/ledger"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+// Factory returns a user repository holding users. the repository may hold other users as well
+type Factory func(t *testing.T, users ...*model.User) repository.UserRepository
+
+// UserRepository runs the conformance tests of the user repositories against the repositories of newRepo.
+// the ids of the users and the transactions are unique per run, so the tests may share a db
+func UserRepository(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, f *fixture)
+	}{
+		{name: "get user", fn: testGetUser},
+		{name: "create user", fn: testCreateUser},
+		{name: "add balance", fn: testAddBalance},
+		{name: "add balance chains transactions", fn: testAddBalanceChain},
+		{name: "add balance to unknown user", fn: testAddBalanceUnknownUser},
+		{name: "add balance with fee", fn: testAddBalanceWithFee},
+		{name: "set fee schedule", fn: testSetFeeSchedule},
+		{name: "duplicate transaction id", fn: testDuplicateTransactionID},
+		{name: "frozen user", fn: testFrozenUser},
+		{name: "history pagination", fn: testHistoryPagination},
+		{name: "invalid cursor", fn: testInvalidCursor},
+		{name: "list users", fn: testListUsers},
+		{name: "export transactions", fn: testExportTransactions},
+		{name: "transactions after", fn: testTransactionsAfter},
+		{name: "balance at", fn: testBalanceAt},
+		{name: "balance as of", fn: testBalanceAsOf},
+		{name: "balance as of snapshot", fn: testBalanceAsOfSnapshot},
+		{name: "find transactions", fn: testFindTransactions},
+		{name: "transactions posted", fn: testTransactionsPosted},
+		{name: "check balances", fn: testCheckBalances},
+		{name: "record adjustment", fn: testRecordAdjustment},
+		{name: "concurrent credits", fn: testConcurrentCredits},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newFixture(t, newRepo))
+		})
+	}
+}
+
+// fixture is a repository holding the users a, b and frozen
+type fixture struct {
+	repo repository.UserRepository
+	ctx  context.Context
+	run  string
+	a    *model.User
+	b    *model.User
+}
+
+func newFixture(t *testing.T, newRepo Factory) *fixture {
+	f := &fixture{ctx: context.Background(), run: strconv.FormatInt(time.Now().UnixNano(), 36)}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	f.a = &model.User{ID: f.id("a"), CreatedAt: now, UpdatedAt: now, Name: "A", Balance: 100, OpeningBalance: 100, Status: model.UserStatusActive}
+	f.b = &model.User{ID: f.id("b"), CreatedAt: now, UpdatedAt: now, Name: "B", Balance: 0, Status: model.UserStatusActive}
+	frozen := &model.User{ID: f.id("frozen"), CreatedAt: now, UpdatedAt: now, Name: "Frozen", Balance: 10, OpeningBalance: 10, Status: model.UserStatusFrozen}
+
+	f.repo = newRepo(t, f.a, f.b, frozen)
+	return f
+}
+
+// id returns the user id of name, unique to the run
+func (f *fixture) id(name string) string {
+	return fmt.Sprintf("repotest-%s-%s", f.run, name)
+}
+
+// tx returns the transaction id of name, unique to the run
+func (f *fixture) tx(name string) string {
+	return fmt.Sprintf("tx_%s_%s", f.run, name)
+}
+
+// credit credits the amounts to a user, each with its own transaction id
+func (f *fixture) credit(t *testing.T, userID string, amounts ...float64) {
+	for i, amount := range amounts {
+		_, err := f.repo.AddBalance(f.ctx, userID, f.tx(fmt.Sprintf("%s-%d", userID, i)), amount, nil)
+		require.NoError(t, err)
+	}
+}
+
+// verify asserts the history of a user is chained and sums up to its balance
+func (f *fixture) verify(t *testing.T, userID string) {
+	t.Helper()
+
+	user, err := f.repo.GetUserInfo(f.ctx, userID)
+	require.NoError(t, err)
+	chain := ledger.NewChain(user)
+	require.NoError(t, f.repo.ExportTransactions(f.ctx, userID, func(t *model.Transaction) error {
+		if issue := chain.Add(t); issue != nil {
+			return fmt.Errorf("%s", issue)
+		}
+		return nil
+	}))
+	assert.Nil(t, chain.Close())
+}
+
+// requireStatus asserts err is a response error with the status and the error code
+func requireStatus(t *testing.T, err error, status int, code string) {
+	t.Helper()
+
+	var wrapErr response.WrapErr
+	require.True(t, errors.As(err, &wrapErr), "not a response error: %v", err)
+	assert.Equal(t, status, wrapErr.StatusCode)
+	assert.Equal(t, code, wrapErr.ErrCode)
+}
+
+func testGetUser(t *testing.T, f *fixture) {
+	u, err := f.repo.GetUserInfo(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, f.a.ID, u.ID)
+	assert.Equal(t, "A", u.Name)
+	assert.Equal(t, float64(100), u.Balance)
+	assert.Equal(t, model.UserStatusActive, u.Status)
+
+	_, err = f.repo.GetUserInfo(f.ctx, f.id("unknown"))
+	requireStatus(t, err, http.StatusNotFound, response.CodeUserNotFound)
+}
+
+func testCreateUser(t *testing.T, f *fixture) {
+	now := time.Now().UTC().Truncate(time.Second)
+	user := &model.User{ID: f.id("new"), CreatedAt: now, UpdatedAt: now, Name: "New", Balance: 50, OpeningBalance: 50, Status: model.UserStatusActive}
+
+	require.NoError(t, f.repo.CreateUser(f.ctx, user))
+
+	u, err := f.repo.GetUserInfo(f.ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "New", u.Name)
+	assert.Equal(t, float64(50), u.Balance)
+	assert.Equal(t, float64(50), u.OpeningBalance)
+	assert.Equal(t, model.UserStatusActive, u.Status)
+
+	u, err = f.repo.AddBalance(f.ctx, user.ID, f.tx("1"), 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, float64(60), u.Balance)
+
+	// the existing user is kept
+	err = f.repo.CreateUser(f.ctx, &model.User{ID: f.a.ID, CreatedAt: now, UpdatedAt: now, Name: "Other", Status: model.UserStatusActive})
+	assert.ErrorIs(t, err, repository.ErrUserExists)
+	requireStatus(t, err, http.StatusConflict, response.CodeConflict)
+
+	a, err := f.repo.GetUserInfo(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "A", a.Name)
+	assert.Equal(t, float64(100), a.Balance)
+}
+
+func testAddBalance(t *testing.T, f *fixture) {
+	u, err := f.repo.AddBalance(f.ctx, f.a.ID, f.tx("1"), 25, nil)
+	require.NoError(t, err)
+	assert.Equal(t, float64(125), u.Balance)
+
+	u, err = f.repo.GetUserInfo(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(125), u.Balance)
+
+	count, err := f.repo.GetHistoryCount(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	ts, err := f.repo.GetHistoryList(f.ctx, f.a.ID, 10, "")
+	require.NoError(t, err)
+	require.Len(t, ts, 1)
+	assert.Equal(t, f.tx("1"), ts[0].TransactionID)
+	assert.Equal(t, f.a.ID, ts[0].UserID)
+	assert.Equal(t, float64(25), ts[0].Amount)
+	assert.False(t, ts[0].CreatedAt.IsZero())
+}
+
+func testAddBalanceWithFee(t *testing.T, f *fixture) {
+	now := time.Now().UTC().Truncate(time.Second)
+	house := &model.User{ID: f.id("house"), CreatedAt: now, UpdatedAt: now, Name: "House", Balance: 5, OpeningBalance: 5, Status: model.UserStatusActive}
+	require.NoError(t, f.repo.CreateUser(f.ctx, house))
+
+	fee := &model.Fee{Amount: 1.5, Schedule: "standard", HouseAccount: house.ID}
+	u, err := f.repo.AddBalance(f.ctx, f.b.ID, f.tx("1"), 50, fee)
+	require.NoError(t, err)
+	assert.Equal(t, 48.5, u.Balance)
+
+	// the credit is followed by the fee debit
+	ts, err := f.repo.GetHistoryList(f.ctx, f.b.ID, 10, "")
+	require.NoError(t, err)
+	require.Len(t, ts, 2)
+	debitID, creditID := ledger.FeeTransactionIDs(f.tx("1"))
+	assert.Equal(t, debitID, ts[0].TransactionID)
+	assert.Equal(t, -1.5, ts[0].Amount)
+	assert.Equal(t, f.tx("1"), ts[1].TransactionID)
+	assert.Equal(t, float64(50), ts[1].Amount)
+	assert.Equal(t, 1.5, ts[1].Fee)
+
+	h, err := f.repo.GetUserInfo(f.ctx, house.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6.5, h.Balance)
+
+	ts, err = f.repo.GetHistoryList(f.ctx, house.ID, 10, "")
+	require.NoError(t, err)
+	require.Len(t, ts, 1)
+	assert.Equal(t, creditID, ts[0].TransactionID)
+	assert.Equal(t, 1.5, ts[0].Amount)
+
+	f.verify(t, f.b.ID)
+	f.verify(t, house.ID)
+
+	// a fee to an unknown house account fails the credit
+	_, err = f.repo.AddBalance(f.ctx, f.b.ID, f.tx("2"), 50, &model.Fee{Amount: 1, HouseAccount: f.id("unknown")})
+	require.Error(t, err)
+	u, err = f.repo.GetUserInfo(f.ctx, f.b.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 48.5, u.Balance)
+}
+
+func testSetFeeSchedule(t *testing.T, f *fixture) {
+	u, err := f.repo.SetFeeSchedule(f.ctx, f.a.ID, "premium")
+	require.NoError(t, err)
+	assert.Equal(t, "premium", u.FeeSchedule)
+
+	u, err = f.repo.GetUserInfo(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "premium", u.FeeSchedule)
+
+	u, err = f.repo.SetFeeSchedule(f.ctx, f.a.ID, "")
+	require.NoError(t, err)
+	assert.Empty(t, u.FeeSchedule)
+
+	_, err = f.repo.SetFeeSchedule(f.ctx, f.id("unknown"), "premium")
+	requireStatus(t, err, http.StatusNotFound, response.CodeUserNotFound)
+}
+
+func testAddBalanceChain(t *testing.T, f *fixture) {
+	f.credit(t, f.a.ID, 1, 2, 3)
+
+	var prev string
+	err := f.repo.ExportTransactions(f.ctx, f.a.ID, func(tr *model.Transaction) error {
+		assert.Equal(t, prev, tr.PrevHash)
+		assert.Equal(t, ledger.Hash(tr), tr.Hash)
+		prev = tr.Hash
+		return nil
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, prev)
+}
+
+func testAddBalanceUnknownUser(t *testing.T, f *fixture) {
+	_, err := f.repo.AddBalance(f.ctx, f.id("unknown"), f.tx("1"), 10, nil)
+	requireStatus(t, err, http.StatusNotFound, response.CodeUserNotFound)
+}
+
+func testDuplicateTransactionID(t *testing.T, f *fixture) {
+	_, err := f.repo.AddBalance(f.ctx, f.a.ID, f.tx("1"), 10, nil)
+	require.NoError(t, err)
+
+	// transaction ids are unique across users
+	for _, userID := range []string{f.a.ID, f.b.ID} {
+		_, err = f.repo.AddBalance(f.ctx, userID, f.tx("1"), 10, nil)
+		assert.ErrorIs(t, err, repository.ErrTransactionProcessed)
+		requireStatus(t, err, http.StatusUnprocessableEntity, response.CodeTransactionProcessed)
+	}
+
+	a, err := f.repo.GetUserInfo(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(110), a.Balance)
+
+	b, err := f.repo.GetUserInfo(f.ctx, f.b.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), b.Balance)
+}
+
+func testFrozenUser(t *testing.T, f *fixture) {
+	_, err := f.repo.AddBalance(f.ctx, f.id("frozen"), f.tx("1"), 10, nil)
+	assert.ErrorIs(t, err, repository.ErrUserFrozen)
+	requireStatus(t, err, http.StatusUnprocessableEntity, response.CodeUserFrozen)
+
+	u, err := f.repo.SetUserStatus(f.ctx, f.id("frozen"), model.UserStatusActive)
+	require.NoError(t, err)
+	assert.Equal(t, model.UserStatusActive, u.Status)
+
+	// the rejected transaction id was not used up
+	u, err = f.repo.AddBalance(f.ctx, f.id("frozen"), f.tx("1"), 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, float64(20), u.Balance)
+
+	_, err = f.repo.SetUserStatus(f.ctx, f.id("unknown"), model.UserStatusFrozen)
+	requireStatus(t, err, http.StatusNotFound, response.CodeUserNotFound)
+}
+
+func testHistoryPagination(t *testing.T, f *fixture) {
+	f.credit(t, f.a.ID, 1, 2, 3, 4, 5)
+	f.credit(t, f.b.ID, 100)
+
+	var amounts []float64
+	cursor := ""
+	for {
+		ts, err := f.repo.GetHistoryList(f.ctx, f.a.ID, 2, cursor)
+		require.NoError(t, err)
+		if len(ts) == 0 {
+			break
+		}
+		require.LessOrEqual(t, len(ts), 2)
+
+		for _, tr := range ts {
+			amounts = append(amounts, tr.Amount)
+		}
+		cursor = (&response.Cursor{ID: ts[len(ts)-1].ID}).ToBase64String()
+	}
+
+	// newest first, every transaction exactly once
+	assert.Equal(t, []float64{5, 4, 3, 2, 1}, amounts)
+
+	count, err := f.repo.GetHistoryCount(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+}
+
+func testInvalidCursor(t *testing.T, f *fixture) {
+	_, err := f.repo.GetHistoryList(f.ctx, f.a.ID, 10, "not a cursor")
+	requireStatus(t, err, http.StatusBadRequest, response.CodeInvalidCursor)
+}
+
+func testListUsers(t *testing.T, f *fixture) {
+	us, err := f.repo.ListUsers(f.ctx, f.id("a"), 2)
+	require.NoError(t, err)
+	require.Len(t, us, 2)
+	assert.Equal(t, f.id("b"), us[0].ID)
+	assert.Equal(t, f.id("frozen"), us[1].ID)
+
+	us, err = f.repo.ListUsers(f.ctx, f.id("b"), 1)
+	require.NoError(t, err)
+	require.Len(t, us, 1)
+	assert.Equal(t, f.id("frozen"), us[0].ID)
+}
+
+func testExportTransactions(t *testing.T, f *fixture) {
+	f.credit(t, f.a.ID, 1, 2, 3)
+
+	var amounts []float64
+	err := f.repo.ExportTransactions(f.ctx, f.a.ID, func(tr *model.Transaction) error {
+		amounts = append(amounts, tr.Amount)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 2, 3}, amounts)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = f.repo.ExportTransactions(f.ctx, f.a.ID, func(tr *model.Transaction) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+func testTransactionsAfter(t *testing.T, f *fixture) {
+	last, err := f.repo.GetLastTransactionID(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), last)
+
+	f.credit(t, f.a.ID, 1, 2, 3)
+	f.credit(t, f.b.ID, 100)
+
+	all, err := f.repo.ListTransactionsAfter(f.ctx, f.a.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+
+	last, err = f.repo.GetLastTransactionID(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, all[2].ID, last)
+
+	ts, err := f.repo.ListTransactionsAfter(f.ctx, f.a.ID, all[0].ID, 1)
+	require.NoError(t, err)
+	require.Len(t, ts, 1)
+	assert.Equal(t, float64(2), ts[0].Amount)
+
+	ts, err = f.repo.ListTransactionsAfter(f.ctx, f.a.ID, last, 10)
+	require.NoError(t, err)
+	assert.Empty(t, ts)
+}
+
+func testBalanceAt(t *testing.T, f *fixture) {
+	f.credit(t, f.a.ID, 1, 2, 3)
+
+	ts, err := f.repo.ListTransactionsAfter(f.ctx, f.a.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, ts, 3)
+
+	balance, err := f.repo.GetBalanceAt(f.ctx, f.a.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, float64(100), balance)
+
+	balance, err = f.repo.GetBalanceAt(f.ctx, f.a.ID, ts[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(103), balance)
+
+	balance, err = f.repo.GetBalanceAt(f.ctx, f.a.ID, ts[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(106), balance)
+
+	_, err = f.repo.GetBalanceAt(f.ctx, f.id("unknown"), 0)
+	requireStatus(t, err, http.StatusNotFound, response.CodeUserNotFound)
+}
+
+func testBalanceAsOf(t *testing.T, f *fixture) {
+	f.credit(t, f.a.ID, 1, 2)
+
+	ts, err := f.repo.ListTransactionsAfter(f.ctx, f.a.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, ts, 2)
+
+	time.Sleep(2 * time.Millisecond)
+	_, err = f.repo.AddBalance(f.ctx, f.a.ID, f.tx("late"), 4, nil)
+	require.NoError(t, err)
+
+	s, err := f.repo.GetBalanceAsOf(f.ctx, f.a.ID, ts[0].CreatedAt.Add(-time.Microsecond))
+	require.NoError(t, err)
+	assert.Equal(t, float64(100), s.Balance)
+	assert.Equal(t, uint(0), s.TransactionSeq)
+
+	s, err = f.repo.GetBalanceAsOf(f.ctx, f.a.ID, ts[1].CreatedAt)
+	require.NoError(t, err)
+	assert.Equal(t, float64(103), s.Balance)
+	assert.Equal(t, ts[1].ID, s.TransactionSeq)
+
+	s, err = f.repo.GetBalanceAsOf(f.ctx, f.a.ID, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, float64(107), s.Balance)
+
+	_, err = f.repo.GetBalanceAsOf(f.ctx, f.id("unknown"), time.Now())
+	requireStatus(t, err, http.StatusNotFound, response.CodeUserNotFound)
+}
+
+func testBalanceAsOfSnapshot(t *testing.T, f *fixture) {
+	f.credit(t, f.a.ID, 1, 2)
+
+	asOf := time.Now()
+	s, err := f.repo.GetBalanceAsOf(f.ctx, f.a.ID, asOf)
+	require.NoError(t, err)
+	assert.Equal(t, float64(103), s.Balance)
+
+	ok, err := f.repo.SaveBalanceSnapshot(f.ctx, s)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = f.repo.SaveBalanceSnapshot(f.ctx, &model.BalanceSnapshot{UserID: f.a.ID, AsOf: asOf, Balance: 1})
+	require.NoError(t, err)
+	assert.False(t, ok, "a second snapshot as of the same time is saved")
+
+	time.Sleep(2 * time.Millisecond)
+	_, err = f.repo.AddBalance(f.ctx, f.a.ID, f.tx("late"), 4, nil)
+	require.NoError(t, err)
+
+	s, err = f.repo.GetBalanceAsOf(f.ctx, f.a.ID, asOf)
+	require.NoError(t, err)
+	assert.Equal(t, float64(103), s.Balance)
+
+	s, err = f.repo.GetBalanceAsOf(f.ctx, f.a.ID, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, float64(107), s.Balance)
+
+	// the balance as of a later time starts from the snapshot instead of the history
+	ok, err = f.repo.SaveBalanceSnapshot(f.ctx, &model.BalanceSnapshot{UserID: f.b.ID, AsOf: asOf, Balance: 50})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = f.repo.AddBalance(f.ctx, f.b.ID, f.tx("b-late"), 5, nil)
+	require.NoError(t, err)
+
+	s, err = f.repo.GetBalanceAsOf(f.ctx, f.b.ID, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, float64(55), s.Balance)
+}
+
+func testFindTransactions(t *testing.T, f *fixture) {
+	f.credit(t, f.a.ID, 1, 2)
+	f.credit(t, f.b.ID, 3)
+
+	ts, err := f.repo.FindTransactions(f.ctx, []string{f.tx(f.b.ID + "-0"), f.tx(f.a.ID + "-1"), f.tx("unknown")})
+	require.NoError(t, err)
+	require.Len(t, ts, 2)
+	assert.Equal(t, f.tx(f.a.ID+"-1"), ts[0].TransactionID)
+	assert.Equal(t, float64(2), ts[0].Amount)
+	assert.Equal(t, f.b.ID, ts[1].UserID)
+
+	ts, err = f.repo.FindTransactions(f.ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, ts)
+}
+
+func testTransactionsPosted(t *testing.T, f *fixture) {
+	f.credit(t, f.a.ID, 1)
+	from := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	f.credit(t, f.b.ID, 2, 3, 4)
+	time.Sleep(2 * time.Millisecond)
+	to := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	_, err := f.repo.AddBalance(f.ctx, f.a.ID, f.tx("late"), 5, nil)
+	require.NoError(t, err)
+
+	ts, err := f.repo.ListTransactionsPosted(f.ctx, from, to, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, ts, 2)
+	assert.Equal(t, float64(2), ts[0].Amount)
+	assert.Equal(t, float64(3), ts[1].Amount)
+
+	ts, err = f.repo.ListTransactionsPosted(f.ctx, from, to, ts[1].ID, 2)
+	require.NoError(t, err)
+	require.Len(t, ts, 1)
+	assert.Equal(t, float64(4), ts[0].Amount)
+	assert.Equal(t, f.b.ID, ts[0].UserID)
+}
+
+// drifted creates a user with a balance of 50 and an opening balance of 40, so it drifted by 10 from its history
+func (f *fixture) drifted(t *testing.T) *model.User {
+	now := time.Now().UTC().Truncate(time.Second)
+	u := &model.User{ID: f.id("drifted"), CreatedAt: now, UpdatedAt: now, Name: "Drifted", Balance: 50, OpeningBalance: 40, Status: model.UserStatusActive}
+	require.NoError(t, f.repo.CreateUser(f.ctx, u))
+	return u
+}
+
+func testCheckBalances(t *testing.T, f *fixture) {
+	f.credit(t, f.a.ID, 5, 7)
+	f.drifted(t)
+
+	cs, err := f.repo.CheckBalances(f.ctx, f.id(""), 3)
+	require.NoError(t, err)
+	require.Len(t, cs, 3)
+
+	assert.Equal(t, f.a.ID, cs[0].UserID)
+	assert.Equal(t, float64(112), cs[0].Balance)
+	assert.Equal(t, float64(112), cs[0].Expected)
+	last, err := f.repo.GetLastTransactionID(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, last, cs[0].TransactionSeq)
+
+	assert.Equal(t, f.b.ID, cs[1].UserID)
+	assert.Zero(t, cs[1].TransactionSeq)
+
+	assert.Equal(t, f.id("drifted"), cs[2].UserID)
+	assert.Equal(t, float64(50), cs[2].Balance)
+	assert.Equal(t, float64(40), cs[2].Expected)
+	assert.Equal(t, float64(10), ledger.Drift(cs[2]))
+
+	cs, err = f.repo.CheckBalances(f.ctx, f.id("drifted"), 1)
+	require.NoError(t, err)
+	require.Len(t, cs, 1)
+	assert.Equal(t, f.id("frozen"), cs[0].UserID)
+}
+
+func testRecordAdjustment(t *testing.T, f *fixture) {
+	u := f.drifted(t)
+	f.credit(t, u.ID, 5)
+
+	cs, err := f.repo.CheckBalances(f.ctx, f.id("d"), 1)
+	require.NoError(t, err)
+	require.Len(t, cs, 1)
+	c := cs[0]
+	require.Equal(t, float64(10), ledger.Drift(c))
+
+	adj, err := f.repo.RecordAdjustment(f.ctx, c, f.tx("adjust"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(10), adj.Amount)
+	assert.Greater(t, adj.ID, c.TransactionSeq)
+
+	cs, err = f.repo.CheckBalances(f.ctx, f.id("d"), 1)
+	require.NoError(t, err)
+	assert.Equal(t, float64(55), cs[0].Balance)
+	assert.Zero(t, ledger.Drift(cs[0]))
+	assert.Equal(t, adj.ID, cs[0].TransactionSeq)
+
+	// the adjustment is chained to the history
+	f.verify(t, u.ID)
+
+	// the check is stale once the history changed
+	_, err = f.repo.RecordAdjustment(f.ctx, c, f.tx("stale"))
+	requireStatus(t, err, http.StatusConflict, response.CodeConflict)
+	assert.ErrorIs(t, err, repository.ErrLedgerChanged)
+
+	_, err = f.repo.RecordAdjustment(f.ctx, &model.BalanceCheck{UserID: f.id("unknown")}, f.tx("unknown"))
+	requireStatus(t, err, http.StatusNotFound, response.CodeUserNotFound)
+}
+
+func testConcurrentCredits(t *testing.T, f *fixture) {
+	const credits = 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < credits; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := f.repo.AddBalance(f.ctx, f.a.ID, f.tx(strconv.Itoa(i)), 1, nil)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	u, err := f.repo.GetUserInfo(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(100+credits), u.Balance)
+
+	count, err := f.repo.GetHistoryCount(f.ctx, f.a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(credits), count)
+}
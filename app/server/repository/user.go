@@ -18,47 +18,94 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/doug-martin/goqu/v9"
+	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
 	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/jmoiron/sqlx"
 
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/ledger"
 	"github.com/diptomondal007/your-money/app/server/model"
 	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/tracing"
+)
+
+var (
+	// ErrTransactionProcessed is returned when a transaction id was already used for a posting
+	ErrTransactionProcessed = errors.New("transaction was already processed")
+	// ErrUserFrozen is returned when a posting is made to a frozen user account
+	ErrUserFrozen = errors.New("user account is frozen")
+	// ErrUserExists is returned when a user is created with the id of another user
+	ErrUserExists = errors.New("user already exists")
+	// ErrLedgerChanged is returned when an adjustment is recorded for a check made before the last posting of the user
+	ErrLedgerChanged = errors.New("the ledger of the user changed since it was checked")
 )
 
 // userRepository ...
 type userRepository struct {
-	db *sqlx.DB
+	db      *sqlx.DB
+	dialect goqu.DialectWrapper
 }
 
 // UserRepository ...
 type UserRepository interface {
-	AddBalance(userID string, transactionID string, amount float64) (*model.User, error)
-	GetUserInfo(userID string) (*model.User, error)
-	GetHistoryList(userID string, pageSize int64, cursor string) ([]*model.Transaction, error)
-	GetHistoryCount(userID string) (int64, error)
+	AddBalance(ctx context.Context, userID string, transactionID string, amount float64, fee *model.Fee) (*model.User, error)
+	GetUserInfo(ctx context.Context, userID string) (*model.User, error)
+	GetHistoryList(ctx context.Context, userID string, pageSize int64, cursor string) ([]*model.Transaction, error)
+	GetHistoryCount(ctx context.Context, userID string) (int64, error)
+	SetUserStatus(ctx context.Context, userID string, status string) (*model.User, error)
+	SetFeeSchedule(ctx context.Context, userID string, schedule string) (*model.User, error)
+	CreateUser(ctx context.Context, user *model.User) error
+	ListUsers(ctx context.Context, afterID string, limit int64) ([]*model.User, error)
+	ExportTransactions(ctx context.Context, userID string, fn func(t *model.Transaction) error) error
+	ListTransactionsAfter(ctx context.Context, userID string, afterID uint, limit int64) ([]*model.Transaction, error)
+	GetLastTransactionID(ctx context.Context, userID string) (uint, error)
+	GetBalanceAt(ctx context.Context, userID string, transactionSeq uint) (float64, error)
+	GetBalanceAsOf(ctx context.Context, userID string, asOf time.Time) (*model.BalanceSnapshot, error)
+	FindTransactions(ctx context.Context, transactionIDs []string) ([]*model.Transaction, error)
+	ListTransactionsPosted(ctx context.Context, from, to time.Time, afterID uint, limit int64) ([]*model.Transaction, error)
+	SaveBalanceSnapshot(ctx context.Context, s *model.BalanceSnapshot) (bool, error)
+	CheckBalances(ctx context.Context, afterID string, limit int64) ([]*model.BalanceCheck, error)
+	RecordAdjustment(ctx context.Context, c *model.BalanceCheck, transactionID string) (*model.Transaction, error)
 }
 
+var tracer = tracing.Tracer("repository")
+
 // NewUserRepo returns a new user repo instance
 func NewUserRepo(db *sqlx.DB) UserRepository {
-	return &userRepository{db: db}
+	return &userRepository{db: db, dialect: goqu.Dialect("postgres")}
 }
 
-func (u userRepository) AddBalance(userID string, transactionID string, amount float64) (*model.User, error) {
+// AddBalance credits amount to a user. a fee is debited from the user and credited to the house account by
+// transactions of their own. the balance.credited events and the audit event are written in the same db transaction
+func (u userRepository) AddBalance(ctx context.Context, userID string, transactionID string, amount float64, fee *model.Fee) (_ *model.User, err error) {
+	ctx, span := tracer.Start(ctx, "userRepository.AddBalance")
+	defer func() { tracing.End(span, err) }()
+
+	details := creditDetails(transactionID, amount, fee)
+	defer func() {
+		if err != nil {
+			auditFailure(ctx, u.db, audit.ActionBalanceCredit, "user:"+userID, details, err)
+		}
+	}()
+
 	updatedUser := &model.User{}
 
-	tx := u.db.MustBegin()
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
 	defer tx.Rollback()
 
 	var user model.User
-	q, _, err := goqu.From(goqu.T(model.TableUsers).As("u")).
+	q, _, err := u.dialect.From(goqu.T(model.TableUsers).As("u")).
 		Select("u.*").
 		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).
 		ForUpdate(exp.Wait). // add for update clause to lock the row so that other process or connection can't read the dirty value
@@ -67,16 +114,20 @@ func (u userRepository) AddBalance(userID string, transactionID string, amount f
 		return nil, err
 	}
 
-	if err := tx.Get(&user, q); err != nil {
+	if err := get(ctx, tx, "lock user", &user, q); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, response.WrapError(fmt.Errorf("user not found"), http.StatusNotFound, "")
+			return nil, response.WrapError(fmt.Errorf("user not found"), http.StatusNotFound, response.CodeUserNotFound)
 		}
 		return nil, err
 	}
 
+	if user.Status == model.UserStatusFrozen {
+		return nil, response.WrapError(ErrUserFrozen, http.StatusUnprocessableEntity, response.CodeUserFrozen)
+	}
+
 	tr := model.Transaction{}
 
-	q, _, err = goqu.From(goqu.T(model.TableTransactions).As("t")).
+	q, _, err = u.dialect.From(goqu.T(model.TableTransactions).As("t")).
 		Select("t.*").
 		Where(goqu.Ex{"transaction_id": goqu.Op{"eq": transactionID}}).
 		ToSQL()
@@ -84,49 +135,97 @@ func (u userRepository) AddBalance(userID string, transactionID string, amount f
 		return nil, err
 	}
 
-	if err = tx.Get(&tr, q); err != nil {
+	if err = get(ctx, tx, "find transaction", &tr, q); err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
 			return nil, err
 		}
 	}
 
 	if err == nil {
-		return nil, response.WrapError(fmt.Errorf("transaction was already processed"), http.StatusUnprocessableEntity, "")
+		return nil, response.WrapError(ErrTransactionProcessed, http.StatusUnprocessableEntity, response.CodeTransactionProcessed)
 	}
 
-	q, _, err = goqu.Update(model.TableUsers).
-		Set(map[string]interface{}{"balance": goqu.L("balance + ?", amount)}).
-		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).ToSQL()
+	// the user row is locked, so the last transaction can't change until the new one is chained to it
+	last, err := u.lastTransaction(ctx, tx, userID)
 	if err != nil {
 		return nil, err
 	}
-	tx.MustExec(q)
 
-	q, _, err = goqu.Insert(goqu.T(model.TableTransactions)).Rows(model.Transaction{
-		CreatedAt:     time.Now().UTC(),
+	if err = u.addToBalance(ctx, tx, userID, amount-fee.GetAmount()); err != nil {
+		return nil, err
+	}
+
+	t := model.Transaction{
+		CreatedAt:     ledger.Timestamp(time.Now()),
 		Amount:        amount,
 		UserID:        userID,
 		TransactionID: transactionID,
-	}).ToSQL()
+		PrevHash:      last.Hash,
+		Fee:           fee.GetAmount(),
+	}
+	t.Hash = ledger.Hash(&t)
+
+	q, _, err = u.dialect.Insert(goqu.T(model.TableTransactions)).Rows(t).ToSQL()
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = tx.Exec(q)
-	if err != nil {
-		log.Println(err)
+	if err = exec(ctx, tx, "insert transaction", q); err != nil {
+		// only the user is locked, so a concurrent credit of another user may have used the transaction id since it
+		// was looked up. the insert waits for it on the unique index and fails once it's committed
+		if isUniqueViolation(err) {
+			return nil, response.WrapError(ErrTransactionProcessed, http.StatusUnprocessableEntity, response.CodeTransactionProcessed)
+		}
 		return nil, err
 	}
 
-	q, _, err = goqu.
-		From(goqu.T(model.TableUsers).As("u")).
+	var house *model.User
+	if fee != nil {
+		if house, err = u.chargeFee(ctx, tx, &t, fee, true); err != nil {
+			return nil, err
+		}
+	}
+
+	q, _, err = u.dialect.From(goqu.T(model.TableUsers).As("u")).
 		Select("u.*").
 		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).ToSQL()
 	if err != nil {
 		return nil, err
 	}
 
-	if err = tx.Get(updatedUser, q); err != nil {
+	if err = get(ctx, tx, "get user", updatedUser, q); err != nil {
+		return nil, err
+	}
+
+	err = appendOutboxEvent(ctx, tx, model.EventBalanceCredited, userID, BalanceCredited{
+		UserID:        userID,
+		TransactionID: transactionID,
+		Amount:        amount,
+		Fee:           fee.GetAmount(),
+		Balance:       updatedUser.Balance,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if house != nil {
+		_, creditID := ledger.FeeTransactionIDs(transactionID)
+		err = appendOutboxEvent(ctx, tx, model.EventBalanceCredited, house.ID, BalanceCredited{
+			UserID:        house.ID,
+			TransactionID: creditID,
+			Amount:        fee.Amount,
+			Balance:       house.Balance,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ev := audit.NewEvent(ctx, audit.ActionBalanceCredit, "user:"+userID, model.AuditOutcomeSuccess, details)
+	ev.BeforeBalance = sql.NullFloat64{Float64: user.Balance, Valid: true}
+	ev.AfterBalance = sql.NullFloat64{Float64: updatedUser.Balance, Valid: true}
+
+	if err = appendAuditEvent(ctx, tx, ev); err != nil {
 		return nil, err
 	}
 
@@ -138,11 +237,131 @@ func (u userRepository) AddBalance(userID string, transactionID string, amount f
 	return updatedUser, nil
 }
 
+// creditDetails returns the audit details of a credit
+func creditDetails(transactionID string, amount float64, fee *model.Fee) map[string]interface{} {
+	details := map[string]interface{}{"transaction_id": transactionID, "amount": amount}
+	if fee != nil {
+		details["fee"] = fee.Amount
+		details["fee_schedule"] = fee.Schedule
+	}
+	return details
+}
+
+// addToBalance adds delta to the balance of a user in tx
+func (u userRepository) addToBalance(ctx context.Context, tx *sqlx.Tx, userID string, delta float64) error {
+	q, _, err := u.dialect.Update(model.TableUsers).
+		Set(map[string]interface{}{"balance": goqu.L("balance + ?", delta)}).
+		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).ToSQL()
+	if err != nil {
+		return err
+	}
+
+	return exec(ctx, tx, "update balance", q)
+}
+
+// lastTransaction returns the last transaction of a user in tx, an empty one if there is none
+func (u userRepository) lastTransaction(ctx context.Context, tx *sqlx.Tx, userID string) (*model.Transaction, error) {
+	last := &model.Transaction{}
+	q, _, err := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+		Select("t.*").
+		Where(goqu.Ex{"user_id": goqu.Op{"eq": userID}}).
+		Order(goqu.I("t.id").Desc()).
+		Limit(1).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = get(ctx, tx, "find last transaction", last, q); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return last, nil
+}
+
+// chargeFee debits the fee of the credit t from the user and credits it to the house account, each chained to the
+// history of its account. the user must be locked in tx, the house account is locked if lock is set. it returns the
+// house account after the credit.
+// the fee credit is chained to the last transaction of the house account, so the house row stays locked until the
+// credit commits and every credit charged a fee waits for the credits before it. the lock is taken as late as
+// possible, after the credit and the fee debit are written
+func (u userRepository) chargeFee(ctx context.Context, tx *sqlx.Tx, t *model.Transaction, fee *model.Fee, lock bool) (*model.User, error) {
+	debitID, creditID := ledger.FeeTransactionIDs(t.TransactionID)
+
+	// the debit follows the credit in the history of the user
+	debit := model.Transaction{
+		CreatedAt:     t.CreatedAt,
+		Amount:        -fee.Amount,
+		UserID:        t.UserID,
+		TransactionID: debitID,
+		PrevHash:      t.Hash,
+	}
+	debit.Hash = ledger.Hash(&debit)
+
+	q, _, err := u.dialect.Insert(goqu.T(model.TableTransactions)).Rows(debit).ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = exec(ctx, tx, "insert fee debit", q); err != nil {
+		return nil, err
+	}
+
+	ds := u.dialect.From(goqu.T(model.TableUsers).As("u")).
+		Select("u.*").
+		Where(goqu.Ex{"id": goqu.Op{"eq": fee.HouseAccount}})
+	if lock {
+		// the house account is always locked after the user, so the postings can't deadlock. the lock serializes the
+		// fee credits, two of them can't chain to the same last transaction of the house account
+		ds = ds.ForUpdate(exp.Wait)
+	}
+
+	house := &model.User{}
+	if q, _, err = ds.ToSQL(); err != nil {
+		return nil, err
+	}
+
+	if err = get(ctx, tx, "lock house account", house, q); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("house account %s not found", fee.HouseAccount)
+		}
+		return nil, err
+	}
+
+	last, err := u.lastTransaction(ctx, tx, house.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	credit := model.Transaction{
+		CreatedAt:     t.CreatedAt,
+		Amount:        fee.Amount,
+		UserID:        house.ID,
+		TransactionID: creditID,
+		PrevHash:      last.Hash,
+	}
+	credit.Hash = ledger.Hash(&credit)
+
+	if q, _, err = u.dialect.Insert(goqu.T(model.TableTransactions)).Rows(credit).ToSQL(); err != nil {
+		return nil, err
+	}
+
+	if err = exec(ctx, tx, "insert fee credit", q); err != nil {
+		return nil, err
+	}
+
+	if err = u.addToBalance(ctx, tx, house.ID, fee.Amount); err != nil {
+		return nil, err
+	}
+
+	house.Balance += fee.Amount
+	return house, nil
+}
+
 // GetUserInfo fetches user info for a user from db
-func (u userRepository) GetUserInfo(userID string) (*model.User, error) {
+func (u userRepository) GetUserInfo(ctx context.Context, userID string) (*model.User, error) {
 	user := &model.User{}
 
-	q, _, err := goqu.From(goqu.T(model.TableUsers).As("u")).
+	q, _, err := u.dialect.From(goqu.T(model.TableUsers).As("u")).
 		Select("u.*").
 		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).
 		ToSQL()
@@ -150,20 +369,183 @@ func (u userRepository) GetUserInfo(userID string) (*model.User, error) {
 		return nil, err
 	}
 
-	if err := u.db.Get(user, q); err != nil {
+	if err := get(ctx, u.db, "get user", user, q); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, response.WrapError(fmt.Errorf("user not found"), http.StatusNotFound, "")
+			return nil, response.WrapError(fmt.Errorf("user not found"), http.StatusNotFound, response.CodeUserNotFound)
 		}
 		return nil, err
 	}
 	return user, nil
 }
 
+// SetUserStatus updates the status of a user, ex - freezes the account. the change is recorded to the audit log
+// in the same db transaction
+func (u userRepository) SetUserStatus(ctx context.Context, userID string, status string) (_ *model.User, err error) {
+	details := map[string]interface{}{"status": status}
+	defer func() {
+		if err != nil {
+			auditFailure(ctx, u.db, audit.ActionUserStatusChange, "user:"+userID, details, err)
+		}
+	}()
+
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var user model.User
+	q, _, err := u.dialect.From(goqu.T(model.TableUsers).As("u")).
+		Select("u.*").
+		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).
+		ForUpdate(exp.Wait).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = get(ctx, tx, "lock user", &user, q); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, response.WrapError(fmt.Errorf("user not found"), http.StatusNotFound, response.CodeUserNotFound)
+		}
+		return nil, err
+	}
+
+	q, _, err = u.dialect.Update(model.TableUsers).
+		Set(goqu.Record{"status": status, "updated_at": time.Now().UTC()}).
+		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = exec(ctx, tx, "update user status", q); err != nil {
+		return nil, err
+	}
+
+	ev := audit.NewEvent(ctx, audit.ActionUserStatusChange, "user:"+userID, model.AuditOutcomeSuccess,
+		map[string]interface{}{"before_status": user.Status, "after_status": status})
+	ev.BeforeBalance = sql.NullFloat64{Float64: user.Balance, Valid: true}
+	ev.AfterBalance = ev.BeforeBalance
+
+	if err = appendAuditEvent(ctx, tx, ev); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	user.Status = status
+	return &user, nil
+}
+
+// SetFeeSchedule assigns a fee schedule to a user, the default schedule applies if it's empty. the change is
+// recorded to the audit log in the same db transaction
+func (u userRepository) SetFeeSchedule(ctx context.Context, userID string, schedule string) (_ *model.User, err error) {
+	details := map[string]interface{}{"fee_schedule": schedule}
+	defer func() {
+		if err != nil {
+			auditFailure(ctx, u.db, audit.ActionUserFeeScheduleChange, "user:"+userID, details, err)
+		}
+	}()
+
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var user model.User
+	q, _, err := u.dialect.From(goqu.T(model.TableUsers).As("u")).
+		Select("u.*").
+		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).
+		ForUpdate(exp.Wait).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = get(ctx, tx, "lock user", &user, q); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, response.WrapError(fmt.Errorf("user not found"), http.StatusNotFound, response.CodeUserNotFound)
+		}
+		return nil, err
+	}
+
+	q, _, err = u.dialect.Update(model.TableUsers).
+		Set(goqu.Record{"fee_schedule": schedule, "updated_at": time.Now().UTC()}).
+		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = exec(ctx, tx, "update fee schedule", q); err != nil {
+		return nil, err
+	}
+
+	ev := audit.NewEvent(ctx, audit.ActionUserFeeScheduleChange, "user:"+userID, model.AuditOutcomeSuccess,
+		map[string]interface{}{"before_fee_schedule": user.FeeSchedule, "after_fee_schedule": schedule})
+	ev.BeforeBalance = sql.NullFloat64{Float64: user.Balance, Valid: true}
+	ev.AfterBalance = ev.BeforeBalance
+
+	if err = appendAuditEvent(ctx, tx, ev); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	user.FeeSchedule = schedule
+	return &user, nil
+}
+
+// CreateUser creates a user. the creation is recorded to the audit log in the same db transaction
+func (u userRepository) CreateUser(ctx context.Context, user *model.User) (err error) {
+	details := map[string]interface{}{"name": user.Name, "opening_balance": user.OpeningBalance}
+	defer func() {
+		if err != nil {
+			auditFailure(ctx, u.db, audit.ActionUserCreate, "user:"+user.ID, details, err)
+		}
+	}()
+
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q, _, err := u.dialect.Insert(goqu.T(model.TableUsers)).Rows(user).OnConflict(goqu.DoNothing()).ToSQL()
+	if err != nil {
+		return err
+	}
+
+	n, err := execAffected(ctx, tx, "insert user", q)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return response.WrapError(ErrUserExists, http.StatusConflict, response.CodeConflict)
+	}
+
+	ev := audit.NewEvent(ctx, audit.ActionUserCreate, "user:"+user.ID, model.AuditOutcomeSuccess, details)
+	ev.AfterBalance = sql.NullFloat64{Float64: user.Balance, Valid: true}
+
+	if err = appendAuditEvent(ctx, tx, ev); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // GetHistoryList returns the transaction history list for a user
-func (u userRepository) GetHistoryList(userID string, pageSize int64, cursor string) ([]*model.Transaction, error) {
+func (u userRepository) GetHistoryList(ctx context.Context, userID string, pageSize int64, cursor string) ([]*model.Transaction, error) {
 	res := make([]*model.Transaction, 0)
 
-	d := goqu.From(goqu.T(model.TableTransactions).As("t")).
+	d := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
 		Select("t.*")
 
 	d = d.Where(goqu.Ex{"user_id": goqu.Op{"eq": userID}})
@@ -171,7 +553,7 @@ func (u userRepository) GetHistoryList(userID string, pageSize int64, cursor str
 	if cursor != "" {
 		c, err := response.ParseCursor(cursor)
 		if err != nil {
-			return res, response.WrapError(fmt.Errorf("invalid pagination cursor"), http.StatusBadRequest, "")
+			return res, response.WrapError(fmt.Errorf("invalid pagination cursor"), http.StatusBadRequest, response.CodeInvalidCursor)
 		}
 
 		d = d.Where(goqu.Ex{
@@ -187,16 +569,398 @@ func (u userRepository) GetHistoryList(userID string, pageSize int64, cursor str
 		return nil, err
 	}
 
-	if err = u.db.Select(&res, sql); err != nil {
+	if err = selectAll(ctx, u.db, "list transactions", &res, sql); err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-func (u userRepository) GetHistoryCount(userID string) (int64, error) {
+// ListUsers returns up to limit users ordered by id, starting after afterID
+func (u userRepository) ListUsers(ctx context.Context, afterID string, limit int64) ([]*model.User, error) {
+	res := make([]*model.User, 0)
+
+	q, _, err := u.dialect.From(goqu.T(model.TableUsers).As("u")).
+		Select("u.*").
+		Where(goqu.Ex{"id": goqu.Op{"gt": afterID}}).
+		Order(goqu.I("u.id").Asc()).
+		Limit(uint(limit)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = selectAll(ctx, u.db, "list users", &res, q); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ExportTransactions calls fn for every transaction of a user in insertion order
+func (u userRepository) ExportTransactions(ctx context.Context, userID string, fn func(t *model.Transaction) error) error {
+	var lastID uint
+	for {
+		q, _, err := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+			Select("t.*").
+			Where(goqu.Ex{"user_id": goqu.Op{"eq": userID}, "id": goqu.Op{"gt": lastID}}).
+			Order(goqu.I("t.id").Asc()).
+			Limit(exportBatchSize).
+			ToSQL()
+		if err != nil {
+			return err
+		}
+
+		ts := make([]*model.Transaction, 0, exportBatchSize)
+		if err = selectAll(ctx, u.db, "export transactions", &ts, q); err != nil {
+			return err
+		}
+
+		for _, t := range ts {
+			if err := fn(t); err != nil {
+				return err
+			}
+			lastID = t.ID
+		}
+
+		if len(ts) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// ListTransactionsAfter returns up to limit transactions of a user with a sequence greater than afterID, oldest first.
+// postings of a user are serialized by the user row lock, so the sequence of a user grows in commit order
+func (u userRepository) ListTransactionsAfter(ctx context.Context, userID string, afterID uint, limit int64) ([]*model.Transaction, error) {
+	res := make([]*model.Transaction, 0)
+
+	q, _, err := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+		Select("t.*").
+		Where(goqu.Ex{"user_id": goqu.Op{"eq": userID}, "id": goqu.Op{"gt": afterID}}).
+		Order(goqu.I("t.id").Asc()).
+		Limit(uint(limit)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = selectAll(ctx, u.db, "list transactions after", &res, q); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetLastTransactionID returns the sequence of the last transaction of a user, 0 if there is none
+func (u userRepository) GetLastTransactionID(ctx context.Context, userID string) (uint, error) {
+	var id uint
+
+	q, _, err := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+		Select(goqu.COALESCE(goqu.MAX("t.id"), 0)).
+		Where(goqu.Ex{"user_id": goqu.Op{"eq": userID}}).
+		ToSQL()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = get(ctx, u.db, "get last transaction id", &id, q); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetBalanceAt returns the balance of a user right after the transaction with the sequence transactionSeq,
+// computed from the opening balance and the history
+func (u userRepository) GetBalanceAt(ctx context.Context, userID string, transactionSeq uint) (float64, error) {
+	var balance float64
+
+	sum := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+		Select(goqu.COALESCE(goqu.SUM("t.amount"), 0)).
+		Where(goqu.Ex{"t.user_id": goqu.I("u.id"), "t.id": goqu.Op{"lte": transactionSeq}})
+
+	q, _, err := u.dialect.From(goqu.T(model.TableUsers).As("u")).
+		Select(goqu.L("? + ?", goqu.I("u.opening_balance"), sum)).
+		Where(goqu.Ex{"u.id": goqu.Op{"eq": userID}}).
+		ToSQL()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = get(ctx, u.db, "get balance at", &balance, q); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, response.WrapError(fmt.Errorf("user not found"), http.StatusNotFound, response.CodeUserNotFound)
+		}
+		return 0, err
+	}
+	return balance, nil
+}
+
+// GetBalanceAsOf returns the balance of a user as of a point in time. it's the balance of the latest snapshot
+// taken at or before asOf, or the opening balance if there is none, plus the transactions created since the snapshot
+// until asOf. the returned snapshot isn't saved
+func (u userRepository) GetBalanceAsOf(ctx context.Context, userID string, asOf time.Time) (*model.BalanceSnapshot, error) {
+	asOf = ledger.Timestamp(asOf)
+
+	user, err := u.GetUserInfo(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &model.BalanceSnapshot{UserID: userID, AsOf: asOf, Balance: user.OpeningBalance}
+
+	var snapshots []*model.BalanceSnapshot
+	q, _, err := u.dialect.From(goqu.T(model.TableSnapshots).As("s")).
+		Select("s.*").
+		Where(goqu.Ex{"user_id": goqu.Op{"eq": userID}, "as_of": goqu.Op{"lte": asOf}}).
+		Order(goqu.I("s.as_of").Desc()).
+		Limit(1).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = selectAll(ctx, u.db, "get balance snapshot", &snapshots, q); err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) > 0 {
+		res.Balance = snapshots[0].Balance
+		res.TransactionSeq = snapshots[0].TransactionSeq
+	}
+
+	delta := struct {
+		Amount float64 `db:"amount"`
+		Seq    uint    `db:"seq"`
+	}{}
+	q, _, err = u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+		Select(
+			goqu.COALESCE(goqu.SUM("t.amount"), 0).As("amount"),
+			goqu.COALESCE(goqu.MAX("t.id"), 0).As("seq"),
+		).
+		Where(goqu.Ex{
+			"user_id":    goqu.Op{"eq": userID},
+			"id":         goqu.Op{"gt": res.TransactionSeq},
+			"created_at": goqu.Op{"lte": asOf},
+		}).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = get(ctx, u.db, "get balance delta", &delta, q); err != nil {
+		return nil, err
+	}
+
+	res.Balance += delta.Amount
+	if delta.Seq > res.TransactionSeq {
+		res.TransactionSeq = delta.Seq
+	}
+	return res, nil
+}
+
+// SaveBalanceSnapshot saves the snapshot of a user. it returns false if the user already has a snapshot as of
+// the same time, the existing snapshot is kept
+func (u userRepository) SaveBalanceSnapshot(ctx context.Context, s *model.BalanceSnapshot) (bool, error) {
+	s.AsOf = ledger.Timestamp(s.AsOf)
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = ledger.Timestamp(time.Now())
+	}
+
+	q, _, err := u.dialect.Insert(goqu.T(model.TableSnapshots)).Rows(s).OnConflict(goqu.DoNothing()).ToSQL()
+	if err != nil {
+		return false, err
+	}
+
+	n, err := execAffected(ctx, u.db, "insert balance snapshot", q)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// FindTransactions returns the transactions of any user with the transaction ids, in id order. unknown transaction
+// ids are skipped
+func (u userRepository) FindTransactions(ctx context.Context, transactionIDs []string) ([]*model.Transaction, error) {
+	res := make([]*model.Transaction, 0, len(transactionIDs))
+	if len(transactionIDs) == 0 {
+		return res, nil
+	}
+
+	q, _, err := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+		Select("t.*").
+		Where(goqu.Ex{"transaction_id": transactionIDs}).
+		Order(goqu.I("t.id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = selectAll(ctx, u.db, "find transactions", &res, q); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ListTransactionsPosted returns up to limit transactions of any user created in [from, to) with an id greater
+// than afterID, in id order
+func (u userRepository) ListTransactionsPosted(ctx context.Context, from, to time.Time, afterID uint, limit int64) ([]*model.Transaction, error) {
+	res := make([]*model.Transaction, 0)
+
+	q, _, err := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+		Select("t.*").
+		Where(
+			goqu.C("id").Gt(afterID),
+			goqu.C("created_at").Gte(ledger.Timestamp(from)),
+			goqu.C("created_at").Lt(ledger.Timestamp(to)),
+		).
+		Order(goqu.I("t.id").Asc()).
+		Limit(uint(limit)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = selectAll(ctx, u.db, "list posted transactions", &res, q); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CheckBalances returns up to limit users with an id greater than afterID, in id order, with their stored balance
+// next to the balance recomputed from the opening balance and the history. both are read by a single statement,
+// so they are consistent
+func (u userRepository) CheckBalances(ctx context.Context, afterID string, limit int64) ([]*model.BalanceCheck, error) {
+	res := make([]*model.BalanceCheck, 0)
+
+	sum := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+		Select(goqu.COALESCE(goqu.SUM("t.amount"), 0)).
+		Where(goqu.Ex{"t.user_id": goqu.I("u.id")})
+	seq := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+		Select(goqu.COALESCE(goqu.MAX("t.id"), 0)).
+		Where(goqu.Ex{"t.user_id": goqu.I("u.id")})
+
+	q, _, err := u.dialect.From(goqu.T(model.TableUsers).As("u")).
+		Select(
+			goqu.I("u.id").As("user_id"),
+			goqu.I("u.balance"),
+			goqu.L("? + ?", goqu.I("u.opening_balance"), sum).As("expected"),
+			seq.As("transaction_seq"),
+		).
+		Where(goqu.Ex{"u.id": goqu.Op{"gt": afterID}}).
+		Order(goqu.I("u.id").Asc()).
+		Limit(uint(limit)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = selectAll(ctx, u.db, "check balances", &res, q); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RecordAdjustment records the drift of the balance found by c as a transaction of the user, so that the history
+// adds up to the stored balance again. the balance itself isn't changed. it returns ErrLedgerChanged if the balance
+// or the history of the user changed since c was made
+func (u userRepository) RecordAdjustment(ctx context.Context, c *model.BalanceCheck, transactionID string) (_ *model.Transaction, err error) {
+	ctx, span := tracer.Start(ctx, "userRepository.RecordAdjustment")
+	defer func() { tracing.End(span, err) }()
+
+	details := map[string]interface{}{"transaction_id": transactionID, "amount": c.Balance - c.Expected}
+	defer func() {
+		if err != nil {
+			auditFailure(ctx, u.db, audit.ActionLedgerAdjust, "user:"+c.UserID, details, err)
+		}
+	}()
+
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var user model.User
+	q, _, err := u.dialect.From(goqu.T(model.TableUsers).As("u")).
+		Select("u.*").
+		Where(goqu.Ex{"id": goqu.Op{"eq": c.UserID}}).
+		ForUpdate(exp.Wait).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := get(ctx, tx, "lock user", &user, q); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, response.WrapError(fmt.Errorf("user not found"), http.StatusNotFound, response.CodeUserNotFound)
+		}
+		return nil, err
+	}
+
+	t, err := u.insertAdjustment(ctx, tx, &user, c, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	ev := audit.NewEvent(ctx, audit.ActionLedgerAdjust, "user:"+c.UserID, model.AuditOutcomeSuccess, details)
+	ev.BeforeBalance = sql.NullFloat64{Float64: user.Balance, Valid: true}
+	ev.AfterBalance = sql.NullFloat64{Float64: user.Balance, Valid: true}
+
+	if err = appendAuditEvent(ctx, tx, ev); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// insertAdjustment chains the adjusting transaction for c to the history of user in tx. user must be read in tx,
+// after the row was locked
+func (u userRepository) insertAdjustment(ctx context.Context, tx *sqlx.Tx, user *model.User, c *model.BalanceCheck, transactionID string) (*model.Transaction, error) {
+	last, err := u.lastTransaction(ctx, tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Balance != c.Balance || last.ID != c.TransactionSeq {
+		return nil, response.WrapError(ErrLedgerChanged, http.StatusConflict, response.CodeConflict)
+	}
+
+	t := &model.Transaction{
+		CreatedAt:     ledger.Timestamp(time.Now()),
+		Amount:        c.Balance - c.Expected,
+		UserID:        user.ID,
+		TransactionID: transactionID,
+		PrevHash:      last.Hash,
+	}
+	t.Hash = ledger.Hash(t)
+
+	q, _, err := u.dialect.Insert(goqu.T(model.TableTransactions)).Rows(t).ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = exec(ctx, tx, "insert adjustment", q); err != nil {
+		return nil, err
+	}
+
+	q, _, err = u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+		Select("t.id").
+		Where(goqu.Ex{"transaction_id": goqu.Op{"eq": transactionID}}).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = get(ctx, tx, "get adjustment", &t.ID, q); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (u userRepository) GetHistoryCount(ctx context.Context, userID string) (int64, error) {
 	var count int64
 
-	d := goqu.From(goqu.T(model.TableTransactions).As("t")).
+	d := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
 		Select(goqu.COUNT("*"))
 
 	d = d.Where(goqu.Ex{"user_id": goqu.Op{"eq": userID}})
@@ -206,7 +970,7 @@ func (u userRepository) GetHistoryCount(userID string) (int64, error) {
 		return 0, err
 	}
 
-	if err = u.db.Get(&count, sql); err != nil {
+	if err = get(ctx, u.db, "count transactions", &count, sql); err != nil {
 		return 0, err
 	}
 	return count, nil
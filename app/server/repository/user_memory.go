@@ -0,0 +1,523 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/ledger"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+// memoryUserRepository keeps the users and their transactions in memory. it has the semantics of the postgres
+// repository, but keeps no outbox and no audit events. the server refuses to use it unless the features needing
+// them are turned off, see config.Unsupported
+type memoryUserRepository struct {
+	// mu guards users, txIDs and seq
+	mu    sync.RWMutex
+	users map[string]*memoryUser
+	// txIDs are the processed transaction ids, they are unique across users
+	txIDs map[string]bool
+	// seq is the id of the last transaction
+	seq uint
+}
+
+// memoryUser is a user, its transactions and its balance snapshots ordered by as of. mu serializes the postings
+// of the user like the user row lock
+type memoryUser struct {
+	mu        sync.Mutex
+	user      model.User
+	txs       []*model.Transaction
+	snapshots []*model.BalanceSnapshot
+}
+
+// NewMemoryUserRepo returns a new in-memory user repo holding users
+func NewMemoryUserRepo(users ...*model.User) UserRepository {
+	r := &memoryUserRepository{users: map[string]*memoryUser{}, txIDs: map[string]bool{}}
+	for _, u := range users {
+		user := *u
+		if user.Status == "" {
+			user.Status = model.UserStatusActive
+		}
+		r.users[u.ID] = &memoryUser{user: user}
+	}
+	return r
+}
+
+// SeedUsers are the users loaded by the migrations
+func SeedUsers() []*model.User {
+	now := time.Now().UTC()
+	return []*model.User{
+		{ID: "6d7750a1-c3f2-4765-bf8f-33bc80f3f809", CreatedAt: now, UpdatedAt: now, Name: "Test", Balance: 100, OpeningBalance: 100, Status: model.UserStatusActive},
+	}
+}
+
+// user returns the user with userID
+func (r *memoryUserRepository) user(userID string) (*memoryUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[userID]
+	if !ok {
+		return nil, response.WrapError(fmt.Errorf("user not found"), http.StatusNotFound, response.CodeUserNotFound)
+	}
+	return u, nil
+}
+
+// AddBalance credits amount to a user. a fee is debited from the user and credited to the house account by
+// transactions of their own
+func (r *memoryUserRepository) AddBalance(ctx context.Context, userID string, transactionID string, amount float64, fee *model.Fee) (*model.User, error) {
+	u, err := r.user(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var house *memoryUser
+	if fee != nil {
+		if house, err = r.user(fee.HouseAccount); err != nil {
+			return nil, fmt.Errorf("house account %s not found", fee.HouseAccount)
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	// the house account is always locked after the user, like the rows of the postgres repository
+	if house != nil && house != u {
+		house.mu.Lock()
+		defer house.mu.Unlock()
+	}
+
+	if u.user.Status == model.UserStatusFrozen {
+		return nil, response.WrapError(ErrUserFrozen, http.StatusUnprocessableEntity, response.CodeUserFrozen)
+	}
+
+	r.mu.Lock()
+	if r.txIDs[transactionID] {
+		r.mu.Unlock()
+		return nil, response.WrapError(ErrTransactionProcessed, http.StatusUnprocessableEntity, response.CodeTransactionProcessed)
+	}
+	r.txIDs[transactionID] = true
+	r.mu.Unlock()
+
+	t := r.append(u, ledger.Timestamp(time.Now()), transactionID, amount, fee.GetAmount())
+	u.user.Balance += amount
+
+	if fee != nil {
+		debitID, creditID := ledger.FeeTransactionIDs(transactionID)
+		r.append(u, t.CreatedAt, debitID, -fee.Amount, 0)
+		u.user.Balance -= fee.Amount
+		r.append(house, t.CreatedAt, creditID, fee.Amount, 0)
+		house.user.Balance += fee.Amount
+	}
+
+	user := u.user
+	return &user, nil
+}
+
+// append chains a transaction of amount, charged fee, to the history of a locked user. the transaction id must be
+// checked and the balance updated by the caller
+func (r *memoryUserRepository) append(u *memoryUser, createdAt time.Time, transactionID string, amount, fee float64) *model.Transaction {
+	r.mu.Lock()
+	r.txIDs[transactionID] = true
+	r.seq++
+	id := r.seq
+	r.mu.Unlock()
+
+	t := &model.Transaction{
+		ID:            id,
+		CreatedAt:     createdAt,
+		Amount:        amount,
+		Fee:           fee,
+		UserID:        u.user.ID,
+		TransactionID: transactionID,
+	}
+	if n := len(u.txs); n > 0 {
+		t.PrevHash = u.txs[n-1].Hash
+	}
+	t.Hash = ledger.Hash(t)
+
+	u.txs = append(u.txs, t)
+	return t
+}
+
+// GetUserInfo returns a user
+func (r *memoryUserRepository) GetUserInfo(ctx context.Context, userID string) (*model.User, error) {
+	u, err := r.user(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user := u.user
+	return &user, nil
+}
+
+// GetHistoryList returns a page of the transactions of a user, newest first
+func (r *memoryUserRepository) GetHistoryList(ctx context.Context, userID string, pageSize int64, cursor string) ([]*model.Transaction, error) {
+	res := make([]*model.Transaction, 0)
+
+	before := ^uint(0)
+	if cursor != "" {
+		c, err := response.ParseCursor(cursor)
+		if err != nil {
+			return res, response.WrapError(fmt.Errorf("invalid pagination cursor"), http.StatusBadRequest, response.CodeInvalidCursor)
+		}
+		before = c.ID
+	}
+
+	txs := r.transactions(userID)
+	for i := len(txs) - 1; i >= 0 && int64(len(res)) < pageSize; i-- {
+		if txs[i].ID < before {
+			res = append(res, txs[i])
+		}
+	}
+	return res, nil
+}
+
+// GetHistoryCount returns the number of transactions of a user
+func (r *memoryUserRepository) GetHistoryCount(ctx context.Context, userID string) (int64, error) {
+	return int64(len(r.transactions(userID))), nil
+}
+
+// SetUserStatus updates the status of a user
+func (r *memoryUserRepository) SetUserStatus(ctx context.Context, userID string, status string) (*model.User, error) {
+	u, err := r.user(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.user.Status = status
+	u.user.UpdatedAt = time.Now().UTC()
+
+	user := u.user
+	return &user, nil
+}
+
+// SetFeeSchedule assigns a fee schedule to a user, the default schedule applies if it's empty
+func (r *memoryUserRepository) SetFeeSchedule(ctx context.Context, userID string, schedule string) (*model.User, error) {
+	u, err := r.user(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.user.FeeSchedule = schedule
+	u.user.UpdatedAt = time.Now().UTC()
+
+	user := u.user
+	return &user, nil
+}
+
+// CreateUser creates a user
+func (r *memoryUserRepository) CreateUser(ctx context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; ok {
+		return response.WrapError(ErrUserExists, http.StatusConflict, response.CodeConflict)
+	}
+
+	u := *user
+	if u.Status == "" {
+		u.Status = model.UserStatusActive
+	}
+	r.users[user.ID] = &memoryUser{user: u}
+	return nil
+}
+
+// ListUsers returns up to limit users ordered by id, starting after afterID
+func (r *memoryUserRepository) ListUsers(ctx context.Context, afterID string, limit int64) ([]*model.User, error) {
+	r.mu.RLock()
+	ids := make([]string, 0, len(r.users))
+	for id := range r.users {
+		if id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Strings(ids)
+	if int64(len(ids)) > limit {
+		ids = ids[:limit]
+	}
+
+	res := make([]*model.User, 0, len(ids))
+	for _, id := range ids {
+		u, err := r.GetUserInfo(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, u)
+	}
+	return res, nil
+}
+
+// ExportTransactions calls fn for every transaction of a user in insertion order
+func (r *memoryUserRepository) ExportTransactions(ctx context.Context, userID string, fn func(t *model.Transaction) error) error {
+	for _, t := range r.transactions(userID) {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListTransactionsAfter returns up to limit transactions of a user with a sequence greater than afterID, oldest first
+func (r *memoryUserRepository) ListTransactionsAfter(ctx context.Context, userID string, afterID uint, limit int64) ([]*model.Transaction, error) {
+	txs := r.transactions(userID)
+	i := sort.Search(len(txs), func(i int) bool { return txs[i].ID > afterID })
+
+	res := make([]*model.Transaction, 0)
+	for ; i < len(txs) && int64(len(res)) < limit; i++ {
+		res = append(res, txs[i])
+	}
+	return res, nil
+}
+
+// GetLastTransactionID returns the sequence of the last transaction of a user, 0 if there is none
+func (r *memoryUserRepository) GetLastTransactionID(ctx context.Context, userID string) (uint, error) {
+	txs := r.transactions(userID)
+	if len(txs) == 0 {
+		return 0, nil
+	}
+	return txs[len(txs)-1].ID, nil
+}
+
+// GetBalanceAt returns the balance of a user right after the transaction with the sequence transactionSeq
+func (r *memoryUserRepository) GetBalanceAt(ctx context.Context, userID string, transactionSeq uint) (float64, error) {
+	u, err := r.GetUserInfo(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	balance := u.OpeningBalance
+	for _, t := range r.transactions(userID) {
+		if t.ID > transactionSeq {
+			break
+		}
+		balance += t.Amount
+	}
+	return balance, nil
+}
+
+// GetBalanceAsOf returns the balance of a user as of a point in time, from the latest snapshot taken at or before
+// asOf and the transactions created since
+func (r *memoryUserRepository) GetBalanceAsOf(ctx context.Context, userID string, asOf time.Time) (*model.BalanceSnapshot, error) {
+	asOf = ledger.Timestamp(asOf)
+
+	u, err := r.user(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	res := &model.BalanceSnapshot{UserID: userID, AsOf: asOf, Balance: u.user.OpeningBalance}
+
+	i := sort.Search(len(u.snapshots), func(i int) bool { return u.snapshots[i].AsOf.After(asOf) })
+	if i > 0 {
+		res.Balance = u.snapshots[i-1].Balance
+		res.TransactionSeq = u.snapshots[i-1].TransactionSeq
+	}
+
+	seq := res.TransactionSeq
+	for _, t := range u.txs {
+		if t.ID <= seq || t.CreatedAt.After(asOf) {
+			continue
+		}
+		res.Balance += t.Amount
+		res.TransactionSeq = t.ID
+	}
+	return res, nil
+}
+
+// SaveBalanceSnapshot saves the snapshot of a user. it returns false if the user already has a snapshot as of
+// the same time
+func (r *memoryUserRepository) SaveBalanceSnapshot(ctx context.Context, s *model.BalanceSnapshot) (bool, error) {
+	s.AsOf = ledger.Timestamp(s.AsOf)
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = ledger.Timestamp(time.Now())
+	}
+
+	u, err := r.user(s.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	i := sort.Search(len(u.snapshots), func(i int) bool { return !u.snapshots[i].AsOf.Before(s.AsOf) })
+	if i < len(u.snapshots) && u.snapshots[i].AsOf.Equal(s.AsOf) {
+		return false, nil
+	}
+
+	sn := *s
+	u.snapshots = append(u.snapshots, nil)
+	copy(u.snapshots[i+1:], u.snapshots[i:])
+	u.snapshots[i] = &sn
+	return true, nil
+}
+
+// FindTransactions returns the transactions of any user with the transaction ids, in id order
+func (r *memoryUserRepository) FindTransactions(ctx context.Context, transactionIDs []string) ([]*model.Transaction, error) {
+	ids := make(map[string]bool, len(transactionIDs))
+	for _, id := range transactionIDs {
+		ids[id] = true
+	}
+
+	return r.findTransactions(func(t *model.Transaction) bool { return ids[t.TransactionID] }, 0), nil
+}
+
+// ListTransactionsPosted returns up to limit transactions of any user created in [from, to) with an id greater
+// than afterID, in id order
+func (r *memoryUserRepository) ListTransactionsPosted(ctx context.Context, from, to time.Time, afterID uint, limit int64) ([]*model.Transaction, error) {
+	return r.findTransactions(func(t *model.Transaction) bool {
+		return t.ID > afterID && !t.CreatedAt.Before(from) && t.CreatedAt.Before(to)
+	}, int(limit)), nil
+}
+
+// CheckBalances returns up to limit users with an id greater than afterID, in id order, with their balance next to
+// the balance recomputed from the history
+func (r *memoryUserRepository) CheckBalances(ctx context.Context, afterID string, limit int64) ([]*model.BalanceCheck, error) {
+	r.mu.RLock()
+	ids := make([]string, 0, len(r.users))
+	for id := range r.users {
+		if id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Strings(ids)
+	if int64(len(ids)) > limit {
+		ids = ids[:limit]
+	}
+
+	res := make([]*model.BalanceCheck, 0, len(ids))
+	for _, id := range ids {
+		u, err := r.user(id)
+		if err != nil {
+			return nil, err
+		}
+
+		u.mu.Lock()
+		c := &model.BalanceCheck{UserID: id, Balance: u.user.Balance, Expected: u.user.OpeningBalance}
+		for _, t := range u.txs {
+			c.Expected += t.Amount
+			c.TransactionSeq = t.ID
+		}
+		u.mu.Unlock()
+
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+// RecordAdjustment records the drift of the balance found by c as a transaction of the user, without changing the
+// balance. it returns ErrLedgerChanged if the balance or the history of the user changed since c was made
+func (r *memoryUserRepository) RecordAdjustment(ctx context.Context, c *model.BalanceCheck, transactionID string) (*model.Transaction, error) {
+	u, err := r.user(c.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var last model.Transaction
+	if n := len(u.txs); n > 0 {
+		last = *u.txs[n-1]
+	}
+
+	if u.user.Balance != c.Balance || last.ID != c.TransactionSeq {
+		return nil, response.WrapError(ErrLedgerChanged, http.StatusConflict, response.CodeConflict)
+	}
+
+	r.mu.Lock()
+	if r.txIDs[transactionID] {
+		r.mu.Unlock()
+		return nil, response.WrapError(ErrTransactionProcessed, http.StatusUnprocessableEntity, response.CodeTransactionProcessed)
+	}
+	r.txIDs[transactionID] = true
+	r.mu.Unlock()
+
+	t := r.append(u, ledger.Timestamp(time.Now()), transactionID, c.Balance-c.Expected, 0)
+
+	tr := *t
+	return &tr, nil
+}
+
+// findTransactions returns copies of up to limit transactions of any user matching fn in id order, all of them if
+// limit is 0
+func (r *memoryUserRepository) findTransactions(fn func(t *model.Transaction) bool, limit int) []*model.Transaction {
+	r.mu.RLock()
+	userIDs := make([]string, 0, len(r.users))
+	for id := range r.users {
+		userIDs = append(userIDs, id)
+	}
+	r.mu.RUnlock()
+
+	res := make([]*model.Transaction, 0)
+	for _, id := range userIDs {
+		for _, t := range r.transactions(id) {
+			if fn(t) {
+				res = append(res, t)
+			}
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+// transactions returns copies of the transactions of a user in id order, none if the user doesn't exist
+func (r *memoryUserRepository) transactions(userID string) []*model.Transaction {
+	u, err := r.user(userID)
+	if err != nil {
+		return nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	res := make([]*model.Transaction, len(u.txs))
+	for i, t := range u.txs {
+		tr := *t
+		res[i] = &tr
+	}
+	return res
+}
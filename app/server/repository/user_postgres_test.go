@@ -0,0 +1,67 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/repository/repotest"
+	"github.com/diptomondal007/your-money/infrastructure/conn"
+)
+
+func TestPostgresUserRepository(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	repotest.UserRepository(t, newPostgresRepo)
+}
+
+func TestPostgresUserRepositoryConcurrency(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	repotest.Concurrency(t, newPostgresRepo)
+}
+
+// newPostgresRepo returns a repo of the test db holding users. the pool is limited below the connection limit of
+// postgres, the concurrent callers wait for a connection
+func newPostgresRepo(t *testing.T, users ...*model.User) repository.UserRepository {
+	require.NoError(t, conn.ConnectDB())
+	db := conn.GetDB().DB
+	db.SetMaxOpenConns(20)
+
+	rows := make([]interface{}, len(users))
+	for i, u := range users {
+		rows[i] = u
+	}
+
+	q, _, err := goqu.Insert(goqu.T(model.TableUsers)).Rows(rows...).ToSQL()
+	require.NoError(t, err)
+
+	_, err = db.Exec(q)
+	require.NoError(t, err)
+
+	return repository.NewUserRepo(db)
+}
@@ -0,0 +1,278 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/dialect/sqlite3"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/diptomondal007/your-money/app/server/ledger"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/tracing"
+)
+
+// sqliteDialect is the sqlite3 dialect writing times with a fixed width. sqlite keeps times as text, so they are
+// only compared in time order when they are all of the same width and zone
+const sqliteDialect = "your-money-sqlite3"
+
+func init() {
+	opts := sqlite3.DialectOptions()
+	opts.TimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+	goqu.RegisterDialect(sqliteDialect, opts)
+}
+
+// sqliteUserRepository keeps the users and their transactions in sqlite. sqlite has no row locks, the postings
+// are serialized by the write lock of the db instead, which every transaction takes when it begins. it keeps no
+// outbox and no audit events, so the server refuses to use it unless the features needing them are turned off,
+// see config.Unsupported
+type sqliteUserRepository struct {
+	userRepository
+}
+
+// NewSQLiteUserRepo returns a new sqlite user repo. db must be opened by the dsn of conn.SQLiteDSN, so its
+// transactions are begun immediate
+func NewSQLiteUserRepo(db *sqlx.DB) UserRepository {
+	return &sqliteUserRepository{userRepository{db: db, dialect: goqu.Dialect(sqliteDialect)}}
+}
+
+// AddBalance credits amount to a user and charges the fee. the transaction holds the write lock of the db from its
+// beginning, so the user, the check of the transaction id and the last transactions can't change until it's committed
+func (u sqliteUserRepository) AddBalance(ctx context.Context, userID string, transactionID string, amount float64, fee *model.Fee) (_ *model.User, err error) {
+	ctx, span := tracer.Start(ctx, "sqliteUserRepository.AddBalance")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := u.getUser(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Status == model.UserStatusFrozen {
+		return nil, response.WrapError(ErrUserFrozen, http.StatusUnprocessableEntity, response.CodeUserFrozen)
+	}
+
+	var count int64
+	q, _, err := u.dialect.From(goqu.T(model.TableTransactions).As("t")).
+		Select(goqu.COUNT("*")).
+		Where(goqu.Ex{"transaction_id": goqu.Op{"eq": transactionID}}).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = get(ctx, tx, "find transaction", &count, q); err != nil {
+		return nil, err
+	}
+
+	if count > 0 {
+		return nil, response.WrapError(ErrTransactionProcessed, http.StatusUnprocessableEntity, response.CodeTransactionProcessed)
+	}
+
+	last, err := u.lastTransaction(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.addToBalance(ctx, tx, userID, amount-fee.GetAmount()); err != nil {
+		return nil, err
+	}
+
+	t := model.Transaction{
+		CreatedAt:     ledger.Timestamp(time.Now()),
+		Amount:        amount,
+		UserID:        userID,
+		TransactionID: transactionID,
+		PrevHash:      last.Hash,
+		Fee:           fee.GetAmount(),
+	}
+	t.Hash = ledger.Hash(&t)
+
+	q, _, err = u.dialect.Insert(goqu.T(model.TableTransactions)).Rows(t).ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = exec(ctx, tx, "insert transaction", q); err != nil {
+		return nil, err
+	}
+
+	if fee != nil {
+		if _, err = u.chargeFee(ctx, tx, &t, fee, false); err != nil {
+			return nil, err
+		}
+	}
+
+	updatedUser, err := u.getUser(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return updatedUser, nil
+}
+
+// SetUserStatus updates the status of a user, ex - freezes the account
+func (u sqliteUserRepository) SetUserStatus(ctx context.Context, userID string, status string) (*model.User, error) {
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := u.getUser(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Status = status
+	user.UpdatedAt = time.Now().UTC()
+
+	q, _, err := u.dialect.Update(model.TableUsers).
+		Set(goqu.Record{"status": user.Status, "updated_at": user.UpdatedAt}).
+		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = exec(ctx, tx, "update user status", q); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetFeeSchedule assigns a fee schedule to a user, the default schedule applies if it's empty
+func (u sqliteUserRepository) SetFeeSchedule(ctx context.Context, userID string, schedule string) (*model.User, error) {
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := u.getUser(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.FeeSchedule = schedule
+	user.UpdatedAt = time.Now().UTC()
+
+	q, _, err := u.dialect.Update(model.TableUsers).
+		Set(goqu.Record{"fee_schedule": user.FeeSchedule, "updated_at": user.UpdatedAt}).
+		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = exec(ctx, tx, "update fee schedule", q); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUser creates a user
+func (u sqliteUserRepository) CreateUser(ctx context.Context, user *model.User) error {
+	q, _, err := u.dialect.Insert(goqu.T(model.TableUsers)).Rows(user).OnConflict(goqu.DoNothing()).ToSQL()
+	if err != nil {
+		return err
+	}
+
+	n, err := execAffected(ctx, u.db, "insert user", q)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return response.WrapError(ErrUserExists, http.StatusConflict, response.CodeConflict)
+	}
+	return nil
+}
+
+// RecordAdjustment records the drift of the balance found by c as a transaction of the user, without changing the
+// balance. it returns ErrLedgerChanged if the balance or the history of the user changed since c was made
+func (u sqliteUserRepository) RecordAdjustment(ctx context.Context, c *model.BalanceCheck, transactionID string) (_ *model.Transaction, err error) {
+	ctx, span := tracer.Start(ctx, "sqliteUserRepository.RecordAdjustment")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := u.getUser(ctx, tx, c.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := u.insertAdjustment(ctx, tx, user, c, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// getUser returns a user in the transaction tx
+func (u sqliteUserRepository) getUser(ctx context.Context, tx *sqlx.Tx, userID string) (*model.User, error) {
+	user := &model.User{}
+
+	q, _, err := u.dialect.From(goqu.T(model.TableUsers).As("u")).
+		Select("u.*").
+		Where(goqu.Ex{"id": goqu.Op{"eq": userID}}).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := get(ctx, tx, "get user", user, q); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, response.WrapError(fmt.Errorf("user not found"), http.StatusNotFound, response.CodeUserNotFound)
+		}
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,60 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/repository/repotest"
+	"github.com/diptomondal007/your-money/infrastructure/conn"
+)
+
+func TestSQLiteUserRepository(t *testing.T) {
+	repotest.UserRepository(t, newSQLiteRepo)
+}
+
+func TestSQLiteUserRepositoryConcurrency(t *testing.T) {
+	repotest.Concurrency(t, newSQLiteRepo)
+}
+
+// newSQLiteRepo returns a repo of a new sqlite db holding users
+func newSQLiteRepo(t *testing.T, users ...*model.User) repository.UserRepository {
+	db, err := conn.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "your-money.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	rows := make([]interface{}, len(users))
+	for i, u := range users {
+		rows[i] = u
+	}
+
+	q, _, err := goqu.Dialect("sqlite3").Insert(goqu.T(model.TableUsers)).Rows(rows...).ToSQL()
+	require.NoError(t, err)
+
+	_, err = db.Exec(q)
+	require.NoError(t, err)
+
+	return repository.NewSQLiteUserRepo(db)
+}
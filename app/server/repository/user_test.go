@@ -18,15 +18,22 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"net/http"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/model"
 	"github.com/diptomondal007/your-money/app/utils"
+	"github.com/diptomondal007/your-money/app/utils/response"
 )
 
 func TestCheckBalance(t *testing.T) {
@@ -45,7 +52,7 @@ func TestCheckBalance(t *testing.T) {
 
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows)
 
-	user, err := ur.GetUserInfo(id)
+	user, err := ur.GetUserInfo(context.Background(), id)
 
 	assert.NoError(t, err)
 
@@ -72,7 +79,7 @@ func TestGetHistoryCount(t *testing.T) {
 
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows)
 
-	count, err := ur.GetHistoryCount(id)
+	count, err := ur.GetHistoryCount(context.Background(), id)
 
 	assert.NoError(t, err)
 	assert.Equal(t, count, int64(2))
@@ -95,7 +102,7 @@ func TestGetHistoryList(t *testing.T) {
 
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows)
 
-	list, err := ur.GetHistoryList(id, int64(pageSize), cursor)
+	list, err := ur.GetHistoryList(context.Background(), id, int64(pageSize), cursor)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(list), 1)
@@ -122,20 +129,330 @@ func TestAddBalance(t *testing.T) {
 	//mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(uRows)
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(sql.ErrNoRows)
 
+	query = `SELECT "t".* FROM "transactions" AS "t" WHERE ("user_id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809') ORDER BY "t"."id" DESC LIMIT 1`
+	tRows := sqlmock.NewRows([]string{"id", "transaction_id", "hash"}).AddRow(3, "tx_prev", strings.Repeat("a", 64))
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(tRows)
+
 	query = `UPDATE "users" SET "balance"=balance + 10 WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`
 	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 1))
 
-	query = `INSERT INTO "transactions" ("amount", "created_at", "transaction_id", "user_id")`
-	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewErrorResult(nil))
+	// the new transaction is chained to the last one
+	mock.ExpectExec(`INSERT INTO "transactions" \("amount", "created_at", "fee", "hash", "prev_hash", "transaction_id", "user_id"\) VALUES \(10, '[^']+', 0, '[0-9a-f]{64}', '` + strings.Repeat("a", 64) + `', 'tx_1as4ndakda', '6d7750a1-c3f2-4765-bf8f-33bc80f3f809'\)`).
+		WillReturnResult(sqlmock.NewErrorResult(nil))
 
 	query = `SELECT "u".* FROM "users" AS "u" WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`
 	uRows = sqlmock.NewRows([]string{"id", "name", "balance"}).AddRow("6d7750a1-c3f2-4765-bf8f-33bc80f3f809", "Test", 110.10)
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(uRows)
 
+	mock.ExpectExec(`INSERT INTO "outbox_events" .* VALUES \('6d7750a1-c3f2-4765-bf8f-33bc80f3f809', '[^']+', NULL, 'balance\.credited', '{"user_id":"6d7750a1-c3f2-4765-bf8f-33bc80f3f809","transaction_id":"tx_1as4ndakda","amount":10,"balance":110.1}'\)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec(`INSERT INTO "audit_events" .* VALUES \('balance\.credit', 'system', 'system', 110\.1, 100\.1, .*'success', '', NULL, 'user:6d7750a1-c3f2-4765-bf8f-33bc80f3f809', NULL\)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
 	mock.ExpectCommit()
 
-	user, err := ur.AddBalance(id, "tx_1as4ndakda", 10)
+	user, err := ur.AddBalance(context.Background(), id, "tx_1as4ndakda", 10, nil)
 
 	assert.NoError(t, err)
 	assert.Equal(t, user.Balance, 110.1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddBalanceWithFee(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ur := NewUserRepo(db)
+
+	id := "6d7750a1-c3f2-4765-bf8f-33bc80f3f809"
+	fee := &model.Fee{Amount: 0.5, Schedule: "standard", HouseAccount: "house"}
+
+	mock.ExpectBegin()
+	query := `SELECT "u".* FROM "users" AS "u" WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809') FOR UPDATE`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "balance"}).AddRow(id, "Test", 100))
+
+	query = `SELECT "t".* FROM "transactions" AS "t" WHERE ("transaction_id" = 'tx_1as4ndakda')`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(sql.ErrNoRows)
+
+	query = `SELECT "t".* FROM "transactions" AS "t" WHERE ("user_id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809') ORDER BY "t"."id" DESC LIMIT 1`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(sql.ErrNoRows)
+
+	// the user is credited the amount net of the fee
+	query = `UPDATE "users" SET "balance"=balance + 9.5 WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec(`INSERT INTO "transactions" \("amount", "created_at", "fee", "hash", "prev_hash", "transaction_id", "user_id"\) VALUES \(10, '[^']+', 0\.5, '([0-9a-f]{64})', '', 'tx_1as4ndakda', '6d7750a1-c3f2-4765-bf8f-33bc80f3f809'\)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec(`INSERT INTO "transactions" .* VALUES \(-0\.5, '[^']+', 0, '[0-9a-f]{64}', '[0-9a-f]{64}', 'fee_tx_1as4ndakda', '6d7750a1-c3f2-4765-bf8f-33bc80f3f809'\)`).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	query = `SELECT "u".* FROM "users" AS "u" WHERE ("id" = 'house') FOR UPDATE`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "balance"}).AddRow("house", "House", 20))
+
+	query = `SELECT "t".* FROM "transactions" AS "t" WHERE ("user_id" = 'house') ORDER BY "t"."id" DESC LIMIT 1`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "hash"}).AddRow(1, "fee_house_tx_prev", strings.Repeat("b", 64)))
+
+	mock.ExpectExec(`INSERT INTO "transactions" .* VALUES \(0\.5, '[^']+', 0, '[0-9a-f]{64}', '` + strings.Repeat("b", 64) + `', 'fee_house_tx_1as4ndakda', 'house'\)`).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	query = `UPDATE "users" SET "balance"=balance + 0.5 WHERE ("id" = 'house')`
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	query = `SELECT "u".* FROM "users" AS "u" WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "balance"}).AddRow(id, "Test", 109.5))
+
+	mock.ExpectExec(`INSERT INTO "outbox_events" .* VALUES \('6d7750a1-c3f2-4765-bf8f-33bc80f3f809', '[^']+', NULL, 'balance\.credited', '{"user_id":"6d7750a1-c3f2-4765-bf8f-33bc80f3f809","transaction_id":"tx_1as4ndakda","amount":10,"fee":0.5,"balance":109.5}'\)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "outbox_events" .* VALUES \('house', '[^']+', NULL, 'balance\.credited', '{"user_id":"house","transaction_id":"fee_house_tx_1as4ndakda","amount":0.5,"balance":20.5}'\)`).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	mock.ExpectExec(`INSERT INTO "audit_events" .* VALUES \('balance\.credit', 'system', 'system', 109\.5, 100, .*"fee":0\.5,"fee_schedule":"standard".*'success'`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectCommit()
+
+	user, err := ur.AddBalance(context.Background(), id, "tx_1as4ndakda", 10, fee)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 109.5, user.Balance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddBalanceTransactionIDRaced(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ur := NewUserRepo(db)
+
+	id := "6d7750a1-c3f2-4765-bf8f-33bc80f3f809"
+
+	uRows := sqlmock.NewRows([]string{"id", "name", "balance"}).AddRow(id, "Test", 100.10)
+
+	mock.ExpectBegin()
+	query := `SELECT "u".* FROM "users" AS "u" WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809') FOR UPDATE`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(uRows)
+
+	query = `SELECT "t".* FROM "transactions" AS "t" WHERE ("transaction_id" = 'tx_1as4ndakda')`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(sql.ErrNoRows)
+
+	query = `SELECT "t".* FROM "transactions" AS "t" WHERE ("user_id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809') ORDER BY "t"."id" DESC LIMIT 1`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(sql.ErrNoRows)
+
+	query = `UPDATE "users" SET "balance"=balance + 10 WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// a credit of another user committed the transaction id after it was looked up
+	mock.ExpectExec(`INSERT INTO "transactions"`).
+		WillReturnError(&pq.Error{Code: "23505", Message: `duplicate key value violates unique constraint "transactions_transaction_id_key"`})
+	mock.ExpectRollback()
+	mock.ExpectExec(`INSERT INTO "audit_events" .* VALUES \('balance\.credit', 'system', 'system', NULL, NULL, .*transaction was already processed.*'failed'`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	_, err := ur.AddBalance(context.Background(), id, "tx_1as4ndakda", 10, nil)
+
+	var wrapped response.WrapErr
+	if assert.ErrorAs(t, err, &wrapped) {
+		assert.Equal(t, http.StatusUnprocessableEntity, wrapped.StatusCode)
+		assert.Equal(t, response.CodeTransactionProcessed, wrapped.ErrCode)
+	}
+	assert.ErrorIs(t, err, ErrTransactionProcessed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddBalanceUserFrozen(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ur := NewUserRepo(db)
+
+	id := "6d7750a1-c3f2-4765-bf8f-33bc80f3f809"
+
+	uRows := sqlmock.NewRows([]string{"id", "name", "balance", "status"}).AddRow(id, "Test", 100.10, "frozen")
+
+	mock.ExpectBegin()
+	query := `SELECT "u".* FROM "users" AS "u" WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809') FOR UPDATE`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(uRows)
+	mock.ExpectRollback()
+	mock.ExpectExec(`INSERT INTO "audit_events" .* VALUES \('balance\.credit', 'system', 'system', NULL, NULL, .*user account is frozen.*'failed'`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	_, err := ur.AddBalance(context.Background(), id, "tx_1as4ndakda", 10, nil)
+
+	assert.ErrorIs(t, err, ErrUserFrozen)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetUserStatus(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ur := NewUserRepo(db)
+
+	id := "6d7750a1-c3f2-4765-bf8f-33bc80f3f809"
+
+	uRows := sqlmock.NewRows([]string{"id", "name", "balance", "status"}).AddRow(id, "Test", 100.10, "active")
+
+	mock.ExpectBegin()
+	query := `SELECT "u".* FROM "users" AS "u" WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809') FOR UPDATE`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(uRows)
+
+	query = `UPDATE "users" SET "status"='frozen'`
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec(`INSERT INTO "audit_events" .* VALUES \('user\.status_change', 'payments', 'service', 100\.1, 100\.1, .*"after_status":"frozen","before_status":"active".*'success', '', NULL, 'user:6d7750a1-c3f2-4765-bf8f-33bc80f3f809', '10\.0\.0\.1'\)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	ctx := audit.WithCall(context.Background(), audit.Call{ActorKind: "service", ActorID: "payments", SourceIP: "10.0.0.1"})
+
+	user, err := ur.SetUserStatus(ctx, id, "frozen")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "frozen", user.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUser(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ur := NewUserRepo(db)
+
+	now := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	user := &model.User{ID: "6d7750a1-c3f2-4765-bf8f-33bc80f3f80b", CreatedAt: now, UpdatedAt: now, Name: "New", Balance: 50, OpeningBalance: 50, Status: model.UserStatusActive}
+
+	mock.ExpectBegin()
+	query := `INSERT INTO "users" ("balance", "created_at", "fee_schedule", "id", "name", "opening_balance", "status", "updated_at") VALUES (50, '2026-10-01T00:00:00Z', '', '6d7750a1-c3f2-4765-bf8f-33bc80f3f80b', 'New', 50, 'active', '2026-10-01T00:00:00Z') ON CONFLICT DO NOTHING`
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "audit_events" .* VALUES \('user\.create', 'system', 'system', 50, NULL, .*"name":"New","opening_balance":50.*'success', '', NULL, 'user:6d7750a1-c3f2-4765-bf8f-33bc80f3f80b', NULL\)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, ur.CreateUser(context.Background(), user))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUserExists(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ur := NewUserRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "users" .* ON CONFLICT DO NOTHING`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectExec(`INSERT INTO "audit_events" .* VALUES \('user\.create', 'system', 'system', NULL, NULL, .*user already exists.*'failed'`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := ur.CreateUser(context.Background(), &model.User{ID: "6d7750a1-c3f2-4765-bf8f-33bc80f3f809", Name: "Test"})
+
+	assert.ErrorIs(t, err, ErrUserExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBalanceAsOf(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ur := NewUserRepo(db)
+
+	id := "6d7750a1-c3f2-4765-bf8f-33bc80f3f809"
+	asOf := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "u".* FROM "users" AS "u" WHERE ("id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "balance", "opening_balance"}).AddRow(id, "Test", 130, 100))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "s".* FROM "balance_snapshots" AS "s" WHERE (("as_of" <= '2024-01-31T00:00:00Z') AND ("user_id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809')) ORDER BY "s"."as_of" DESC LIMIT 1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "as_of", "transaction_seq", "balance"}).AddRow(1, id, asOf.AddDate(0, 0, -1), 7, 110))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM("t"."amount"), 0) AS "amount", COALESCE(MAX("t"."id"), 0) AS "seq" FROM "transactions" AS "t" WHERE (("created_at" <= '2024-01-31T00:00:00Z') AND ("id" > 7) AND ("user_id" = '6d7750a1-c3f2-4765-bf8f-33bc80f3f809'))`)).
+		WillReturnRows(sqlmock.NewRows([]string{"amount", "seq"}).AddRow(5, 9))
+
+	s, err := ur.GetBalanceAsOf(context.Background(), id, asOf)
+
+	assert.NoError(t, err)
+	assert.Equal(t, float64(115), s.Balance)
+	assert.Equal(t, uint(9), s.TransactionSeq)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveBalanceSnapshot(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ur := NewUserRepo(db)
+
+	mock.ExpectExec(`INSERT INTO "balance_snapshots" \("as_of", "balance", "created_at", "transaction_seq", "user_id"\) VALUES \('2024-01-31T00:00:00Z', 115, '.*', 9, '6d7750a1-c3f2-4765-bf8f-33bc80f3f809'\) ON CONFLICT DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ok, err := ur.SaveBalanceSnapshot(context.Background(), &model.BalanceSnapshot{
+		UserID:         "6d7750a1-c3f2-4765-bf8f-33bc80f3f809",
+		AsOf:           time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		TransactionSeq: 9,
+		Balance:        115,
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListTransactionsPosted(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ur := NewUserRepo(db)
+
+	from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "t".* FROM "transactions" AS "t" WHERE (("id" > 7) AND ("created_at" >= '2024-02-01T00:00:00Z') AND ("created_at" < '2024-02-02T00:00:00Z')) ORDER BY "t"."id" ASC LIMIT 100`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "amount"}).AddRow(8, "tx_8", 10).AddRow(9, "tx_9", 5))
+
+	ts, err := ur.ListTransactionsPosted(context.Background(), from, from.Add(24*time.Hour), 7, 100)
+
+	assert.NoError(t, err)
+	assert.Len(t, ts, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckBalances(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ur := NewUserRepo(db)
+
+	// the balance and the recomputed balance are read by one statement
+	query := `SELECT "u"."id" AS "user_id", "u"."balance", "u"."opening_balance" + (SELECT COALESCE(SUM("t"."amount"), 0) FROM "transactions" AS "t" WHERE ("t"."user_id" = "u"."id")) AS "expected", ` +
+		`(SELECT COALESCE(MAX("t"."id"), 0) FROM "transactions" AS "t" WHERE ("t"."user_id" = "u"."id")) AS "transaction_seq" FROM "users" AS "u" WHERE ("u"."id" > 'u0') ORDER BY "u"."id" ASC LIMIT 100`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "balance", "expected", "transaction_seq"}).AddRow("u1", 110, 100, 4))
+
+	cs, err := ur.CheckBalances(context.Background(), "u0", 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.BalanceCheck{{UserID: "u1", Balance: 110, Expected: 100, TransactionSeq: 4}}, cs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordAdjustmentLedgerChanged(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	ur := NewUserRepo(db)
+
+	mock.ExpectBegin()
+	query := `SELECT "u".* FROM "users" AS "u" WHERE ("id" = 'u1') FOR UPDATE`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).AddRow("u1", 110))
+
+	// a transaction was posted since the check
+	query = `SELECT "t".* FROM "transactions" AS "t" WHERE ("user_id" = 'u1') ORDER BY "t"."id" DESC LIMIT 1`
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id"}).AddRow(5, "tx_5"))
+	mock.ExpectRollback()
+	mock.ExpectExec(`INSERT INTO "audit_events" .* VALUES \('ledger\.adjust', 'system', 'system', NULL, NULL, .*changed since it was checked.*'failed'`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	_, err := ur.RecordAdjustment(context.Background(), &model.BalanceCheck{UserID: "u1", Balance: 110, Expected: 100, TransactionSeq: 4}, "adj_1")
+
+	assert.ErrorIs(t, err, ErrLedgerChanged)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -0,0 +1,480 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+var (
+	// ErrWebhookNotFound is returned when no webhook exists with the given id
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// webhookRepository ...
+type webhookRepository struct {
+	db *sqlx.DB
+}
+
+// WebhookRepository ...
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, wh *model.Webhook) error
+	GetWebhook(ctx context.Context, id uint) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*model.Webhook, error)
+	UpdateWebhook(ctx context.Context, wh *model.Webhook) error
+	DeleteWebhook(ctx context.Context, id uint) error
+
+	ListDeliveries(ctx context.Context, webhookID uint, status string, pageSize int64, cursor string) ([]*model.WebhookDelivery, error)
+	ReplayDeliveries(ctx context.Context, webhookID uint, deliveryID uint) (int64, error)
+
+	FanOutEvents(ctx context.Context, limit int) (int, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.DeliveryJob, error)
+	CompleteDelivery(ctx context.Context, d *model.WebhookDelivery) error
+}
+
+// NewWebhookRepo returns a new webhook repo instance
+func NewWebhookRepo(db *sqlx.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func webhookResource(id uint) string {
+	return "webhook:" + strconv.FormatUint(uint64(id), 10)
+}
+
+// CreateWebhook stores a new webhook subscription and sets its id. the secret is never audited
+func (w webhookRepository) CreateWebhook(ctx context.Context, wh *model.Webhook) (err error) {
+	details := map[string]interface{}{"url": wh.URL, "event_types": wh.EventTypes}
+	defer func() {
+		if err != nil {
+			auditFailure(ctx, w.db, audit.ActionWebhookCreate, "webhook", details, err)
+		}
+	}()
+
+	tx, err := w.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q, _, err := goqu.Insert(goqu.T(model.TableWebhooks)).Rows(wh).Returning("id").ToSQL()
+	if err != nil {
+		return err
+	}
+
+	if err = get(ctx, tx, "insert webhook", &wh.ID, q); err != nil {
+		return err
+	}
+
+	ev := audit.NewEvent(ctx, audit.ActionWebhookCreate, webhookResource(wh.ID), model.AuditOutcomeSuccess, details)
+	if err = appendAuditEvent(ctx, tx, ev); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetWebhook fetches a webhook by id
+func (w webhookRepository) GetWebhook(ctx context.Context, id uint) (*model.Webhook, error) {
+	wh := &model.Webhook{}
+
+	q, _, err := goqu.From(goqu.T(model.TableWebhooks).As("w")).
+		Select("w.*").
+		Where(goqu.Ex{"id": goqu.Op{"eq": id}}).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := get(ctx, w.db, "get webhook", wh, q); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, response.WrapError(ErrWebhookNotFound, http.StatusNotFound, response.CodeWebhookNotFound)
+		}
+		return nil, err
+	}
+	return wh, nil
+}
+
+// ListWebhooks returns all the webhooks
+func (w webhookRepository) ListWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	res := make([]*model.Webhook, 0)
+
+	q, _, err := goqu.From(goqu.T(model.TableWebhooks).As("w")).
+		Select("w.*").
+		Order(goqu.I("w.id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = selectAll(ctx, w.db, "list webhooks", &res, q); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateWebhook updates the url, subscribed event types and the active flag of a webhook
+func (w webhookRepository) UpdateWebhook(ctx context.Context, wh *model.Webhook) (err error) {
+	details := map[string]interface{}{"url": wh.URL, "event_types": wh.EventTypes, "active": wh.Active}
+	defer func() {
+		if err != nil {
+			auditFailure(ctx, w.db, audit.ActionWebhookUpdate, webhookResource(wh.ID), details, err)
+		}
+	}()
+
+	tx, err := w.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q, _, err := goqu.Update(model.TableWebhooks).
+		Set(goqu.Record{"url": wh.URL, "event_types": wh.EventTypes, "active": wh.Active, "updated_at": wh.UpdatedAt}).
+		Where(goqu.Ex{"id": goqu.Op{"eq": wh.ID}}).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+
+	affected, err := execAffected(ctx, tx, "update webhook", q)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return response.WrapError(ErrWebhookNotFound, http.StatusNotFound, response.CodeWebhookNotFound)
+	}
+
+	ev := audit.NewEvent(ctx, audit.ActionWebhookUpdate, webhookResource(wh.ID), model.AuditOutcomeSuccess, details)
+	if err = appendAuditEvent(ctx, tx, ev); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteWebhook removes a webhook together with its deliveries
+func (w webhookRepository) DeleteWebhook(ctx context.Context, id uint) (err error) {
+	defer func() {
+		if err != nil {
+			auditFailure(ctx, w.db, audit.ActionWebhookDelete, webhookResource(id), map[string]interface{}{}, err)
+		}
+	}()
+
+	tx, err := w.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q, _, err := goqu.Delete(goqu.T(model.TableWebhooks)).
+		Where(goqu.Ex{"id": goqu.Op{"eq": id}}).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+
+	affected, err := execAffected(ctx, tx, "delete webhook", q)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return response.WrapError(ErrWebhookNotFound, http.StatusNotFound, response.CodeWebhookNotFound)
+	}
+
+	ev := audit.NewEvent(ctx, audit.ActionWebhookDelete, webhookResource(id), model.AuditOutcomeSuccess, map[string]interface{}{})
+	if err = appendAuditEvent(ctx, tx, ev); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListDeliveries returns the deliveries of a webhook, newest first. status is optional
+func (w webhookRepository) ListDeliveries(ctx context.Context, webhookID uint, status string, pageSize int64, cursor string) ([]*model.WebhookDelivery, error) {
+	res := make([]*model.WebhookDelivery, 0)
+
+	ex := goqu.Ex{"webhook_id": goqu.Op{"eq": webhookID}}
+	if status != "" {
+		ex["status"] = status
+	}
+
+	d := goqu.From(goqu.T(model.TableDeliveries).As("d")).
+		Select("d.*").
+		Where(ex)
+
+	if cursor != "" {
+		c, err := response.ParseCursor(cursor)
+		if err != nil {
+			return res, response.WrapError(fmt.Errorf("invalid pagination cursor"), http.StatusBadRequest, response.CodeInvalidCursor)
+		}
+
+		d = d.Where(goqu.Ex{"id": goqu.Op{"lt": c.ID}})
+	}
+
+	q, _, err := d.Order(goqu.I("d.id").Desc()).Limit(uint(pageSize)).ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = selectAll(ctx, w.db, "list webhook deliveries", &res, q); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ReplayDeliveries schedules deliveries of a webhook to be sent again right away. if deliveryID is 0
+// every dead delivery of the webhook is replayed. it returns the number of replayed deliveries
+func (w webhookRepository) ReplayDeliveries(ctx context.Context, webhookID uint, deliveryID uint) (_ int64, err error) {
+	details := map[string]interface{}{"delivery_id": deliveryID}
+	defer func() {
+		if err != nil {
+			auditFailure(ctx, w.db, audit.ActionWebhookReplay, webhookResource(webhookID), details, err)
+		}
+	}()
+
+	if _, err = w.GetWebhook(ctx, webhookID); err != nil {
+		return 0, err
+	}
+
+	tx, err := w.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	ex := goqu.Ex{"webhook_id": goqu.Op{"eq": webhookID}}
+	if deliveryID != 0 {
+		ex["id"] = deliveryID
+	} else {
+		ex["status"] = model.DeliveryStatusDead
+	}
+
+	now := time.Now().UTC()
+	q, _, err := goqu.Update(model.TableDeliveries).
+		Set(goqu.Record{"status": model.DeliveryStatusPending, "attempts": 0, "next_attempt_at": now, "updated_at": now}).
+		Where(ex).
+		ToSQL()
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := execAffected(ctx, tx, "replay webhook deliveries", q)
+	if err != nil {
+		return 0, err
+	}
+	if deliveryID != 0 && affected == 0 {
+		return 0, response.WrapError(fmt.Errorf("webhook delivery not found"), http.StatusNotFound, response.CodeDeliveryNotFound)
+	}
+
+	details["replayed"] = affected
+	ev := audit.NewEvent(ctx, audit.ActionWebhookReplay, webhookResource(webhookID), model.AuditOutcomeSuccess, details)
+	if err = appendAuditEvent(ctx, tx, ev); err != nil {
+		return 0, err
+	}
+
+	return affected, tx.Commit()
+}
+
+// FanOutEvents creates a delivery of every undispatched outbox event for each active webhook subscribed to it.
+// the events are locked with skip locked, so that multiple instances can fan out concurrently
+func (w webhookRepository) FanOutEvents(ctx context.Context, limit int) (_ int, err error) {
+	tx, err := w.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	events := make([]*model.OutboxEvent, 0, limit)
+	q, _, err := goqu.From(goqu.T(model.TableOutboxEvents).As("o")).
+		Select("o.*").
+		Where(goqu.Ex{"dispatched_at": goqu.Op{"is": nil}}).
+		Order(goqu.I("o.id").Asc()).
+		Limit(uint(limit)).
+		ForUpdate(exp.SkipLocked).
+		ToSQL()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = selectAll(ctx, tx, "lock outbox events", &events, q); err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	webhooks := make([]*model.Webhook, 0)
+	q, _, err = goqu.From(goqu.T(model.TableWebhooks).As("w")).
+		Select("w.*").
+		Where(goqu.Ex{"active": true}).
+		ToSQL()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = selectAll(ctx, tx, "list active webhooks", &webhooks, q); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	deliveries := make([]model.WebhookDelivery, 0)
+	ids := make([]uint, 0, len(events))
+	for _, ev := range events {
+		ids = append(ids, ev.ID)
+		for _, wh := range webhooks {
+			if !subscribed(wh, ev.EventType) {
+				continue
+			}
+			deliveries = append(deliveries, model.WebhookDelivery{
+				WebhookID:     wh.ID,
+				EventID:       ev.ID,
+				Status:        model.DeliveryStatusPending,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			})
+		}
+	}
+
+	if len(deliveries) > 0 {
+		q, _, err = goqu.Insert(goqu.T(model.TableDeliveries)).
+			Rows(deliveries).
+			OnConflict(goqu.DoNothing()).
+			ToSQL()
+		if err != nil {
+			return 0, err
+		}
+
+		if err = exec(ctx, tx, "insert webhook deliveries", q); err != nil {
+			return 0, err
+		}
+	}
+
+	q, _, err = goqu.Update(model.TableOutboxEvents).
+		Set(goqu.Record{"dispatched_at": now}).
+		Where(goqu.Ex{"id": ids}).
+		ToSQL()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = exec(ctx, tx, "mark outbox events dispatched", q); err != nil {
+		return 0, err
+	}
+
+	return len(events), tx.Commit()
+}
+
+// subscribed reports whether a webhook receives the events of eventType
+func subscribed(wh *model.Webhook, eventType string) bool {
+	if strings.TrimSpace(wh.EventTypes) == "" {
+		return true
+	}
+	for _, t := range strings.Fields(wh.EventTypes) {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimDeliveries claims up to limit due deliveries for lease by pushing their next attempt into the future,
+// so that no other instance picks them up while they are being sent
+func (w webhookRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.DeliveryJob, error) {
+	now := time.Now().UTC()
+
+	due := goqu.From(goqu.T(model.TableDeliveries)).
+		Select("id").
+		Where(goqu.Ex{"status": model.DeliveryStatusPending, "next_attempt_at": goqu.Op{"lte": now}}).
+		Order(goqu.I("next_attempt_at").Asc()).
+		Limit(uint(limit)).
+		ForUpdate(exp.SkipLocked)
+
+	q, _, err := goqu.Update(model.TableDeliveries).
+		Set(goqu.Record{"next_attempt_at": now.Add(lease), "updated_at": now}).
+		Where(goqu.I("id").In(due)).
+		Returning("id").
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, limit)
+	if err = selectAll(ctx, w.db, "claim webhook deliveries", &ids, q); err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*model.DeliveryJob, 0, len(ids))
+	if len(ids) == 0 {
+		return jobs, nil
+	}
+
+	q, _, err = goqu.From(goqu.T(model.TableDeliveries).As("d")).
+		Join(goqu.T(model.TableWebhooks).As("w"), goqu.On(goqu.I("w.id").Eq(goqu.I("d.webhook_id")))).
+		Join(goqu.T(model.TableOutboxEvents).As("o"), goqu.On(goqu.I("o.id").Eq(goqu.I("d.event_id")))).
+		Select(
+			goqu.I("d.id"), goqu.I("d.attempts"), goqu.I("w.url"), goqu.I("w.secret"),
+			goqu.I("o.id").As("event_id"), goqu.I("o.event_type"), goqu.I("o.payload"), goqu.I("o.created_at"),
+		).
+		Where(goqu.Ex{"d.id": ids}).
+		Order(goqu.I("d.id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = selectAll(ctx, w.db, "get webhook delivery jobs", &jobs, q); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// CompleteDelivery stores the outcome of a delivery attempt
+func (w webhookRepository) CompleteDelivery(ctx context.Context, d *model.WebhookDelivery) error {
+	q, _, err := goqu.Update(model.TableDeliveries).
+		Set(goqu.Record{
+			"status":           d.Status,
+			"attempts":         d.Attempts,
+			"next_attempt_at":  d.NextAttemptAt,
+			"last_status_code": d.LastStatusCode,
+			"last_error":       d.LastError,
+			"delivered_at":     d.DeliveredAt,
+			"updated_at":       d.UpdatedAt,
+		}).
+		Where(goqu.Ex{"id": goqu.Op{"eq": d.ID}}).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+
+	return exec(ctx, w.db, "complete webhook delivery", q)
+}
@@ -0,0 +1,88 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/utils"
+)
+
+func TestFanOutEvents(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	wr := NewWebhookRepo(db)
+
+	mock.ExpectBegin()
+
+	query := `SELECT "o".* FROM "outbox_events" AS "o" WHERE ("dispatched_at" IS NULL) ORDER BY "o"."id" ASC LIMIT 10 FOR UPDATE SKIP LOCKED`
+	eRows := sqlmock.NewRows([]string{"id", "event_type", "aggregate_id", "payload"}).
+		AddRow(1, model.EventBalanceCredited, "u1", "{}").
+		AddRow(2, "other.event", "u1", "{}")
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(eRows)
+
+	query = `SELECT "w".* FROM "webhooks" AS "w" WHERE ("active" IS TRUE)`
+	wRows := sqlmock.NewRows([]string{"id", "url", "event_types", "active"}).
+		AddRow(7, "https://a.example.com", model.EventBalanceCredited, true).
+		AddRow(8, "https://b.example.com", "", true)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(wRows)
+
+	// webhook 7 receives only the balance event, webhook 8 every event
+	mock.ExpectExec(`INSERT INTO "webhook_deliveries" .* VALUES \(0, '[^']+', NULL, 1, NULL, NULL, '[^']+', 'pending', '[^']+', 7\), \(0, '[^']+', NULL, 1, NULL, NULL, '[^']+', 'pending', '[^']+', 8\), \(0, '[^']+', NULL, 2, NULL, NULL, '[^']+', 'pending', '[^']+', 8\) ON CONFLICT DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	mock.ExpectExec(`UPDATE "outbox_events" SET "dispatched_at"='[^']+' WHERE \("id" IN \(1, 2\)\)`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	n, err := wr.FanOutEvents(context.Background(), 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimDeliveries(t *testing.T) {
+	db, mock := utils.MockSqlxDB()
+	defer db.Close()
+
+	wr := NewWebhookRepo(db)
+
+	mock.ExpectQuery(`UPDATE "webhook_deliveries" SET "next_attempt_at"='[^']+',"updated_at"='[^']+' WHERE \("id" IN \(\(SELECT "id" FROM "webhook_deliveries" WHERE \(\("next_attempt_at" <= '[^']+'\) AND \("status" = 'pending'\)\) ORDER BY "next_attempt_at" ASC LIMIT 5 FOR UPDATE SKIP LOCKED\)\)\) RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "webhook_deliveries" AS "d" INNER JOIN "webhooks" AS "w" ON ("w"."id" = "d"."webhook_id") INNER JOIN "outbox_events" AS "o" ON ("o"."id" = "d"."event_id") WHERE ("d"."id" IN (3)) ORDER BY "d"."id" ASC`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "attempts", "url", "secret", "event_id", "event_type", "payload", "created_at"}).
+			AddRow(3, 1, "https://a.example.com", "whsec_x", 1, model.EventBalanceCredited, "{}", time.Now().UTC()))
+
+	jobs, err := wr.ClaimDeliveries(context.Background(), 5, time.Minute)
+
+	assert.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "https://a.example.com", jobs[0].URL)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
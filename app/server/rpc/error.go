@@ -0,0 +1,93 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+// httpCodes maps the http status codes of the use case errors to grpc codes
+var httpCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusNotAcceptable:       codes.InvalidArgument,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusUnprocessableEntity: codes.FailedPrecondition,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusInternalServerError: codes.Internal,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+}
+
+// ErrorDomain is the domain of the error info attached to the status errors
+const ErrorDomain = "your-money"
+
+// toStatus converts an error of the use cases to a grpc status error. the message is the one the rest api
+// would respond with, so internal errors are not exposed. the error code is attached as the reason of an
+// error info and the rejected fields of a validation error as a bad request detail
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	statusCode, resp := response.RespondError(err)
+
+	code, ok := httpCodes[statusCode]
+	if !ok {
+		code = codes.Unknown
+		if statusCode >= http.StatusInternalServerError {
+			code = codes.Internal
+		}
+	}
+
+	st := status.New(code, resp.Message)
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: resp.ErrorCode, Domain: ErrorDomain}}
+	if len(resp.Details) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, f := range resp.Details {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Description: f.Message,
+				Reason:      f.Code,
+			})
+		}
+		details = append(details, br)
+	}
+
+	if ds, err := st.WithDetails(details...); err == nil {
+		st = ds
+	}
+	return st.Err()
+}
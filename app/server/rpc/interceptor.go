@@ -0,0 +1,179 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package rpc
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/rpc/pb"
+	"github.com/diptomondal007/your-money/infrastructure/logger"
+)
+
+// metadata keys read from the incoming calls, they carry the same values as the http headers
+const (
+	MetadataAPIKey        = "x-api-key"
+	MetadataAuthorization = "authorization"
+	MetadataRequestID     = "x-request-id"
+
+	MetadataSignatureKeyID     = "x-signature-key-id"
+	MetadataSignatureTimestamp = "x-signature-timestamp"
+	MetadataSignatureNonce     = "x-signature-nonce"
+	MetadataSignature          = "x-signature"
+
+	// MetadataRetryAfter is sent with the calls rejected by the rate limits, in seconds
+	MetadataRetryAfter = "retry-after"
+)
+
+// mutating are the methods whose request is kept as the payload of their audit events
+var mutating = map[string]bool{
+	pb.UserService_AddBalance_FullMethodName: true,
+}
+
+// unaryInterceptor authenticates and logs the unary calls
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+
+	var payload string
+	if m, ok := req.(proto.Message); ok && mutating[info.FullMethod] {
+		b, _ := protojson.Marshal(m)
+		payload = string(b)
+	}
+
+	ctx, err := s.begin(ctx, info.FullMethod, payload)
+	var resp interface{}
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+
+	accessLog(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+// streamInterceptor authenticates and logs the streaming calls
+func (s *Server) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+
+	ctx, err := s.begin(ss.Context(), info.FullMethod, "")
+	if err == nil {
+		err = handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+
+	accessLog(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// begin assigns the request id and authenticates the caller. the returned context carries the logger,
+// the principal and the audit call, like the context of a http request does
+func (s *Server) begin(ctx context.Context, method, payload string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	rid := logger.RequestIDFrom(first(md, MetadataRequestID))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataRequestID, rid))
+
+	l := logger.FromContext(ctx).With(slog.String(logger.KeyRequestID, rid))
+	ctx = logger.WithContext(ctx, l)
+
+	p, err := s.az.AuthenticateCredentials(ctx, first(md, MetadataAPIKey), first(md, MetadataAuthorization))
+	if err != nil {
+		if auth.IsUnauthenticated(err) {
+			l.Warn("authentication failed", slog.String("method", method), slog.Any("error", err))
+			return ctx, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return ctx, toStatus(err)
+	}
+
+	ctx = auth.WithPrincipal(ctx, p)
+	ctx = audit.WithCall(ctx, audit.Call{
+		ActorKind: p.Kind,
+		ActorID:   p.Subject,
+		RequestID: rid,
+		SourceIP:  peerIP(ctx),
+		Payload:   payload,
+	})
+	return ctx, nil
+}
+
+// accessLog writes a structured log line for every call
+func accessLog(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+
+	attrs := []any{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("remote_ip", peerIP(ctx)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+
+	l := logger.FromContext(ctx)
+	switch code {
+	case codes.OK:
+		l.Info("call", attrs...)
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss:
+		l.Error("call", attrs...)
+	default:
+		l.Warn("call", attrs...)
+	}
+}
+
+// serverStream overrides the context of a stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// first returns the first value of a metadata key
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// peerIP returns the ip address of the caller
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,221 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package rpc serves the user api over grpc on top of the same use cases as the rest api
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/ratelimit"
+	"github.com/diptomondal007/your-money/app/server/rpc/pb"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+const (
+	defaultStreamPageSize = 100
+	maxStreamPageSize     = 500
+)
+
+// Server implements the grpc user service
+type Server struct {
+	pb.UnimplementedUserServiceServer
+
+	uc usecase.UserUseCase
+	az *auth.Authorizer
+	rl *ratelimit.Limiter
+}
+
+// NewServer returns a grpc server serving the user service. every method requires the scope of its rest
+// counterpart, end users may access only their own user. every method is rate limited by the rules of its rest
+// route, a stream takes a single token. credits must be signed if request signing is enabled. a nil limiter doesn't
+// limit anything
+func NewServer(uc usecase.UserUseCase, az *auth.Authorizer, rl *ratelimit.Limiter) *grpc.Server {
+	s := &Server{uc: uc, az: az, rl: rl}
+
+	gs := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	pb.RegisterUserServiceServer(gs, s)
+
+	return gs
+}
+
+func (s *Server) AddBalance(ctx context.Context, req *pb.AddBalanceRequest) (*pb.AddBalanceResponse, error) {
+	if err := s.authorize(ctx, pb.UserService_AddBalance_FullMethodName, auth.ScopeBalanceCredit, req.GetUserId()); err != nil {
+		return nil, err
+	}
+	if err := s.limit(ctx, "add", req.GetUserId()); err != nil {
+		return nil, err
+	}
+	if err := s.verifySignature(ctx, pb.UserService_AddBalance_FullMethodName, req); err != nil {
+		return nil, err
+	}
+
+	r := &usecase.AddBalanceReq{TransactionID: req.GetTransactionId(), Amount: req.GetAmount()}
+	if err := r.Validate(); err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp, err := s.uc.AddBalance(ctx, req.GetUserId(), r)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.AddBalanceResponse{CurrentBalance: resp.Balance, Fee: resp.Fee, FeeSchedule: resp.FeeSchedule}, nil
+}
+
+func (s *Server) CheckBalance(ctx context.Context, req *pb.CheckBalanceRequest) (*pb.CheckBalanceResponse, error) {
+	if err := s.authorize(ctx, pb.UserService_CheckBalance_FullMethodName, auth.ScopeBalanceRead, req.GetUserId()); err != nil {
+		return nil, err
+	}
+	if err := s.limit(ctx, "balance", req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.uc.CheckBalance(ctx, req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.CheckBalanceResponse{Balance: resp.Balance}, nil
+}
+
+func (s *Server) ListHistory(ctx context.Context, req *pb.ListHistoryRequest) (*pb.ListHistoryResponse, error) {
+	if err := s.authorize(ctx, pb.UserService_ListHistory_FullMethodName, auth.ScopeHistoryRead, req.GetUserId()); err != nil {
+		return nil, err
+	}
+	if err := s.limit(ctx, "history", req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if req.GetPageSize() < 1 {
+		return nil, status.Error(codes.InvalidArgument, "page size should be greater than 0")
+	}
+
+	resp, err := s.uc.ListHistory(ctx, req.GetUserId(), req.GetPageSize(), req.GetPage())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	histories := make([]*pb.History, 0, len(resp.Histories))
+	for i := range resp.Histories {
+		histories = append(histories, toHistory(&resp.Histories[i]))
+	}
+
+	return &pb.ListHistoryResponse{
+		Total:     resp.Total,
+		PageSize:  resp.PageSize,
+		NextPage:  resp.NextPage,
+		Histories: histories,
+	}, nil
+}
+
+// StreamHistory sends the whole history page by page
+func (s *Server) StreamHistory(req *pb.StreamHistoryRequest, stream grpc.ServerStreamingServer[pb.History]) error {
+	ctx := stream.Context()
+	if err := s.authorize(ctx, pb.UserService_StreamHistory_FullMethodName, auth.ScopeHistoryRead, req.GetUserId()); err != nil {
+		return err
+	}
+	if err := s.limit(ctx, "history", req.GetUserId()); err != nil {
+		return err
+	}
+
+	pageSize := req.GetPageSize()
+	switch {
+	case pageSize < 0 || pageSize > maxStreamPageSize:
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("page size should be between 1 and %d", maxStreamPageSize))
+	case pageSize == 0:
+		pageSize = defaultStreamPageSize
+	}
+
+	cursor := ""
+	for {
+		resp, err := s.uc.ListHistory(ctx, req.GetUserId(), pageSize, cursor)
+		if err != nil {
+			return toStatus(err)
+		}
+
+		for i := range resp.Histories {
+			if err := stream.Send(toHistory(&resp.Histories[i])); err != nil {
+				return err
+			}
+		}
+
+		if int64(len(resp.Histories)) < pageSize {
+			return nil
+		}
+		cursor = resp.NextPage
+	}
+}
+
+// limit takes a token of the rate limits of route for the call
+func (s *Server) limit(ctx context.Context, route, userID string) error {
+	allowed, retryAfter := s.rl.Allow(ctx, route, userID, peerIP(ctx))
+	if allowed {
+		return nil
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataRetryAfter, strconv.Itoa(retryAfter)))
+	return toStatus(response.ErrTooManyRequests)
+}
+
+// verifySignature verifies the signature of a call if request signing is enabled. the signed string is made of
+// POST, the full method name and the protobuf encoding of the request as the body
+func (s *Server) verifySignature(ctx context.Context, method string, req proto.Message) error {
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return toStatus(err)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	sig := auth.Signature{
+		KeyID:     first(md, MetadataSignatureKeyID),
+		Timestamp: first(md, MetadataSignatureTimestamp),
+		Nonce:     first(md, MetadataSignatureNonce),
+		Value:     first(md, MetadataSignature),
+	}
+	return toStatus(s.az.VerifySignature(ctx, sig, http.MethodPost, method, body))
+}
+
+// authorize checks the scope and the user of a call
+func (s *Server) authorize(ctx context.Context, method, scope, userID string) error {
+	if userID == "" {
+		return status.Error(codes.InvalidArgument, "not a valid user id")
+	}
+	return toStatus(s.az.Authorize(ctx, method, scope, userID))
+}
+
+func toHistory(h *usecase.History) *pb.History {
+	return &pb.History{
+		CreatedAt:     timestamppb.New(h.CreatedAt),
+		Amount:        h.Amount,
+		TransactionId: h.TransactionID,
+		Fee:           h.Fee,
+	}
+}
@@ -0,0 +1,390 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/ratelimit"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/rpc/pb"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+const testUserID = "6d7750a1-c3f2-4765-bf8f-33bc80f3f809"
+
+type fakeKeyStore map[string]*model.APIKey
+
+func (f fakeKeyStore) GetAPIKey(ctx context.Context, keyID string) (*model.APIKey, error) {
+	k, ok := f[keyID]
+	if !ok {
+		return nil, repository.ErrAPIKeyNotFound
+	}
+	return k, nil
+}
+
+type fakeAuditLog struct {
+	events []*model.AuditEvent
+}
+
+func (f *fakeAuditLog) AppendAuditEvent(ctx context.Context, ev *model.AuditEvent) error {
+	f.events = append(f.events, ev)
+	return nil
+}
+
+// fakeUserUseCase serves a history of 5 transactions and remembers the audit call of the last credit.
+// the credit tx_fee and the second transaction of the history are charged a fee
+type fakeUserUseCase struct {
+	call audit.Call
+}
+
+func (f *fakeUserUseCase) AddBalance(ctx context.Context, userID string, req *usecase.AddBalanceReq) (*usecase.AddBalanceResp, error) {
+	f.call = audit.CallFrom(ctx)
+	if req.TransactionID == "tx_used" {
+		return nil, response.WrapError(repository.ErrTransactionProcessed, http.StatusUnprocessableEntity, "")
+	}
+	if req.TransactionID == "tx_fee" {
+		return &usecase.AddBalanceResp{Balance: 100 + req.Amount - 1, Fee: 1, FeeSchedule: "flat"}, nil
+	}
+	return &usecase.AddBalanceResp{Balance: 100 + req.Amount}, nil
+}
+
+func (f *fakeUserUseCase) CheckBalance(ctx context.Context, userID string) (*usecase.CheckBalanceResp, error) {
+	if userID != testUserID {
+		return nil, response.WrapError(errors.New("user not found"), http.StatusNotFound, response.CodeUserNotFound)
+	}
+	return &usecase.CheckBalanceResp{Balance: 100}, nil
+}
+
+func (f *fakeUserUseCase) ListHistory(ctx context.Context, userID string, pageSize int64, cursor string) (*usecase.ListHistory, error) {
+	start := 0
+	if cursor != "" {
+		_, _ = fmt.Sscanf(cursor, "%d", &start)
+	}
+
+	res := &usecase.ListHistory{Total: 5, PageSize: pageSize}
+	for i := start; i < 5 && int64(len(res.Histories)) < pageSize; i++ {
+		h := usecase.History{TransactionID: fmt.Sprintf("tx_%d", i), Amount: 1, CreatedAt: time.Now()}
+		if i == 1 {
+			h.Fee = 0.5
+		}
+		res.Histories = append(res.Histories, h)
+	}
+	res.NextPage = fmt.Sprint(start + len(res.Histories))
+	return res, nil
+}
+
+func (f *fakeUserUseCase) CheckBalanceAt(ctx context.Context, userID string, asOf time.Time) (*usecase.CheckBalanceResp, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeUserUseCase) CreateUser(ctx context.Context, req *usecase.CreateUserReq) (*usecase.User, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeUserUseCase) SetStatus(ctx context.Context, userID string, status string) (*usecase.UserStatusResp, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeUserUseCase) SetFeeSchedule(ctx context.Context, userID string, schedule string) (*usecase.FeeScheduleResp, error) {
+	return nil, errors.New("not implemented")
+}
+
+type testEnv struct {
+	client pb.UserServiceClient
+	uc     *fakeUserUseCase
+	audit  *fakeAuditLog
+	keys   map[string]string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	return newTestEnvWith(t, nil, nil)
+}
+
+// newTestEnvWith returns a test env verifying the signatures with sig and limiting the calls with rl, if not nil
+func newTestEnvWith(t *testing.T, sig *auth.SignatureVerifier, rl *ratelimit.Limiter) *testEnv {
+	store := fakeKeyStore{}
+	keys := map[string]string{}
+	for _, role := range []string{auth.RoleSupport, auth.RolePayments} {
+		plain, key, err := auth.NewAPIKey(role, role, nil)
+		require.NoError(t, err)
+		store[key.KeyID] = key
+		keys[role] = plain
+	}
+
+	authn, err := auth.NewAuthenticator(config.Auth{Enabled: true}, store)
+	require.NoError(t, err)
+
+	al := &fakeAuditLog{}
+	uc := &fakeUserUseCase{}
+	az := auth.NewAuthorizer(authn, al)
+	if sig != nil {
+		az.SetSignatureVerifier(sig)
+	}
+	gs := NewServer(uc, az, rl)
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return &testEnv{client: pb.NewUserServiceClient(conn), uc: uc, audit: al, keys: keys}
+}
+
+func (e *testEnv) as(role string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), MetadataAPIKey, e.keys[role], MetadataRequestID, "req-123")
+}
+
+func TestAddBalance(t *testing.T) {
+	env := newTestEnv(t)
+
+	res, err := env.client.AddBalance(env.as(auth.RolePayments), &pb.AddBalanceRequest{UserId: testUserID, TransactionId: "tx_1", Amount: 10})
+
+	require.NoError(t, err)
+	assert.Equal(t, float64(110), res.GetCurrentBalance())
+	assert.Equal(t, auth.KindService, env.uc.call.ActorKind)
+	assert.Equal(t, "req-123", env.uc.call.RequestID)
+	assert.JSONEq(t, `{"userId":"`+testUserID+`","transactionId":"tx_1","amount":10}`, env.uc.call.Payload)
+}
+
+func TestAddBalanceWithFee(t *testing.T) {
+	env := newTestEnv(t)
+
+	res, err := env.client.AddBalance(env.as(auth.RolePayments), &pb.AddBalanceRequest{UserId: testUserID, TransactionId: "tx_fee", Amount: 10})
+
+	require.NoError(t, err)
+	assert.Equal(t, float64(109), res.GetCurrentBalance())
+	assert.Equal(t, float64(1), res.GetFee())
+	assert.Equal(t, "flat", res.GetFeeSchedule())
+}
+
+func TestAddBalanceErrors(t *testing.T) {
+	env := newTestEnv(t)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		req  *pb.AddBalanceRequest
+		want codes.Code
+	}{
+		{name: "no credentials", ctx: context.Background(), req: &pb.AddBalanceRequest{UserId: testUserID, TransactionId: "tx_1", Amount: 10}, want: codes.Unauthenticated},
+		{name: "missing scope", ctx: env.as(auth.RoleSupport), req: &pb.AddBalanceRequest{UserId: testUserID, TransactionId: "tx_1", Amount: 10}, want: codes.PermissionDenied},
+		{name: "invalid amount", ctx: env.as(auth.RolePayments), req: &pb.AddBalanceRequest{UserId: testUserID, TransactionId: "tx_1"}, want: codes.InvalidArgument},
+		{name: "duplicate", ctx: env.as(auth.RolePayments), req: &pb.AddBalanceRequest{UserId: testUserID, TransactionId: "tx_used", Amount: 10}, want: codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.client.AddBalance(tt.ctx, tt.req)
+			assert.Equal(t, tt.want, status.Code(err))
+		})
+	}
+
+	// the denial is audited like the denials of the rest api
+	require.Len(t, env.audit.events, 1)
+	assert.Equal(t, auth.ActionAccessDenied, env.audit.events[0].Action)
+	assert.Equal(t, "req-123", env.audit.events[0].RequestID.String)
+}
+
+func TestAddBalanceSigned(t *testing.T) {
+	sig, err := auth.NewSignatureVerifier(config.Signing{Keys: map[string][]string{"k1": {"s3cret"}}, Tolerance: time.Minute}, auth.NewMemoryNonceStore())
+	require.NoError(t, err)
+	env := newTestEnvWith(t, sig, nil)
+
+	req := &pb.AddBalanceRequest{UserId: testUserID, TransactionId: "tx_1", Amount: 10}
+	signed := func(nonce string) context.Context {
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+		require.NoError(t, err)
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		sts := auth.StringToSign(http.MethodPost, pb.UserService_AddBalance_FullMethodName, ts, nonce, body)
+		return metadata.AppendToOutgoingContext(env.as(auth.RolePayments),
+			MetadataSignatureKeyID, "k1",
+			MetadataSignatureTimestamp, ts,
+			MetadataSignatureNonce, nonce,
+			MetadataSignature, auth.Sign("s3cret", sts),
+		)
+	}
+
+	// an unsigned credit is rejected like on the rest api
+	_, err = env.client.AddBalance(env.as(auth.RolePayments), req)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, response.CodeSignatureRequired, status.Convert(err).Details()[0].(*errdetails.ErrorInfo).GetReason())
+
+	res, err := env.client.AddBalance(signed("n1"), req)
+	require.NoError(t, err)
+	assert.Equal(t, float64(110), res.GetCurrentBalance())
+
+	// the signature covers the request
+	ctx := signed("n2")
+	_, err = env.client.AddBalance(ctx, &pb.AddBalanceRequest{UserId: testUserID, TransactionId: "tx_1", Amount: 1000})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = env.client.AddBalance(signed("n1"), req)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, response.CodeNonceReused, status.Convert(err).Details()[0].(*errdetails.ErrorInfo).GetReason())
+}
+
+func TestAddBalanceRateLimited(t *testing.T) {
+	rules := []config.RateLimitRule{{Route: "add", Dimension: ratelimit.DimensionKey, Rate: 1.0 / 60, Burst: 1}}
+	env := newTestEnvWith(t, nil, ratelimit.NewLimiter(rules, ratelimit.NewMemoryStore()))
+
+	req := &pb.AddBalanceRequest{UserId: testUserID, TransactionId: "tx_1", Amount: 10}
+	_, err := env.client.AddBalance(env.as(auth.RolePayments), req)
+	require.NoError(t, err)
+
+	var header metadata.MD
+	_, err = env.client.AddBalance(env.as(auth.RolePayments), req, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"60"}, header.Get(MetadataRetryAfter))
+}
+
+func TestReadsRateLimited(t *testing.T) {
+	rules := []config.RateLimitRule{
+		{Route: "balance", Dimension: ratelimit.DimensionKey, Rate: 1.0 / 60, Burst: 1},
+		{Route: "history", Dimension: ratelimit.DimensionKey, Rate: 1.0 / 60, Burst: 1},
+	}
+	env := newTestEnvWith(t, nil, ratelimit.NewLimiter(rules, ratelimit.NewMemoryStore()))
+	ctx := env.as(auth.RoleSupport)
+
+	_, err := env.client.CheckBalance(ctx, &pb.CheckBalanceRequest{UserId: testUserID})
+	require.NoError(t, err)
+
+	var header metadata.MD
+	_, err = env.client.CheckBalance(ctx, &pb.CheckBalanceRequest{UserId: testUserID}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"60"}, header.Get(MetadataRetryAfter))
+
+	_, err = env.client.ListHistory(ctx, &pb.ListHistoryRequest{UserId: testUserID, PageSize: 2})
+	require.NoError(t, err)
+
+	_, err = env.client.ListHistory(ctx, &pb.ListHistoryRequest{UserId: testUserID, PageSize: 2})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// a stream takes from the same bucket as the pages of the history
+	stream, err := env.client.StreamHistory(ctx, &pb.StreamHistoryRequest{UserId: testUserID, PageSize: 2})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	header, err = stream.Header()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"60"}, header.Get(MetadataRetryAfter))
+}
+
+func TestCheckBalanceNotFound(t *testing.T) {
+	env := newTestEnv(t)
+
+	_, err := env.client.CheckBalance(env.as(auth.RoleSupport), &pb.CheckBalanceRequest{UserId: "unknown"})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "user not found", status.Convert(err).Message())
+
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	assert.Equal(t, response.CodeUserNotFound, details[0].(*errdetails.ErrorInfo).GetReason())
+	assert.Equal(t, ErrorDomain, details[0].(*errdetails.ErrorInfo).GetDomain())
+}
+
+func TestAddBalanceValidationDetails(t *testing.T) {
+	env := newTestEnv(t)
+
+	_, err := env.client.AddBalance(env.as(auth.RolePayments), &pb.AddBalanceRequest{UserId: testUserID, TransactionId: "1"})
+
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	details := status.Convert(err).Details()
+	require.Len(t, details, 2)
+	assert.Equal(t, response.CodeValidationFailed, details[0].(*errdetails.ErrorInfo).GetReason())
+
+	violations := details[1].(*errdetails.BadRequest).GetFieldViolations()
+	require.Len(t, violations, 2)
+	assert.Equal(t, "amount", violations[0].GetField())
+	assert.Equal(t, response.FieldNotPositive, violations[0].GetReason())
+	assert.Equal(t, "transaction_id", violations[1].GetField())
+	assert.Equal(t, response.FieldInvalidFormat, violations[1].GetReason())
+}
+
+func TestStreamHistory(t *testing.T) {
+	env := newTestEnv(t)
+
+	stream, err := env.client.StreamHistory(env.as(auth.RoleSupport), &pb.StreamHistoryRequest{UserId: testUserID, PageSize: 2})
+	require.NoError(t, err)
+
+	var ids []string
+	var fees []float64
+	for {
+		h, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		ids = append(ids, h.GetTransactionId())
+		fees = append(fees, h.GetFee())
+	}
+
+	assert.Equal(t, []string{"tx_0", "tx_1", "tx_2", "tx_3", "tx_4"}, ids)
+	assert.Equal(t, []float64{0, 0.5, 0, 0, 0}, fees)
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+		msg  string
+	}{
+		{err: response.ErrBadRequest, code: codes.InvalidArgument, msg: response.ErrBadRequest.Error()},
+		{err: response.ErrTooManyRequests, code: codes.ResourceExhausted, msg: response.ErrTooManyRequests.Error()},
+		{err: response.WrapError(errors.New("conflict"), http.StatusConflict, ""), code: codes.AlreadyExists, msg: "conflict"},
+		{err: errors.New("pq: connection refused"), code: codes.Internal, msg: "something went wrong"},
+		{err: context.DeadlineExceeded, code: codes.DeadlineExceeded, msg: context.DeadlineExceeded.Error()},
+	}
+	for _, tt := range tests {
+		st := status.Convert(toStatus(tt.err))
+		assert.Equal(t, tt.code, st.Code(), tt.err.Error())
+		assert.Equal(t, tt.msg, st.Message())
+	}
+}
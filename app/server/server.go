@@ -19,67 +19,477 @@ package server
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/fee"
 	"github.com/diptomondal007/your-money/app/server/handler"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/ratelimit"
 	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/rpc"
+	"github.com/diptomondal007/your-money/app/server/stream"
 	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/app/server/webhook"
+	"github.com/diptomondal007/your-money/infrastructure/config"
 	"github.com/diptomondal007/your-money/infrastructure/conn"
+	"github.com/diptomondal007/your-money/infrastructure/logger"
+	"github.com/diptomondal007/your-money/infrastructure/metrics"
+	"github.com/diptomondal007/your-money/infrastructure/tracing"
 )
 
 // Server is the server object
 type Server struct {
 	server *echo.Echo
+	// grpc is nil unless the grpc api is served
+	grpc       *grpc.Server
+	grpcHealth *grpchealth.Server
+	grpcCfg    config.GRPC
+	// grpcOnly serves the grpc api without the rest api
+	grpcOnly bool
+	// withDB is set if the postgres storage backend is used. the features needing postgres are disabled without it
+	withDB          bool
+	cfg             config.Server
+	health          *health
+	workers         *workerGroup
+	shutdownTracing tracing.ShutdownFunc
 }
 
-// NewServer returns a new server instance
+// NewServer returns a new server instance serving the rest api, and the grpc api if it's enabled
 func NewServer() *Server {
+	return newServer(config.Get().GRPC.Enabled, false)
+}
+
+// NewGRPCServer returns a new server instance serving only the grpc api
+func NewGRPCServer() *Server {
+	return newServer(true, true)
+}
+
+func newServer(withGRPC, grpcOnly bool) *Server {
+	logger.Init(config.Get().Log)
+
 	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+
+	withDB := true
+	switch backend := config.Get().Storage.Backend; backend {
+	case config.StoragePostgres:
+		if err := conn.ConnectDB(); err != nil {
+			slog.Error("db connection unsuccessful!", slog.Any("error", err))
+			os.Exit(1)
+		}
+	case config.StorageSQLite:
+		withDB = false
+		if err := conn.ConnectSQLite(); err != nil {
+			slog.Error("sqlite db connection unsuccessful!", slog.Any("error", err))
+			os.Exit(1)
+		}
+	case config.StorageMemory:
+		withDB = false
+	default:
+		slog.Error("unknown storage backend!", slog.String("storage", backend))
+		os.Exit(1)
+	}
+
+	// api keys, the audit log, webhooks and balance events need postgres, they must be turned off explicitly
+	if vars := config.Get().Unsupported(); len(vars) > 0 {
+		slog.Error("the storage backend doesn't support the enabled features! set them to false to run without them",
+			slog.String("storage", config.Get().Storage.Backend), slog.Any("env", vars))
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Init(config.Get().Tracing)
+	if err != nil {
+		slog.Error("tracing setup unsuccessful!", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	var authn *auth.Authenticator
+	if config.Get().Auth.Enabled {
+		var keys auth.APIKeyStore = noAPIKeys{}
+		if withDB && config.Get().Auth.APIKeys {
+			keys = repository.NewAPIKeyRepo(conn.GetDB().DB)
+		}
+
+		authn, err = auth.NewAuthenticator(config.Get().Auth, keys)
+		if err != nil {
+			slog.Error("auth setup unsuccessful!", slog.Any("error", err))
+			os.Exit(1)
+		}
+	} else {
+		slog.Warn("authentication is disabled! every caller is treated as admin")
+	}
+
+	var (
+		ar       repository.AuditRepository
+		auditLog auth.AuditLog
+	)
+	if withDB {
+		ar = repository.NewAuditRepo(conn.GetDB().DB)
+		auditLog = ar
+	}
+	az := auth.NewAuthorizer(authn, auditLog)
+
+	s := &Server{
+		server:          e,
+		grpcCfg:         config.Get().GRPC,
+		grpcOnly:        grpcOnly,
+		withDB:          withDB,
+		cfg:             config.Get().Server,
+		workers:         &workerGroup{},
+		shutdownTracing: shutdownTracing,
+	}
+
+	if config.Get().Signing.Enabled {
+		v, err := auth.NewSignatureVerifier(config.Get().Signing, s.nonceStore(config.Get().Signing))
+		if err != nil {
+			slog.Error("request signing setup unsuccessful!", slog.Any("error", err))
+			os.Exit(1)
+		}
+		az.SetSignatureVerifier(v)
+	}
+
+	ur := repository.NewMemoryUserRepo(repository.SeedUsers()...)
+	switch {
+	case withDB:
+		ur = repository.NewUserRepo(conn.GetDB().DB)
+	case conn.GetSQLite() != nil:
+		ur = repository.NewSQLiteUserRepo(conn.GetSQLite())
+	}
 
-	err := conn.ConnectDB()
+	fees, err := fee.NewPolicy(config.Get().Fees)
 	if err != nil {
-		log.Println("db connection unsuccessful! response: ", err)
+		slog.Error("fee schedules setup unsuccessful!", slog.Any("error", err))
 		os.Exit(1)
 	}
+	if fees != nil {
+		if err := ensureHouseAccount(context.Background(), ur, fees.HouseAccount); err != nil {
+			slog.Error("house account setup unsuccessful!", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+	uu := usecase.NewUserUseCase(ur, fees)
 
-	ur := repository.NewUserRepo(conn.GetDB().DB)
-	uu := usecase.NewUserUseCase(ur)
+	if cfg := config.Get().Snapshot; cfg.Enabled && cfg.Interval > 0 {
+		s.AddWorker(snapshotWorker(cfg, usecase.NewLedgerUseCase(ur)))
+	}
+	if cfg := config.Get().Check; cfg.Enabled && cfg.Interval > 0 {
+		s.AddWorker(checkWorker(cfg, usecase.NewLedgerUseCase(ur)))
+	}
 
-	handler.NewHandler(e, uu)
+	var rl *ratelimit.Limiter
+	if config.Get().RateLimit.Enabled {
+		rl = ratelimit.NewLimiter(config.Get().RateLimit.Rules, s.rateLimitStore(config.Get().RateLimit))
+	}
+
+	router := handler.NewRouter(e, config.Get().API)
+	handler.NewHandler(router, uu, az, rl)
+	handler.NewReconcileHandler(router, usecase.NewReconcileUseCase(ur, ar), config.Get().Reconcile, az, rl)
+	handler.NewDocsHandler(e)
+
+	if withDB {
+		handler.NewAuditHandler(router, usecase.NewAuditUseCase(ar), az, rl)
+
+		wr := repository.NewWebhookRepo(conn.GetDB().DB)
+		handler.NewWebhookHandler(router, usecase.NewWebhookUseCase(wr), az, rl)
+		if config.Get().Webhook.Enabled {
+			s.AddWorker(webhook.NewDispatcher(config.Get().Webhook, wr))
+		}
+	}
+
+	if withGRPC {
+		s.grpc = rpc.NewServer(uu, az, rl)
+		s.grpcHealth = grpchealth.NewServer()
+		healthpb.RegisterHealthServer(s.grpc, s.grpcHealth)
+	}
+
+	// the events are published by postgres notifications
+	if cfg := config.Get().Events; cfg.Enabled && withDB && !grpcOnly {
+		broker := stream.NewBroker()
+		handler.NewEventHandler(router, usecase.NewEventUseCase(ur, broker), az, rl, cfg.Heartbeat)
+		s.AddWorker(stream.NewListener(conn.DSN(config.Get().DB), broker))
+		// the streams never become idle on their own, so they are ended as soon as the shutdown starts
+		e.Server.RegisterOnShutdown(broker.Close)
+	}
 
 	// attaching middleware to echo server
 	attach(e)
-	return &Server{server: e}
+
+	ping := func(ctx context.Context) error { return nil }
+	switch {
+	case withDB:
+		ping = conn.GetDB().PingContext
+	case conn.GetSQLite() != nil:
+		ping = conn.GetSQLite().PingContext
+	}
+	s.health = newHealth(e, ping)
+	return s
 }
 
-// Run runs the server. gracefully shut down the server if any terminal signal received
-func (s *Server) Run() {
-	go func() {
-		s.server.Logger.Error(s.server.Start(":8080"))
-	}()
+// noAPIKeys is the api key store of the memory storage backend. api keys are stored only in postgres,
+// so every api key is rejected and only bearer tokens are accepted
+type noAPIKeys struct{}
+
+func (noAPIKeys) GetAPIKey(ctx context.Context, keyID string) (*model.APIKey, error) {
+	return nil, repository.ErrAPIKeyNotFound
+}
+
+// nonceStore returns the nonce store of signed requests. expired nonces are removed from postgres by a background worker
+func (s *Server) nonceStore(cfg config.Signing) auth.NonceStore {
+	if cfg.NonceStore != "postgres" || !s.withDB {
+		return auth.NewMemoryNonceStore()
+	}
+
+	nr := repository.NewNonceRepo(conn.GetDB().DB)
+	s.AddWorker(NewPeriodicWorker("nonce-cleaner", time.Minute, func(ctx context.Context) {
+		n, err := nr.DeleteExpiredNonces(ctx, time.Now().UTC())
+		if err != nil {
+			slog.Error("failed to delete expired nonces", slog.Any("error", err))
+			return
+		}
+		slog.Debug("expired nonces deleted", slog.Int64("count", n))
+	}))
+	return nr
+}
+
+// rateLimitStore returns the store of the rate limit buckets. idle buckets are removed from postgres by a background worker
+func (s *Server) rateLimitStore(cfg config.RateLimit) ratelimit.Store {
+	if cfg.Store != "postgres" || !s.withDB {
+		return ratelimit.NewMemoryStore()
+	}
+
+	// a bucket which was not used for the longest refill time of the rules is full and can be dropped
+	idle := time.Minute
+	for _, r := range cfg.Rules {
+		if d := time.Duration(float64(r.Burst) / r.Rate * float64(time.Second)); d > idle {
+			idle = d
+		}
+	}
 
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
-	<-sig
+	rr := repository.NewRateLimitRepo(conn.GetDB().DB)
+	s.AddWorker(NewPeriodicWorker("rate-limit-cleaner", time.Minute, func(ctx context.Context) {
+		n, err := rr.DeleteIdleBuckets(ctx, time.Now().UTC().Add(-idle))
+		if err != nil {
+			slog.Error("failed to delete idle rate limit buckets", slog.Any("error", err))
+			return
+		}
+		slog.Debug("idle rate limit buckets deleted", slog.Int64("count", n))
+	}))
+	return rr
+}
+
+// ensureHouseAccount creates the house account the fees are credited to unless it exists
+func ensureHouseAccount(ctx context.Context, ur repository.UserRepository, id string) error {
+	now := time.Now().UTC()
+	err := ur.CreateUser(ctx, &model.User{ID: id, CreatedAt: now, UpdatedAt: now, Name: "House", Status: model.UserStatusActive})
+	if errors.Is(err, repository.ErrUserExists) {
+		return nil
+	}
+	return err
+}
+
+// snapshotWorker returns the worker taking the balance snapshots at the end of every period. the snapshots are
+// idempotent, so a restarted server or other instances of the server may take the snapshots of the same period again
+func snapshotWorker(cfg config.Snapshot, lu usecase.LedgerUseCase) Worker {
+	var last time.Time
+	return NewPeriodicWorker("balance-snapshots", min(cfg.Interval, time.Minute), func(ctx context.Context) {
+		asOf := time.Now().UTC().Add(-cfg.Delay).Truncate(cfg.Interval)
+		if !asOf.After(last) {
+			return
+		}
+
+		res, err := lu.Snapshot(ctx, asOf)
+		if err != nil {
+			slog.Error("failed to take balance snapshots", slog.Time("as_of", asOf), slog.Any("error", err))
+			return
+		}
+
+		last = asOf
+		slog.Info("balance snapshots taken", slog.Time("as_of", asOf), slog.Int("users", res.Users), slog.Int("saved", res.Saved))
+	})
+}
+
+// checkWorker returns the worker comparing the stored balances with the history at every interval. it only reports
+// the drift, the repair is left to the check command
+func checkWorker(cfg config.Check, lu usecase.LedgerUseCase) Worker {
+	return NewPeriodicWorker("ledger-check", cfg.Interval, func(ctx context.Context) {
+		res, err := lu.Check(ctx, false, func(d usecase.Discrepancy) {
+			slog.Warn("balance drifted from the history", slog.String("user_id", d.UserID), slog.Float64("balance", d.Balance),
+				slog.Float64("expected", d.Expected), slog.Float64("drift", d.Drift), slog.Uint64("transaction_seq", uint64(d.TransactionSeq)))
+		})
+		if err != nil {
+			slog.Error("failed to check the balances", slog.Any("error", err))
+			return
+		}
+
+		slog.Info("balances checked", slog.Int("users", res.Users), slog.Int("drifted", res.Drifted), slog.Float64("drift", res.Drift))
+	})
+}
+
+// AddWorker registers a background worker. workers are started with the server and stopped during shutdown
+func (s *Server) AddWorker(w Worker) {
+	s.workers.add(w)
+}
 
-	log.Println("-> shutting down server gracefully ....")
+// Run runs the server until SIGINT or SIGTERM is received and then shuts it down gracefully.
+// it returns an error if the server could not be started or shut down cleanly
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	err := s.server.Shutdown(context.Background())
+	s.workers.start()
+
+	startErr := make(chan error, 2)
+	if !s.grpcOnly {
+		go func() {
+			addr := fmt.Sprintf(":%d", s.cfg.Port)
+			slog.Info("server started", slog.String("addr", addr))
+
+			if err := s.server.Start(addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				startErr <- err
+			}
+		}()
+	}
+
+	if s.grpc != nil {
+		go func() {
+			addr := fmt.Sprintf(":%d", s.grpcCfg.Port)
+			lis, err := net.Listen("tcp", addr)
+			if err != nil {
+				startErr <- err
+				return
+			}
+			slog.Info("grpc server started", slog.String("addr", addr))
+
+			if err := s.grpc.Serve(lis); err != nil {
+				startErr <- err
+			}
+		}()
+	}
+
+	s.health.setReady(true)
+	if s.grpcHealth != nil {
+		s.grpcHealth.Resume()
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		slog.Info("-> shutting down server gracefully ....")
+	case err = <-startErr:
+		slog.Error("server failed to start", slog.Any("error", err))
+	}
+	stop()
+
+	if sErr := s.shutdown(); sErr != nil {
+		return errors.Join(err, sErr)
+	}
 	if err != nil {
-		log.Println("err", err)
-		return
+		return err
+	}
+
+	slog.Info("√ successfully shut down!")
+	return nil
+}
+
+// shutdown turns readiness unhealthy, waits for the drain delay, drains in-flight requests,
+// stops the background workers and finally releases the db pool and the tracer
+func (s *Server) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	s.health.setReady(false)
+	if s.grpcHealth != nil {
+		s.grpcHealth.Shutdown()
+	}
+
+	select {
+	case <-time.After(s.cfg.DrainDelay):
+	case <-ctx.Done():
+	}
+
+	var errs []error
+	if !s.grpcOnly {
+		if err := s.server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("http server: %w", err))
+		}
+	}
+
+	if s.grpc != nil {
+		if err := stopGRPC(ctx, s.grpc); err != nil {
+			errs = append(errs, fmt.Errorf("grpc server: %w", err))
+		}
+	}
+
+	if err := s.workers.stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("background workers: %w", err))
+	}
+
+	if err := conn.CloseDB(); err != nil {
+		errs = append(errs, fmt.Errorf("db: %w", err))
+	}
+
+	if err := s.shutdownTracing(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("tracing: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// stopGRPC waits for the in-flight calls to finish, the calls still running when ctx is done are cancelled
+func stopGRPC(ctx context.Context, gs *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		gs.Stop()
+		return ctx.Err()
 	}
-	log.Println("√ successfully shut down!")
 }
 
 // attach add middlewares to echo server
 func attach(e *echo.Echo) {
-	e.Use(middleware.Logger())
+	e.Use(logger.RequestID())
+	e.Use(logger.AccessLog())
 	e.Use(middleware.Recover())
 	e.Pre(middleware.RemoveTrailingSlash())
+	e.Use(tracing.Middleware())
+
+	cfg := config.Get().Metrics
+	if cfg.Enabled {
+		if db := conn.GetDB(); db != nil {
+			if err := metrics.RegisterDBStats(db.DB.DB, "postgres"); err != nil {
+				slog.Error("failed to register db stats collector!", slog.Any("error", err))
+			}
+		}
+		if db := conn.GetSQLite(); db != nil {
+			if err := metrics.RegisterDBStats(db.DB, "sqlite"); err != nil {
+				slog.Error("failed to register db stats collector!", slog.Any("error", err))
+			}
+		}
+
+		e.Use(metrics.Middleware())
+		e.GET(cfg.Path, echo.WrapHandler(metrics.Handler()))
+	}
 }
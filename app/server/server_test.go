@@ -0,0 +1,153 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/app/server/handler"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+type testWorker struct {
+	stopped atomic.Bool
+}
+
+func (w *testWorker) Name() string { return "test" }
+
+func (w *testWorker) Run(ctx context.Context) {
+	<-ctx.Done()
+	w.stopped.Store(true)
+}
+
+func newTestServer(ping func(ctx context.Context) error) *Server {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+
+	return &Server{
+		server:          e,
+		cfg:             config.Server{Port: 0, ShutdownTimeout: 5 * time.Second},
+		health:          newHealth(e, ping),
+		workers:         &workerGroup{},
+		shutdownTracing: func(ctx context.Context) error { return nil },
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name  string
+		ready bool
+		ping  error
+		want  int
+	}{
+		{name: "ready", ready: true, want: http.StatusOK},
+		{name: "shutting down", ready: false, want: http.StatusServiceUnavailable},
+		{name: "db down", ready: true, ping: errors.New("connection refused"), want: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(func(ctx context.Context) error { return tt.ping })
+			s.health.setReady(tt.ready)
+
+			rec := httptest.NewRecorder()
+			s.server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestRunDrainsInFlightRequestsOnSIGTERM(t *testing.T) {
+	s := newTestServer(func(ctx context.Context) error { return nil })
+
+	w := &testWorker{}
+	s.AddWorker(w)
+
+	started := make(chan struct{})
+	s.server.GET("/slow", func(c echo.Context) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return c.NoContent(http.StatusOK)
+	})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run() }()
+
+	var addr string
+	assert.Eventually(t, func() bool {
+		if a := s.server.ListenerAddr(); a != nil {
+			addr = a.String()
+			return true
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+
+	status := make(chan int, 1)
+	go func() {
+		res, err := http.Get(fmt.Sprintf("http://%s/slow", addr))
+		if err != nil {
+			status <- 0
+			return
+		}
+		res.Body.Close()
+		status <- res.StatusCode
+	}()
+
+	<-started
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	assert.Equal(t, http.StatusOK, <-status)
+	assert.NoError(t, <-runErr)
+	assert.False(t, s.health.ready.Load())
+	assert.True(t, w.stopped.Load())
+}
+
+func TestRunReturnsStartupError(t *testing.T) {
+	s := newTestServer(func(ctx context.Context) error { return nil })
+	s.cfg.Port = -1
+
+	assert.Error(t, s.Run())
+}
+
+func TestOpenAPIDocumentsHealthRoutes(t *testing.T) {
+	s := newTestServer(func(ctx context.Context) error { return nil })
+
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(handler.OpenAPISpec(), &doc))
+
+	for _, r := range s.server.Routes() {
+		_, ok := doc.Paths[r.Path][strings.ToLower(r.Method)]
+		assert.True(t, ok, "%s %s is missing from openapi.json", r.Method, r.Path)
+	}
+}
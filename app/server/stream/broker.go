@@ -0,0 +1,133 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import "sync"
+
+// Broker wakes up the streams of a user when a transaction of the user is posted.
+// it carries no data, a woken stream reads the new transactions itself
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[string]map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription is the interest of a single stream in the transactions of a user
+type Subscription struct {
+	broker *Broker
+	userID string
+	wake   chan struct{}
+	done   chan struct{}
+}
+
+// NewBroker returns a new broker
+func NewBroker() *Broker {
+	return &Broker{subs: map[string]map[*Subscription]struct{}{}}
+}
+
+// Subscribe subscribes to the transactions of a user. the subscription must be closed once the stream ends
+func (b *Broker) Subscribe(userID string) *Subscription {
+	s := &Subscription{broker: b, userID: userID, wake: make(chan struct{}, 1), done: make(chan struct{})}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(s.done)
+		return s
+	}
+
+	if b.subs[userID] == nil {
+		b.subs[userID] = map[*Subscription]struct{}{}
+	}
+	b.subs[userID][s] = struct{}{}
+	return s
+}
+
+// Publish wakes up the subscriptions of a user
+func (b *Broker) Publish(userID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subs[userID] {
+		s.notify()
+	}
+}
+
+// PublishAll wakes up every subscription, ex - after notifications might have been missed
+func (b *Broker) PublishAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, subs := range b.subs {
+		for s := range subs {
+			s.notify()
+		}
+	}
+}
+
+// Close ends every subscription. later subscriptions end right away
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+
+	for _, subs := range b.subs {
+		for s := range subs {
+			close(s.done)
+		}
+	}
+	b.subs = map[string]map[*Subscription]struct{}{}
+}
+
+// Wake receives when a transaction of the user was posted. wake ups are coalesced
+func (s *Subscription) Wake() <-chan struct{} {
+	return s.wake
+}
+
+// Done is closed when the broker is closed
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close unsubscribes
+func (s *Subscription) Close() {
+	b := s.broker
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if subs, ok := b.subs[s.userID]; ok {
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(b.subs, s.userID)
+		}
+	}
+}
+
+// notify wakes up the subscription without blocking, a pending wake up already covers the new transaction
+func (s *Subscription) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
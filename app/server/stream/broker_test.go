@@ -0,0 +1,76 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func woken(s *Subscription) bool {
+	select {
+	case <-s.Wake():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestBrokerPublish(t *testing.T) {
+	b := NewBroker()
+
+	a1 := b.Subscribe("a")
+	a2 := b.Subscribe("a")
+	other := b.Subscribe("b")
+
+	// wake ups are coalesced
+	b.Publish("a")
+	b.Publish("a")
+
+	assert.True(t, woken(a1))
+	assert.False(t, woken(a1))
+	assert.True(t, woken(a2))
+	assert.False(t, woken(other))
+
+	a1.Close()
+	b.Publish("a")
+	assert.False(t, woken(a1))
+	assert.True(t, woken(a2))
+
+	b.PublishAll()
+	assert.True(t, woken(a2))
+	assert.True(t, woken(other))
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker()
+
+	s := b.Subscribe("a")
+	b.Close()
+
+	_, open := <-s.Done()
+	assert.False(t, open)
+
+	// subscriptions after closing end right away
+	_, open = <-b.Subscribe("a").Done()
+	assert.False(t, open)
+
+	s.Close()
+	b.Close()
+}
@@ -0,0 +1,98 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+)
+
+// pingInterval is how often the listener connection is checked while no notification arrives
+const pingInterval = 90 * time.Second
+
+// notification is the payload sent by the db for every posted transaction
+type notification struct {
+	UserID string `json:"user_id"`
+	ID     uint   `json:"id"`
+}
+
+// Listener listens for the transactions posted by any server instance and wakes up the local streams
+type Listener struct {
+	dsn    string
+	broker *Broker
+}
+
+// NewListener returns a new listener of the db behind dsn
+func NewListener(dsn string, broker *Broker) *Listener {
+	return &Listener{dsn: dsn, broker: broker}
+}
+
+// Name implements the server worker
+func (l *Listener) Name() string {
+	return "transaction-listener"
+}
+
+// Run listens until ctx is cancelled. the connection is re-established if it's lost
+func (l *Listener) Run(ctx context.Context) {
+	pl := pq.NewListener(l.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error("transaction listener connection failed", slog.Any("error", err))
+		}
+	})
+	defer pl.Close()
+
+	if err := pl.Listen(model.ChannelTransactionPosted); err != nil {
+		slog.Error("failed to listen for posted transactions", slog.Any("error", err))
+	}
+
+	t := time.NewTicker(pingInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-pl.Notify:
+			l.handle(n)
+		case <-t.C:
+			go pl.Ping()
+		}
+	}
+}
+
+// handle wakes up the streams of the user of a notification
+func (l *Listener) handle(n *pq.Notification) {
+	// the connection was re-established, notifications sent in the meantime are lost
+	if n == nil {
+		l.broker.PublishAll()
+		return
+	}
+
+	var p notification
+	if err := json.Unmarshal([]byte(n.Extra), &p); err != nil {
+		slog.Error("invalid transaction notification", slog.String("payload", n.Extra), slog.Any("error", err))
+		return
+	}
+	l.broker.Publish(p.UserID)
+}
@@ -0,0 +1,126 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+type CreateAPIKeyReq struct {
+	Name   string   `json:"name"`
+	Role   string   `json:"role"`
+	Scopes []string `json:"scopes"`
+}
+
+type CreateAPIKeyResp struct {
+	KeyID  string   `json:"key_id"`
+	Name   string   `json:"name"`
+	Role   string   `json:"role"`
+	Scopes []string `json:"scopes"`
+	// Key is the plain api key. it's returned only once and can't be recovered later
+	Key string `json:"key"`
+}
+
+type APIKey struct {
+	KeyID     string     `json:"key_id"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// apiKeyUseCase ...
+type apiKeyUseCase struct {
+	repo repository.APIKeyRepository
+}
+
+// APIKeyUseCase is interface for api key use case
+type APIKeyUseCase interface {
+	Create(ctx context.Context, req *CreateAPIKeyReq) (*CreateAPIKeyResp, error)
+	List(ctx context.Context) ([]APIKey, error)
+	Revoke(ctx context.Context, keyID string) error
+}
+
+// NewAPIKeyUseCase returns a new api key use case instance
+func NewAPIKeyUseCase(repo repository.APIKeyRepository) APIKeyUseCase {
+	return &apiKeyUseCase{repo: repo}
+}
+
+func (a *apiKeyUseCase) Create(ctx context.Context, req *CreateAPIKeyReq) (*CreateAPIKeyResp, error) {
+	name := strings.TrimSpace(req.Name)
+
+	var fields response.ValidationError
+	if name == "" {
+		fields = append(fields, response.FieldError{Field: "name", Code: response.FieldRequired, Message: "api key name required"})
+	}
+	if err := auth.ValidateRole(req.Role); err != nil {
+		fields = append(fields, response.FieldError{Field: "role", Code: response.FieldInvalidValue, Message: err.Error()})
+	}
+	if err := auth.ValidateScopes(req.Scopes); err != nil {
+		fields = append(fields, response.FieldError{Field: "scopes", Code: response.FieldInvalidValue, Message: err.Error()})
+	}
+	if len(fields) > 0 {
+		return nil, fields
+	}
+
+	plain, key, err := auth.NewAPIKey(name, req.Role, req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return &CreateAPIKeyResp{
+		KeyID:  key.KeyID,
+		Name:   key.Name,
+		Role:   key.Role,
+		Scopes: auth.ParseScopes(key.Scopes),
+		Key:    plain,
+	}, nil
+}
+
+func (a *apiKeyUseCase) List(ctx context.Context) ([]APIKey, error) {
+	keys, err := a.repo.ListAPIKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]APIKey, 0, len(keys))
+	for _, k := range keys {
+		ak := APIKey{KeyID: k.KeyID, Name: k.Name, Role: k.Role, Scopes: auth.ParseScopes(k.Scopes), CreatedAt: k.CreatedAt}
+		if k.RevokedAt.Valid {
+			t := k.RevokedAt.Time
+			ak.RevokedAt = &t
+		}
+		res = append(res, ak)
+	}
+	return res, nil
+}
+
+func (a *apiKeyUseCase) Revoke(ctx context.Context, keyID string) error {
+	return a.repo.RevokeAPIKey(ctx, keyID)
+}
@@ -0,0 +1,135 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/tracing"
+)
+
+type AuditEvent struct {
+	ID            uint            `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	RequestID     string          `json:"request_id,omitempty"`
+	ActorKind     string          `json:"actor_kind"`
+	ActorID       string          `json:"actor_id"`
+	Action        string          `json:"action"`
+	Resource      string          `json:"resource"`
+	Outcome       string          `json:"outcome"`
+	SourceIP      string          `json:"source_ip,omitempty"`
+	BeforeBalance *float64        `json:"before_balance,omitempty"`
+	AfterBalance  *float64        `json:"after_balance,omitempty"`
+	Details       json.RawMessage `json:"details,omitempty"`
+	Payload       string          `json:"payload,omitempty"`
+}
+
+type ListAuditEvents struct {
+	PageSize int64        `json:"page_size"`
+	NextPage string       `json:"next_page"`
+	Events   []AuditEvent `json:"events"`
+}
+
+// auditUseCase ...
+type auditUseCase struct {
+	repo repository.AuditRepository
+}
+
+// AuditUseCase is interface for audit use case
+type AuditUseCase interface {
+	List(ctx context.Context, filter model.AuditFilter, pageSize int64, cursor string) (*ListAuditEvents, error)
+	Export(ctx context.Context, filter model.AuditFilter, w io.Writer) (int, error)
+}
+
+// NewAuditUseCase returns a new audit use case instance
+func NewAuditUseCase(repo repository.AuditRepository) AuditUseCase {
+	return &auditUseCase{repo: repo}
+}
+
+func (a *auditUseCase) List(ctx context.Context, filter model.AuditFilter, pageSize int64, cursor string) (_ *ListAuditEvents, err error) {
+	ctx, span := tracer.Start(ctx, "auditUseCase.List")
+	defer func() { tracing.End(span, err) }()
+
+	evs, err := a.repo.ListAuditEvents(ctx, filter, pageSize, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastID uint
+
+	events := make([]AuditEvent, 0, len(evs))
+	for i := range evs {
+		events = append(events, toAuditEvent(evs[i]))
+		lastID = evs[i].ID
+	}
+
+	nPage := &response.Cursor{ID: lastID}
+
+	return &ListAuditEvents{
+		PageSize: pageSize,
+		NextPage: nPage.ToBase64String(),
+		Events:   events,
+	}, nil
+}
+
+// Export writes the audit events matching filter to w as json lines, oldest first. it returns the number of events written
+func (a *auditUseCase) Export(ctx context.Context, filter model.AuditFilter, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+
+	n := 0
+	err := a.repo.ExportAuditEvents(ctx, filter, func(ev *model.AuditEvent) error {
+		if err := enc.Encode(toAuditEvent(ev)); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+func toAuditEvent(ev *model.AuditEvent) AuditEvent {
+	res := AuditEvent{
+		ID:        ev.ID,
+		CreatedAt: ev.CreatedAt,
+		RequestID: ev.RequestID.String,
+		ActorKind: ev.ActorKind,
+		ActorID:   ev.ActorID,
+		Action:    ev.Action,
+		Resource:  ev.Resource,
+		Outcome:   ev.Outcome,
+		SourceIP:  ev.SourceIP.String,
+		Payload:   ev.Payload,
+	}
+
+	if ev.BeforeBalance.Valid {
+		res.BeforeBalance = &ev.BeforeBalance.Float64
+	}
+	if ev.AfterBalance.Valid {
+		res.AfterBalance = &ev.AfterBalance.Float64
+	}
+	if json.Valid([]byte(ev.Details)) {
+		res.Details = json.RawMessage(ev.Details)
+	}
+	return res
+}
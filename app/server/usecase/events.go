@@ -0,0 +1,130 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/stream"
+	"github.com/diptomondal007/your-money/infrastructure/tracing"
+)
+
+// streamBatchSize is the max number of transactions read at once while a stream catches up
+const streamBatchSize = 100
+
+// BalanceEvent is a posted transaction and the balance right after it. ID is the sequence of the transaction
+type BalanceEvent struct {
+	ID            uint      `json:"id"`
+	TransactionID string    `json:"transaction_id"`
+	Amount        float64   `json:"amount"`
+	Balance       float64   `json:"balance"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// EventStream is the stream of the balance events of a user
+type EventStream struct {
+	repo    repository.UserRepository
+	sub     *stream.Subscription
+	userID  string
+	last    uint
+	balance float64
+}
+
+// Wake receives when new events may be available
+func (s *EventStream) Wake() <-chan struct{} {
+	return s.sub.Wake()
+}
+
+// Done is closed when the stream must end, ex - the server is shutting down
+func (s *EventStream) Done() <-chan struct{} {
+	return s.sub.Done()
+}
+
+// Close ends the stream
+func (s *EventStream) Close() {
+	s.sub.Close()
+}
+
+// Next returns the events posted since the last call, oldest first
+func (s *EventStream) Next(ctx context.Context) ([]*BalanceEvent, error) {
+	res := make([]*BalanceEvent, 0)
+	for {
+		ts, err := s.repo.ListTransactionsAfter(ctx, s.userID, s.last, streamBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range ts {
+			s.balance += t.Amount
+			s.last = t.ID
+			res = append(res, &BalanceEvent{
+				ID:            t.ID,
+				TransactionID: t.TransactionID,
+				Amount:        t.Amount,
+				Balance:       s.balance,
+				CreatedAt:     t.CreatedAt,
+			})
+		}
+
+		if len(ts) < streamBatchSize {
+			return res, nil
+		}
+	}
+}
+
+// EventUseCase opens the balance event streams
+type EventUseCase interface {
+	Open(ctx context.Context, userID string, lastEventID *uint) (*EventStream, error)
+}
+
+type eventUseCase struct {
+	repo   repository.UserRepository
+	broker *stream.Broker
+}
+
+// NewEventUseCase returns a new event use case instance
+func NewEventUseCase(repo repository.UserRepository, broker *stream.Broker) EventUseCase {
+	return &eventUseCase{repo: repo, broker: broker}
+}
+
+// Open opens the stream of a user. the stream resumes after lastEventID or starts with the next posted transaction if it's nil
+func (e *eventUseCase) Open(ctx context.Context, userID string, lastEventID *uint) (_ *EventStream, err error) {
+	ctx, span := tracer.Start(ctx, "eventUseCase.Open")
+	defer func() { tracing.End(span, err) }()
+
+	// subscribe first, so that nothing posted while the position is read is missed
+	s := &EventStream{repo: e.repo, sub: e.broker.Subscribe(userID), userID: userID}
+	defer func() {
+		if err != nil {
+			s.Close()
+		}
+	}()
+
+	if lastEventID != nil {
+		s.last = *lastEventID
+	} else if s.last, err = e.repo.GetLastTransactionID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if s.balance, err = e.repo.GetBalanceAt(ctx, userID, s.last); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
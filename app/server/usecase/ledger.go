@@ -0,0 +1,257 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/diptomondal007/your-money/app/server/ledger"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/infrastructure/metrics"
+	"github.com/diptomondal007/your-money/infrastructure/tracing"
+)
+
+// verifyBatchSize is the number of users verified at once
+const verifyBatchSize = 100
+
+type VerifyResult struct {
+	Users        int `json:"users"`
+	Transactions int `json:"transactions"`
+	// Legacy is the number of transactions made before the hash chain was introduced
+	Legacy int `json:"legacy"`
+	Issues int `json:"issues"`
+}
+
+type SnapshotResult struct {
+	AsOf  time.Time `json:"as_of"`
+	Users int       `json:"users"`
+	// Saved is the number of snapshots saved, the users having a snapshot as of the time already are skipped
+	Saved int `json:"saved"`
+}
+
+type CheckResult struct {
+	Users int `json:"users"`
+	// Drifted is the number of users whose stored balance drifted from the history
+	Drifted int `json:"drifted"`
+	// Drift is the sum of the absolute drift of the drifted users
+	Drift    float64 `json:"drift"`
+	Repaired int     `json:"repaired"`
+}
+
+// Discrepancy is a stored balance drifted from the balance recomputed from the history
+type Discrepancy struct {
+	UserID   string  `json:"user_id"`
+	Balance  float64 `json:"balance"`
+	Expected float64 `json:"expected"`
+	Drift    float64 `json:"drift"`
+	// TransactionSeq is the id of the last transaction of the user when it was checked
+	TransactionSeq uint `json:"transaction_seq"`
+	// Adjustment is the transaction id of the adjusting transaction written by a repair
+	Adjustment string `json:"adjustment,omitempty"`
+	// RepairError is why the repair failed, ex - the user posted a transaction since the check
+	RepairError string `json:"repair_error,omitempty"`
+}
+
+func (d Discrepancy) String() string {
+	s := fmt.Sprintf("user %s: balance %v drifted by %v from %v recomputed from the history (last transaction %d)",
+		d.UserID, d.Balance, d.Drift, d.Expected, d.TransactionSeq)
+	switch {
+	case d.Adjustment != "":
+		s += ", adjusted by " + d.Adjustment
+	case d.RepairError != "":
+		s += ", not repaired: " + d.RepairError
+	}
+	return s
+}
+
+// ledgerUseCase ...
+type ledgerUseCase struct {
+	repo repository.UserRepository
+}
+
+// LedgerUseCase is interface for ledger use case
+type LedgerUseCase interface {
+	Verify(ctx context.Context, report func(issue ledger.Issue)) (*VerifyResult, error)
+	Snapshot(ctx context.Context, asOf time.Time) (*SnapshotResult, error)
+	Check(ctx context.Context, repair bool, report func(d Discrepancy)) (*CheckResult, error)
+}
+
+// NewLedgerUseCase returns a new ledger use case instance
+func NewLedgerUseCase(repo repository.UserRepository) LedgerUseCase {
+	return &ledgerUseCase{repo: repo}
+}
+
+// Verify walks the hash chain of every user and recomputes the balances from the history.
+// the first broken link and the balance mismatch of every user are reported
+func (l *ledgerUseCase) Verify(ctx context.Context, report func(issue ledger.Issue)) (_ *VerifyResult, err error) {
+	ctx, span := tracer.Start(ctx, "ledgerUseCase.Verify")
+	defer func() { tracing.End(span, err) }()
+
+	res := &VerifyResult{}
+
+	afterID := ""
+	for {
+		users, err := l.repo.ListUsers(ctx, afterID, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, u := range users {
+			chain := ledger.NewChain(u)
+
+			var broken *ledger.Issue
+			err := l.repo.ExportTransactions(ctx, u.ID, func(t *model.Transaction) error {
+				res.Transactions++
+				if issue := chain.Add(t); issue != nil && broken == nil {
+					broken = issue
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, issue := range []*ledger.Issue{broken, chain.Close()} {
+				if issue != nil {
+					res.Issues++
+					report(*issue)
+				}
+			}
+
+			res.Users++
+			res.Legacy += chain.Legacy
+			afterID = u.ID
+		}
+
+		if len(users) < verifyBatchSize {
+			return res, nil
+		}
+	}
+}
+
+// Snapshot saves the balance of every user existing at asOf as of asOf. the transactions created until asOf must
+// be committed, so asOf must be in the past
+func (l *ledgerUseCase) Snapshot(ctx context.Context, asOf time.Time) (_ *SnapshotResult, err error) {
+	ctx, span := tracer.Start(ctx, "ledgerUseCase.Snapshot")
+	defer func() { tracing.End(span, err) }()
+
+	asOf = ledger.Timestamp(asOf)
+	if asOf.After(time.Now()) {
+		return nil, fmt.Errorf("snapshot time %s is in the future", asOf.Format(time.RFC3339))
+	}
+
+	res := &SnapshotResult{AsOf: asOf}
+
+	afterID := ""
+	for {
+		users, err := l.repo.ListUsers(ctx, afterID, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, u := range users {
+			afterID = u.ID
+			if u.CreatedAt.After(asOf) {
+				continue
+			}
+
+			s, err := l.repo.GetBalanceAsOf(ctx, u.ID, asOf)
+			if err != nil {
+				return nil, err
+			}
+
+			saved, err := l.repo.SaveBalanceSnapshot(ctx, s)
+			if err != nil {
+				return nil, err
+			}
+
+			res.Users++
+			if saved {
+				res.Saved++
+			}
+		}
+
+		if len(users) < verifyBatchSize {
+			return res, nil
+		}
+	}
+}
+
+// Check compares the stored balance of every user with the balance recomputed from the history and reports the
+// drifted ones. with repair, the drift of every user is recorded by an adjusting transaction, so the history adds
+// up to the stored balance again; the balance itself is never changed. a user posting a transaction since it was
+// checked isn't repaired, it's checked again by the next run. the drift left is exposed as a metric
+func (l *ledgerUseCase) Check(ctx context.Context, repair bool, report func(d Discrepancy)) (_ *CheckResult, err error) {
+	ctx, span := tracer.Start(ctx, "ledgerUseCase.Check")
+	defer func() { tracing.End(span, err) }()
+
+	res := &CheckResult{}
+	// left is the drift not repaired
+	var left float64
+
+	afterID := ""
+	for {
+		cs, err := l.repo.CheckBalances(ctx, afterID, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range cs {
+			res.Users++
+			afterID = c.UserID
+
+			drift := ledger.Drift(c)
+			if drift == 0 {
+				continue
+			}
+
+			res.Drifted++
+			res.Drift += math.Abs(drift)
+
+			d := Discrepancy{UserID: c.UserID, Balance: c.Balance, Expected: c.Expected, Drift: drift, TransactionSeq: c.TransactionSeq}
+			if repair {
+				t, err := l.repo.RecordAdjustment(ctx, c, ledger.AdjustmentPrefix+uuid.NewString())
+				switch {
+				case errors.Is(err, repository.ErrLedgerChanged):
+					d.RepairError = err.Error()
+				case err != nil:
+					return nil, err
+				default:
+					d.Adjustment = t.TransactionID
+					res.Repaired++
+				}
+			}
+			if d.Adjustment == "" {
+				left += math.Abs(drift)
+			}
+			report(d)
+		}
+
+		if len(cs) < verifyBatchSize {
+			metrics.ObserveLedgerCheck(res.Drifted-res.Repaired, left)
+			return res, nil
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/ledger"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/reconcile"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/tracing"
+)
+
+// reconcileBatchSize is the number of transactions read at once
+const reconcileBatchSize = 500
+
+// ReconcileReq is a settlement file to reconcile
+type ReconcileReq struct {
+	File   io.Reader
+	Format reconcile.Format
+	// Tolerance is the max difference between the date of a line and the posting of its transaction
+	Tolerance time.Duration
+	// Window is the period of the file, the days of the lines if it's zero
+	Window reconcile.Window
+}
+
+// Validate validates the reconcile request
+func (r *ReconcileReq) Validate() error {
+	var fields response.ValidationError
+
+	if r.Tolerance < 0 {
+		fields = append(fields, response.FieldError{Field: "tolerance", Code: response.FieldInvalidValue, Message: "tolerance should not be negative"})
+	}
+
+	if r.Window.From.IsZero() != r.Window.To.IsZero() {
+		fields = append(fields, response.FieldError{Field: "from", Code: response.FieldRequired, Message: "from and to should be given together"})
+	} else if !r.Window.From.IsZero() && !r.Window.From.Before(r.Window.To) {
+		fields = append(fields, response.FieldError{Field: "to", Code: response.FieldInvalidValue, Message: "to should be after from"})
+	}
+
+	if len(fields) > 0 {
+		return fields
+	}
+	return nil
+}
+
+// reconcileUseCase ...
+type reconcileUseCase struct {
+	repo  repository.UserRepository
+	audit repository.AuditRepository
+}
+
+// ReconcileUseCase is interface for reconcile use case
+type ReconcileUseCase interface {
+	Reconcile(ctx context.Context, req *ReconcileReq) (*reconcile.Report, error)
+}
+
+// NewReconcileUseCase returns a new reconcile use case instance. every run is recorded to the audit log if it's
+// not nil
+func NewReconcileUseCase(repo repository.UserRepository, auditLog repository.AuditRepository) ReconcileUseCase {
+	return &reconcileUseCase{repo: repo, audit: auditLog}
+}
+
+// Reconcile matches the lines of a settlement file against the transactions of their transaction ids and the
+// transactions posted in the period of the file
+func (u *reconcileUseCase) Reconcile(ctx context.Context, req *ReconcileReq) (_ *reconcile.Report, err error) {
+	ctx, span := tracer.Start(ctx, "reconcileUseCase.Reconcile")
+	defer func() { tracing.End(span, err) }()
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	report, err := u.reconcile(ctx, req)
+	if u.audit == nil {
+		return report, err
+	}
+
+	outcome, details := model.AuditOutcomeSuccess, map[string]interface{}{
+		"columns":   req.Format.Mapping.String(),
+		"tolerance": req.Tolerance.String(),
+	}
+	if err != nil {
+		outcome, details["error"] = model.AuditOutcomeFailed, err.Error()
+	} else {
+		details["window"], details["summary"] = report.Window, report.Summary
+	}
+	if aerr := u.audit.AppendAuditEvent(ctx, audit.NewEvent(ctx, audit.ActionLedgerReconcile, "ledger", outcome, details)); aerr != nil {
+		return nil, aerr
+	}
+	return report, err
+}
+
+// reconcile reads the settlement file of req and matches it against the ledger
+func (u *reconcileUseCase) reconcile(ctx context.Context, req *ReconcileReq) (*reconcile.Report, error) {
+	lines, err := reconcile.ReadLines(req.File, req.Format)
+	if err != nil {
+		return nil, response.WrapError(fmt.Errorf("invalid settlement file: %w", err), http.StatusBadRequest, response.CodeInvalidSettlement)
+	}
+
+	w := req.Window
+	if w.From.IsZero() {
+		if len(lines) == 0 {
+			return nil, response.WrapError(fmt.Errorf("invalid settlement file: the file has no lines, from and to should be given"), http.StatusBadRequest, response.CodeInvalidSettlement)
+		}
+		w = reconcile.DefaultWindow(lines)
+	}
+
+	txs := map[uint]*model.Transaction{}
+
+	ids := make([]string, 0, len(lines))
+	seen := map[string]bool{}
+	for _, l := range lines {
+		if !seen[l.TransactionID] {
+			seen[l.TransactionID] = true
+			ids = append(ids, l.TransactionID)
+		}
+	}
+
+	for i := 0; i < len(ids); i += reconcileBatchSize {
+		ts, err := u.repo.FindTransactions(ctx, ids[i:min(i+reconcileBatchSize, len(ids))])
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range ts {
+			txs[t.ID] = t
+		}
+	}
+
+	var afterID uint
+	for {
+		ts, err := u.repo.ListTransactionsPosted(ctx, w.From, w.To, afterID, reconcileBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range ts {
+			afterID = t.ID
+			if !ledger.Internal(t.TransactionID) {
+				txs[t.ID] = t
+			}
+		}
+		if len(ts) < reconcileBatchSize {
+			break
+		}
+	}
+
+	all := make([]*model.Transaction, 0, len(txs))
+	for _, t := range txs {
+		all = append(all, t)
+	}
+
+	return reconcile.Match(lines, all, w, req.Tolerance), nil
+}
@@ -18,12 +18,23 @@
 package usecase
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/diptomondal007/your-money/app/server/fee"
+	"github.com/diptomondal007/your-money/app/server/ledger"
 	"github.com/diptomondal007/your-money/app/server/model"
 	"github.com/diptomondal007/your-money/app/server/repository"
 	"github.com/diptomondal007/your-money/app/utils/response"
+	"github.com/diptomondal007/your-money/infrastructure/logger"
+	"github.com/diptomondal007/your-money/infrastructure/metrics"
+	"github.com/diptomondal007/your-money/infrastructure/tracing"
 )
 
 type AddBalanceReq struct {
@@ -31,12 +42,95 @@ type AddBalanceReq struct {
 	Amount        float64 `json:"amount"`
 }
 
+// Validate checks the request and reports every rejected field, it's shared by every transport
+func (r *AddBalanceReq) Validate() error {
+	var fields response.ValidationError
+
+	if r.Amount <= 0 {
+		fields = append(fields, response.FieldError{Field: "amount", Code: response.FieldNotPositive, Message: "not a valid amount. amount should be a positive value"})
+	}
+
+	switch {
+	case r.TransactionID == "":
+		fields = append(fields, response.FieldError{Field: "transaction_id", Code: response.FieldRequired, Message: "valid transaction id required"})
+	case !strings.HasPrefix(r.TransactionID, "tx_"):
+		fields = append(fields, response.FieldError{Field: "transaction_id", Code: response.FieldInvalidFormat, Message: "valid transaction id should have prefix tx_"})
+	case len(r.TransactionID) > ledger.MaxTransactionIDLength:
+		fields = append(fields, response.FieldError{Field: "transaction_id", Code: response.FieldInvalidValue, Message: fmt.Sprintf("transaction id should be at most %d characters", ledger.MaxTransactionIDLength)})
+	}
+
+	if len(fields) > 0 {
+		return fields
+	}
+	return nil
+}
+
+// LogValue implements slog.LogValuer. only the fields listed here are ever written to the logs
+func (r AddBalanceReq) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("transaction_id", r.TransactionID),
+		slog.Float64("amount", r.Amount),
+	)
+}
+
+type CreateUserReq struct {
+	Name           string  `json:"name"`
+	OpeningBalance float64 `json:"opening_balance"`
+	// FeeSchedule is the fee schedule of the user, the default schedule applies if it's empty
+	FeeSchedule string `json:"fee_schedule"`
+}
+
+// Validate checks the request and reports every rejected field
+func (r *CreateUserReq) Validate() error {
+	var fields response.ValidationError
+
+	switch {
+	case strings.TrimSpace(r.Name) == "":
+		fields = append(fields, response.FieldError{Field: "name", Code: response.FieldRequired, Message: "name required"})
+	case len(r.Name) > maxUserNameLength:
+		fields = append(fields, response.FieldError{Field: "name", Code: response.FieldInvalidValue, Message: fmt.Sprintf("name should be at most %d characters", maxUserNameLength)})
+	}
+
+	if r.OpeningBalance < 0 {
+		fields = append(fields, response.FieldError{Field: "opening_balance", Code: response.FieldInvalidValue, Message: "opening balance should not be negative"})
+	}
+
+	if len(fields) > 0 {
+		return fields
+	}
+	return nil
+}
+
+type User struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Balance     float64   `json:"balance"`
+	Status      string    `json:"status"`
+	FeeSchedule string    `json:"fee_schedule,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
 type AddBalanceResp struct {
 	Balance float64 `json:"current_balance"`
+	// Fee is the fee charged on the credit, the balance is net of it
+	Fee         float64 `json:"fee"`
+	FeeSchedule string  `json:"fee_schedule,omitempty"`
 }
 
 type CheckBalanceResp struct {
 	Balance float64 `json:"balance"`
+	// AsOf is the point in time of a historical balance
+	AsOf *time.Time `json:"as_of,omitempty"`
+}
+
+type UserStatusResp struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+type FeeScheduleResp struct {
+	ID          string `json:"id"`
+	FeeSchedule string `json:"fee_schedule"`
 }
 
 type ListHistory struct {
@@ -50,37 +144,78 @@ type History struct {
 	CreatedAt     time.Time `json:"created_at"`
 	Amount        float64   `json:"amount"`
 	TransactionID string    `json:"transaction_id"`
+	// Fee is the fee charged on a credit, it's debited by the fee transaction following the credit
+	Fee float64 `json:"fee,omitempty"`
 }
 
 // UserUseCase ...
 type userUseCase struct {
 	repo repository.UserRepository
+	fees *fee.Policy
 }
 
 // UserUseCase is interface for user use case
 type UserUseCase interface {
-	AddBalance(userID string, req *AddBalanceReq) (*AddBalanceResp, error)
-	CheckBalance(userID string) (*CheckBalanceResp, error)
-	ListHistory(userID string, pageSize int64, cursor string) (*ListHistory, error)
+	AddBalance(ctx context.Context, userID string, req *AddBalanceReq) (*AddBalanceResp, error)
+	CheckBalance(ctx context.Context, userID string) (*CheckBalanceResp, error)
+	CheckBalanceAt(ctx context.Context, userID string, asOf time.Time) (*CheckBalanceResp, error)
+	ListHistory(ctx context.Context, userID string, pageSize int64, cursor string) (*ListHistory, error)
+	SetStatus(ctx context.Context, userID string, status string) (*UserStatusResp, error)
+	SetFeeSchedule(ctx context.Context, userID string, schedule string) (*FeeScheduleResp, error)
+	CreateUser(ctx context.Context, req *CreateUserReq) (*User, error)
 }
 
-// NewUserUseCase returns a new user use case instance
-func NewUserUseCase(repo repository.UserRepository) UserUseCase {
-	return &userUseCase{repo: repo}
+// maxUserNameLength is the length of the name column
+const maxUserNameLength = 100
+
+var tracer = tracing.Tracer("usecase")
+
+// NewUserUseCase returns a new user use case instance charging the fees of the policy, none if it's nil
+func NewUserUseCase(repo repository.UserRepository, fees *fee.Policy) UserUseCase {
+	return &userUseCase{repo: repo, fees: fees}
 }
 
-func (u *userUseCase) AddBalance(userID string, req *AddBalanceReq) (*AddBalanceResp, error) {
-	us, err := u.repo.AddBalance(userID, req.TransactionID, req.Amount)
+func (u *userUseCase) AddBalance(ctx context.Context, userID string, req *AddBalanceReq) (_ *AddBalanceResp, err error) {
+	ctx, span := tracer.Start(ctx, "userUseCase.AddBalance")
+	defer func() { tracing.End(span, err) }()
+
+	// the fee is charged by the schedule the user has when the credit is requested
+	var f *model.Fee
+	if u.fees != nil {
+		us, err := u.repo.GetUserInfo(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		f = u.fees.Charge(us, req.Amount)
+	}
+
+	us, err := u.repo.AddBalance(ctx, userID, req.TransactionID, req.Amount, f)
 	if err != nil {
-		log.Println(err)
+		l := logger.FromContext(ctx).With(slog.String("user_id", userID), slog.Any("req", *req), slog.Any("error", err))
+		if errors.Is(err, repository.ErrTransactionProcessed) {
+			l.Warn("duplicate transaction")
+			metrics.ObserveAddBalance(metrics.ResultDuplicate, req.Amount)
+		} else {
+			l.Error("add balance failed")
+			metrics.ObserveAddBalance(metrics.ResultFailed, req.Amount)
+		}
 		return nil, err
 	}
 
-	return toAddBalanceResp(us), nil
+	metrics.ObserveAddBalance(metrics.ResultProcessed, req.Amount)
+
+	res := toAddBalanceResp(us)
+	if f != nil {
+		res.Fee, res.FeeSchedule = f.Amount, f.Schedule
+	}
+	return res, nil
 }
 
-func (u *userUseCase) CheckBalance(userID string) (*CheckBalanceResp, error) {
-	us, err := u.repo.GetUserInfo(userID)
+func (u *userUseCase) CheckBalance(ctx context.Context, userID string) (_ *CheckBalanceResp, err error) {
+	ctx, span := tracer.Start(ctx, "userUseCase.CheckBalance")
+	defer func() { tracing.End(span, err) }()
+
+	us, err := u.repo.GetUserInfo(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -88,14 +223,30 @@ func (u *userUseCase) CheckBalance(userID string) (*CheckBalanceResp, error) {
 	return &CheckBalanceResp{Balance: us.Balance}, nil
 }
 
-func (u *userUseCase) ListHistory(userID string, pageSize int64, cursor string) (*ListHistory, error) {
-	ts, err := u.repo.GetHistoryList(userID, pageSize, cursor)
+// CheckBalanceAt returns the balance of a user as of a point in time, computed from the balance snapshots
+func (u *userUseCase) CheckBalanceAt(ctx context.Context, userID string, asOf time.Time) (_ *CheckBalanceResp, err error) {
+	ctx, span := tracer.Start(ctx, "userUseCase.CheckBalanceAt")
+	defer func() { tracing.End(span, err) }()
+
+	s, err := u.repo.GetBalanceAsOf(ctx, userID, asOf)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
 
-	count, err := u.repo.GetHistoryCount(userID)
+	return &CheckBalanceResp{Balance: s.Balance, AsOf: &s.AsOf}, nil
+}
+
+func (u *userUseCase) ListHistory(ctx context.Context, userID string, pageSize int64, cursor string) (_ *ListHistory, err error) {
+	ctx, span := tracer.Start(ctx, "userUseCase.ListHistory")
+	defer func() { tracing.End(span, err) }()
+
+	ts, err := u.repo.GetHistoryList(ctx, userID, pageSize, cursor)
+	if err != nil {
+		logger.FromContext(ctx).Error("list history failed", slog.String("user_id", userID), slog.Any("error", err))
+		return nil, err
+	}
+
+	count, err := u.repo.GetHistoryCount(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -108,6 +259,7 @@ func (u *userUseCase) ListHistory(userID string, pageSize int64, cursor string)
 			CreatedAt:     ts[i].CreatedAt,
 			Amount:        ts[i].Amount,
 			TransactionID: ts[i].TransactionID,
+			Fee:           ts[i].Fee,
 		})
 
 		if i == len(ts)-1 {
@@ -125,6 +277,79 @@ func (u *userUseCase) ListHistory(userID string, pageSize int64, cursor string)
 	}, nil
 }
 
+func (u *userUseCase) SetStatus(ctx context.Context, userID string, status string) (_ *UserStatusResp, err error) {
+	ctx, span := tracer.Start(ctx, "userUseCase.SetStatus")
+	defer func() { tracing.End(span, err) }()
+
+	us, err := u.repo.SetUserStatus(ctx, userID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("user status changed", slog.String("user_id", userID), slog.String("status", us.Status))
+	return &UserStatusResp{ID: us.ID, Status: us.Status}, nil
+}
+
+// SetFeeSchedule assigns a fee schedule to a user. an empty schedule unassigns it, so the default schedule applies
+func (u *userUseCase) SetFeeSchedule(ctx context.Context, userID string, schedule string) (_ *FeeScheduleResp, err error) {
+	ctx, span := tracer.Start(ctx, "userUseCase.SetFeeSchedule")
+	defer func() { tracing.End(span, err) }()
+
+	if err := u.validateFeeSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	us, err := u.repo.SetFeeSchedule(ctx, userID, schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("user fee schedule changed", slog.String("user_id", userID), slog.String("fee_schedule", us.FeeSchedule))
+	return &FeeScheduleResp{ID: us.ID, FeeSchedule: us.FeeSchedule}, nil
+}
+
+// validateFeeSchedule checks a fee schedule is defined, an empty one stands for the default schedule
+func (u *userUseCase) validateFeeSchedule(schedule string) error {
+	if schedule == "" || u.fees.Has(schedule) {
+		return nil
+	}
+	return response.ValidationError{
+		{Field: "fee_schedule", Code: response.FieldInvalidValue, Message: fmt.Sprintf("fee schedule %s is not defined", schedule)},
+	}
+}
+
+// CreateUser creates a user with a new id. the opening balance is the balance of the user before its first transaction
+func (u *userUseCase) CreateUser(ctx context.Context, req *CreateUserReq) (_ *User, err error) {
+	ctx, span := tracer.Start(ctx, "userUseCase.CreateUser")
+	defer func() { tracing.End(span, err) }()
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := u.validateFeeSchedule(req.FeeSchedule); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	us := &model.User{
+		ID:             uuid.NewString(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Name:           strings.TrimSpace(req.Name),
+		Balance:        req.OpeningBalance,
+		OpeningBalance: req.OpeningBalance,
+		Status:         model.UserStatusActive,
+		FeeSchedule:    req.FeeSchedule,
+	}
+
+	if err := u.repo.CreateUser(ctx, us); err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("user created", slog.String("user_id", us.ID))
+	return &User{ID: us.ID, Name: us.Name, Balance: us.Balance, Status: us.Status, FeeSchedule: us.FeeSchedule, CreatedAt: us.CreatedAt}, nil
+}
+
 func toAddBalanceResp(info *model.User) *AddBalanceResp {
 	return &AddBalanceResp{Balance: info.Balance}
 }
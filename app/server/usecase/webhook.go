@@ -0,0 +1,266 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/utils/response"
+)
+
+// eventTypes are the event types webhooks may subscribe to
+var eventTypes = map[string]bool{
+	model.EventBalanceCredited: true,
+}
+
+type WebhookReq struct {
+	URL string `json:"url"`
+	// EventTypes are the subscribed event types, empty subscribes to every event
+	EventTypes []string `json:"event_types"`
+	Active     *bool    `json:"active,omitempty"`
+}
+
+type Webhook struct {
+	ID         uint      `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// Secret signs the deliveries. it's returned only on creation
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             uint       `json:"id"`
+	EventID        uint       `json:"event_id"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode int64      `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+type ListWebhookDeliveries struct {
+	PageSize   int64             `json:"page_size"`
+	NextPage   string            `json:"next_page"`
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+type ReplayResp struct {
+	Replayed int64 `json:"replayed"`
+}
+
+// webhookUseCase ...
+type webhookUseCase struct {
+	repo repository.WebhookRepository
+}
+
+// WebhookUseCase is interface for webhook use case
+type WebhookUseCase interface {
+	Create(ctx context.Context, req *WebhookReq) (*Webhook, error)
+	Get(ctx context.Context, id uint) (*Webhook, error)
+	List(ctx context.Context) ([]Webhook, error)
+	Update(ctx context.Context, id uint, req *WebhookReq) (*Webhook, error)
+	Delete(ctx context.Context, id uint) error
+	ListDeliveries(ctx context.Context, id uint, status string, pageSize int64, cursor string) (*ListWebhookDeliveries, error)
+	Replay(ctx context.Context, id uint, deliveryID uint) (*ReplayResp, error)
+}
+
+// NewWebhookUseCase returns a new webhook use case instance
+func NewWebhookUseCase(repo repository.WebhookRepository) WebhookUseCase {
+	return &webhookUseCase{repo: repo}
+}
+
+// validateWebhookReq checks the url and the event types of a subscription
+func validateWebhookReq(req *WebhookReq) error {
+	var fields response.ValidationError
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fields = append(fields, response.FieldError{Field: "url", Code: response.FieldInvalidFormat, Message: "url should be an absolute http or https url"})
+	}
+
+	for _, t := range req.EventTypes {
+		if !eventTypes[t] {
+			fields = append(fields, response.FieldError{Field: "event_types", Code: response.FieldInvalidValue, Message: fmt.Sprintf("unknown event type %q", t)})
+			break
+		}
+	}
+
+	if len(fields) > 0 {
+		return fields
+	}
+	return nil
+}
+
+func (w *webhookUseCase) Create(ctx context.Context, req *WebhookReq) (*Webhook, error) {
+	if err := validateWebhookReq(req); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	wh := &model.Webhook{
+		URL:        req.URL,
+		Secret:     "whsec_" + hex.EncodeToString(secret),
+		EventTypes: strings.Join(req.EventTypes, " "),
+		Active:     req.Active == nil || *req.Active,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := w.repo.CreateWebhook(ctx, wh); err != nil {
+		return nil, err
+	}
+
+	res := toWebhook(wh)
+	res.Secret = wh.Secret
+	return &res, nil
+}
+
+func (w *webhookUseCase) Get(ctx context.Context, id uint) (*Webhook, error) {
+	wh, err := w.repo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := toWebhook(wh)
+	return &res, nil
+}
+
+func (w *webhookUseCase) List(ctx context.Context) ([]Webhook, error) {
+	whs, err := w.repo.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Webhook, 0, len(whs))
+	for _, wh := range whs {
+		res = append(res, toWebhook(wh))
+	}
+	return res, nil
+}
+
+func (w *webhookUseCase) Update(ctx context.Context, id uint, req *WebhookReq) (*Webhook, error) {
+	if err := validateWebhookReq(req); err != nil {
+		return nil, err
+	}
+
+	wh, err := w.repo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	wh.URL = req.URL
+	wh.EventTypes = strings.Join(req.EventTypes, " ")
+	if req.Active != nil {
+		wh.Active = *req.Active
+	}
+	wh.UpdatedAt = time.Now().UTC()
+
+	if err := w.repo.UpdateWebhook(ctx, wh); err != nil {
+		return nil, err
+	}
+
+	res := toWebhook(wh)
+	return &res, nil
+}
+
+func (w *webhookUseCase) Delete(ctx context.Context, id uint) error {
+	return w.repo.DeleteWebhook(ctx, id)
+}
+
+func (w *webhookUseCase) ListDeliveries(ctx context.Context, id uint, status string, pageSize int64, cursor string) (*ListWebhookDeliveries, error) {
+	if _, err := w.repo.GetWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+
+	ds, err := w.repo.ListDeliveries(ctx, id, status, pageSize, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastID uint
+
+	deliveries := make([]WebhookDelivery, 0, len(ds))
+	for _, d := range ds {
+		wd := WebhookDelivery{
+			ID:             d.ID,
+			EventID:        d.EventID,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			NextAttemptAt:  d.NextAttemptAt,
+			LastStatusCode: d.LastStatusCode.Int64,
+			LastError:      d.LastError.String,
+			CreatedAt:      d.CreatedAt,
+		}
+		if d.DeliveredAt.Valid {
+			t := d.DeliveredAt.Time
+			wd.DeliveredAt = &t
+		}
+		deliveries = append(deliveries, wd)
+		lastID = d.ID
+	}
+
+	nPage := &response.Cursor{ID: lastID}
+
+	return &ListWebhookDeliveries{
+		PageSize:   pageSize,
+		NextPage:   nPage.ToBase64String(),
+		Deliveries: deliveries,
+	}, nil
+}
+
+func (w *webhookUseCase) Replay(ctx context.Context, id uint, deliveryID uint) (*ReplayResp, error) {
+	n, err := w.repo.ReplayDeliveries(ctx, id, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayResp{Replayed: n}, nil
+}
+
+func toWebhook(wh *model.Webhook) Webhook {
+	types := strings.Fields(wh.EventTypes)
+	if types == nil {
+		types = []string{}
+	}
+
+	return Webhook{
+		ID:         wh.ID,
+		URL:        wh.URL,
+		EventTypes: types,
+		Active:     wh.Active,
+		CreatedAt:  wh.CreatedAt,
+		UpdatedAt:  wh.UpdatedAt,
+	}
+}
@@ -0,0 +1,216 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+	"github.com/diptomondal007/your-money/infrastructure/metrics"
+)
+
+// headers sent with every delivery
+const (
+	HeaderEventID   = "X-Webhook-Event-Id"
+	HeaderEventType = "X-Webhook-Event-Type"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature is sha256=<hex hmac-sha256 of "<timestamp>.<body>" with the webhook secret>
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Store keeps the outbox and the deliveries
+type Store interface {
+	FanOutEvents(ctx context.Context, limit int) (int, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.DeliveryJob, error)
+	CompleteDelivery(ctx context.Context, d *model.WebhookDelivery) error
+}
+
+// Envelope is the body of a delivery
+type Envelope struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher delivers the outbox events to the subscribed webhooks
+type Dispatcher struct {
+	cfg    config.Webhook
+	store  Store
+	client *http.Client
+	now    func() time.Time
+	// jitter spreads the retries of deliveries failing together
+	jitter func(d time.Duration) time.Duration
+}
+
+// NewDispatcher returns a new dispatcher
+func NewDispatcher(cfg config.Webhook, store Store) *Dispatcher {
+	return &Dispatcher{
+		cfg:    cfg,
+		store:  store,
+		client: &http.Client{Timeout: cfg.Timeout},
+		now:    time.Now,
+		jitter: func(d time.Duration) time.Duration {
+			return d/2 + rand.N(d/2+1)
+		},
+	}
+}
+
+// Name implements the server worker
+func (d *Dispatcher) Name() string {
+	return "webhook-dispatcher"
+}
+
+// Run polls the outbox and the due deliveries until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	t := time.NewTicker(d.cfg.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			d.Poll(ctx)
+		}
+	}
+}
+
+// Poll fans the new outbox events out to the webhooks and sends the due deliveries
+func (d *Dispatcher) Poll(ctx context.Context) {
+	if n, err := d.store.FanOutEvents(ctx, d.cfg.BatchSize); err != nil {
+		slog.Error("failed to fan out outbox events", slog.Any("error", err))
+	} else if n > 0 {
+		slog.Debug("outbox events fanned out", slog.Int("count", n))
+	}
+
+	// a claimed delivery is not picked up again until the lease is over, even if this instance dies
+	jobs, err := d.store.ClaimDeliveries(ctx, d.cfg.BatchSize, 2*d.cfg.Timeout)
+	if err != nil {
+		slog.Error("failed to claim webhook deliveries", slog.Any("error", err))
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job *model.DeliveryJob) {
+			defer wg.Done()
+			d.deliver(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+}
+
+// deliver sends a delivery once and stores the outcome
+func (d *Dispatcher) deliver(ctx context.Context, job *model.DeliveryJob) {
+	l := slog.With(slog.Uint64("delivery_id", uint64(job.ID)), slog.Uint64("event_id", uint64(job.EventID)), slog.String("url", job.URL))
+
+	statusCode, err := d.send(ctx, job)
+
+	now := d.now().UTC()
+	res := &model.WebhookDelivery{ID: job.ID, Attempts: job.Attempts + 1, NextAttemptAt: now, UpdatedAt: now}
+	if statusCode != 0 {
+		res.LastStatusCode.Int64, res.LastStatusCode.Valid = int64(statusCode), true
+	}
+
+	switch {
+	case err == nil:
+		res.Status = model.DeliveryStatusDelivered
+		res.DeliveredAt.Time, res.DeliveredAt.Valid = now, true
+		metrics.ObserveWebhookDelivery(metrics.ResultDelivered)
+	case res.Attempts >= d.cfg.MaxAttempts:
+		res.Status = model.DeliveryStatusDead
+		res.LastError.String, res.LastError.Valid = err.Error(), true
+		metrics.ObserveWebhookDelivery(metrics.ResultDead)
+		l.Warn("webhook delivery dead-lettered", slog.Int("attempts", res.Attempts), slog.Any("error", err))
+	default:
+		res.Status = model.DeliveryStatusPending
+		res.NextAttemptAt = now.Add(d.backoff(res.Attempts))
+		res.LastError.String, res.LastError.Valid = err.Error(), true
+		metrics.ObserveWebhookDelivery(metrics.ResultRetry)
+		l.Info("webhook delivery failed, retrying", slog.Int("attempts", res.Attempts), slog.Time("next_attempt_at", res.NextAttemptAt), slog.Any("error", err))
+	}
+
+	// the context may be cancelled by a shutdown while sending, the outcome is stored anyway
+	if err := d.store.CompleteDelivery(context.WithoutCancel(ctx), res); err != nil {
+		l.Error("failed to store webhook delivery outcome", slog.Any("error", err))
+	}
+}
+
+// send posts the signed event to the webhook. any status other than 2xx is a failure
+func (d *Dispatcher) send(ctx context.Context, job *model.DeliveryJob) (int, error) {
+	body, err := json.Marshal(Envelope{ID: job.EventID, Type: job.EventType, CreatedAt: job.CreatedAt, Data: json.RawMessage(job.Payload)})
+	if err != nil {
+		return 0, err
+	}
+
+	ts := strconv.FormatInt(d.now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "your-money-webhooks")
+	req.Header.Set(HeaderEventID, strconv.FormatUint(uint64(job.EventID), 10))
+	req.Header.Set(HeaderEventType, job.EventType)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, "sha256="+Signature(job.Secret, ts, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt. it doubles with every attempt up to the max
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.BackoffBase
+	for i := 1; i < attempts && delay < d.cfg.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > d.cfg.BackoffMax {
+		delay = d.cfg.BackoffMax
+	}
+	return d.jitter(delay)
+}
+
+// Signature returns the hex hmac-sha256 of a delivery body signed at timestamp ts
+func Signature(secret, ts string, body []byte) string {
+	return auth.Sign(secret, ts+"."+string(body))
+}
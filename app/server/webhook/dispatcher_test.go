@@ -0,0 +1,163 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/app/server/model"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+type fakeStore struct {
+	mu        sync.Mutex
+	jobs      []*model.DeliveryJob
+	completed map[uint]*model.WebhookDelivery
+}
+
+func (f *fakeStore) FanOutEvents(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+func (f *fakeStore) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.DeliveryJob, error) {
+	jobs := f.jobs
+	f.jobs = nil
+	return jobs, nil
+}
+
+func (f *fakeStore) CompleteDelivery(ctx context.Context, d *model.WebhookDelivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.completed[d.ID] = d
+	return nil
+}
+
+var testCfg = config.Webhook{
+	Interval:    time.Second,
+	BatchSize:   10,
+	Timeout:     time.Second,
+	MaxAttempts: 3,
+	BackoffBase: 5 * time.Second,
+	BackoffMax:  time.Minute,
+}
+
+func newTestDispatcher(jobs ...*model.DeliveryJob) (*Dispatcher, *fakeStore, time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store := &fakeStore{jobs: jobs, completed: map[uint]*model.WebhookDelivery{}}
+	d := NewDispatcher(testCfg, store)
+	d.now = func() time.Time { return now }
+	d.jitter = func(d time.Duration) time.Duration { return d }
+	return d, store, now
+}
+
+func job(id uint, url string, attempts int) *model.DeliveryJob {
+	return &model.DeliveryJob{
+		ID:        id,
+		Attempts:  attempts,
+		URL:       url,
+		Secret:    "whsec_test",
+		EventID:   42,
+		EventType: model.EventBalanceCredited,
+		Payload:   `{"user_id":"u1","amount":10}`,
+		CreatedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestDeliverSigned(t *testing.T) {
+	var got *http.Request
+	var body []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d, store, now := newTestDispatcher(job(1, srv.URL, 0))
+	d.Poll(context.Background())
+
+	assert.Equal(t, "42", got.Header.Get(HeaderEventID))
+	assert.Equal(t, model.EventBalanceCredited, got.Header.Get(HeaderEventType))
+	assert.Equal(t, "sha256="+Signature("whsec_test", got.Header.Get(HeaderTimestamp), body), got.Header.Get(HeaderSignature))
+
+	var env Envelope
+	assert.NoError(t, json.Unmarshal(body, &env))
+	assert.Equal(t, uint(42), env.ID)
+	assert.JSONEq(t, `{"user_id":"u1","amount":10}`, string(env.Data))
+
+	res := store.completed[1]
+	assert.Equal(t, model.DeliveryStatusDelivered, res.Status)
+	assert.Equal(t, 1, res.Attempts)
+	assert.Equal(t, now, res.DeliveredAt.Time)
+	assert.Equal(t, int64(http.StatusNoContent), res.LastStatusCode.Int64)
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	d, store, now := newTestDispatcher(job(1, srv.URL, 0), job(2, srv.URL, 1))
+	d.Poll(context.Background())
+
+	first := store.completed[1]
+	assert.Equal(t, model.DeliveryStatusPending, first.Status)
+	assert.Equal(t, now.Add(5*time.Second), first.NextAttemptAt)
+	assert.Equal(t, "unexpected status 503", first.LastError.String)
+
+	second := store.completed[2]
+	assert.Equal(t, 2, second.Attempts)
+	assert.Equal(t, now.Add(10*time.Second), second.NextAttemptAt)
+}
+
+func TestDeliverDeadLetter(t *testing.T) {
+	// nothing is listening on the url
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	d, store, _ := newTestDispatcher(job(1, url, testCfg.MaxAttempts-1))
+	d.Poll(context.Background())
+
+	res := store.completed[1]
+	assert.Equal(t, model.DeliveryStatusDead, res.Status)
+	assert.Equal(t, testCfg.MaxAttempts, res.Attempts)
+	assert.False(t, res.LastStatusCode.Valid)
+	assert.NotEmpty(t, res.LastError.String)
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	d, _, _ := newTestDispatcher()
+
+	assert.Equal(t, 5*time.Second, d.backoff(1))
+	assert.Equal(t, 20*time.Second, d.backoff(3))
+	assert.Equal(t, time.Minute, d.backoff(5))
+	assert.Equal(t, time.Minute, d.backoff(100))
+}
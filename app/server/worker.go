@@ -0,0 +1,110 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Worker is a background job running for the lifetime of the server.
+// Run must return once ctx is cancelled
+type Worker interface {
+	Name() string
+	Run(ctx context.Context)
+}
+
+// workerGroup runs the background workers and stops them together
+type workerGroup struct {
+	workers []Worker
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func (g *workerGroup) add(w Worker) {
+	g.workers = append(g.workers, w)
+}
+
+func (g *workerGroup) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	g.cancel = cancel
+
+	for _, w := range g.workers {
+		g.wg.Add(1)
+		go func(w Worker) {
+			defer g.wg.Done()
+
+			slog.Info("worker started", slog.String("worker", w.Name()))
+			w.Run(ctx)
+			slog.Info("worker stopped", slog.String("worker", w.Name()))
+		}(w)
+	}
+}
+
+// stop cancels the workers and waits for them to return or ctx to be done
+func (g *workerGroup) stop(ctx context.Context) error {
+	if g.cancel == nil {
+		return nil
+	}
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// periodicWorker runs a job at a fixed interval
+type periodicWorker struct {
+	name     string
+	interval time.Duration
+	job      func(ctx context.Context)
+}
+
+// NewPeriodicWorker returns a worker running job every interval until it's stopped
+func NewPeriodicWorker(name string, interval time.Duration, job func(ctx context.Context)) Worker {
+	return &periodicWorker{name: name, interval: interval, job: job}
+}
+
+func (p *periodicWorker) Name() string {
+	return p.name
+}
+
+func (p *periodicWorker) Run(ctx context.Context) {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.job(ctx)
+		}
+	}
+}
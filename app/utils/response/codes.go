@@ -0,0 +1,124 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package response
+
+import (
+	"errors"
+	"net/http"
+)
+
+// error codes are the stable, machine readable identifiers of the errors sent in the `error_code` field
+// of the response. clients should branch on them instead of the message. codes are never renamed or
+// reused, new ones may be added. the catalogue is documented in the README
+const (
+	// generic codes, sent when there is no specific one for the error
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeInvalidPage      = "invalid_page"
+	CodeNotAcceptable    = "not_acceptable"
+	CodeConflict         = "conflict"
+	CodeUnprocessable    = "unprocessable"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal_error"
+
+	// specific codes
+	CodeInvalidCursor        = "invalid_cursor"
+	CodeUserNotFound         = "user_not_found"
+	CodeUserFrozen           = "user_frozen"
+	CodeTransactionProcessed = "transaction_already_processed"
+	CodeAPIKeyNotFound       = "api_key_not_found"
+	CodeWebhookNotFound      = "webhook_not_found"
+	CodeDeliveryNotFound     = "delivery_not_found"
+	CodeSignatureRequired    = "signature_required"
+	CodeSignatureInvalid     = "signature_invalid"
+	CodeSignatureExpired     = "signature_expired"
+	CodeNonceReused          = "nonce_reused"
+	CodeInvalidSettlement    = "invalid_settlement_file"
+)
+
+// field codes tell why a field was rejected in the `details` of a validation_failed error
+const (
+	FieldRequired      = "required"
+	FieldNotPositive   = "not_positive"
+	FieldInvalidFormat = "invalid_format"
+	FieldInvalidValue  = "invalid_value"
+)
+
+// statusCodes are the codes of the errors without a specific one
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          CodeBadRequest,
+	http.StatusUnauthorized:        CodeUnauthorized,
+	http.StatusForbidden:           CodeForbidden,
+	http.StatusNotFound:            CodeNotFound,
+	http.StatusNotAcceptable:       CodeNotAcceptable,
+	http.StatusConflict:            CodeConflict,
+	http.StatusUnprocessableEntity: CodeUnprocessable,
+	http.StatusTooManyRequests:     CodeRateLimited,
+}
+
+// getErrorCode returns the code of err or an empty string if it has none
+func getErrorCode(err error) string {
+	switch err {
+	case ErrNotFound:
+		return CodeNotFound
+	case ErrInvalidPage:
+		return CodeInvalidPage
+	case ErrConflict:
+		return CodeConflict
+	case ErrBadRequest:
+		return CodeBadRequest
+	case ErrUnauthorized:
+		return CodeUnauthorized
+	case ErrForbidden:
+		return CodeForbidden
+	case ErrTooManyRequests:
+		return CodeRateLimited
+	case ErrInternalServerError:
+		return CodeInternal
+	case ErrNotAcceptable:
+		return CodeNotAcceptable
+	}
+
+	if wrapErr := (WrapErr{}); errors.As(err, &wrapErr) && wrapErr.ErrCode != "" {
+		return wrapErr.ErrCode
+	}
+	if errors.As(err, new(ValidationError)) {
+		return CodeValidationFailed
+	}
+	return ""
+}
+
+// FieldError describes why a field of the request was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError is a bad request listing every rejected field. its message is the one of the first field
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	if len(e) == 0 {
+		return "validation failed"
+	}
+	return e[0].Message
+}
@@ -0,0 +1,43 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package response
+
+import (
+	"encoding/json"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SendError writes the error response of err to the client. the response is tagged
+// with the request id of the current request if there is any. it's sent as problem
+// details if the client prefers them by the accept header
+func SendError(c echo.Context, err error, customErr ...error) error {
+	code, resp := RespondError(err, customErr...)
+	resp.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if !AcceptsProblem(c.Request().Header.Get(echo.HeaderAccept)) {
+		return c.JSON(code, resp)
+	}
+
+	b, err := json.Marshal(NewProblem(resp))
+	if err != nil {
+		return err
+	}
+	return c.Blob(code, MIMEApplicationProblemJSON, b)
+}
@@ -29,6 +29,9 @@ var (
 	ErrNotAcceptable       = errors.New("not acceptable")
 	ErrConflict            = errors.New("data conflict or already exist")
 	ErrBadRequest          = errors.New("bad request, check param or body")
+	ErrUnauthorized        = errors.New("authentication required")
+	ErrForbidden           = errors.New("access to the resource is forbidden")
+	ErrTooManyRequests     = errors.New("too many requests, slow down")
 	ErrInternalServerError = errors.New("internal server response")
 )
 
@@ -42,6 +45,12 @@ func getStatusCode(err error) int {
 		return http.StatusConflict
 	case ErrBadRequest:
 		return http.StatusBadRequest
+	case ErrUnauthorized:
+		return http.StatusUnauthorized
+	case ErrForbidden:
+		return http.StatusForbidden
+	case ErrTooManyRequests:
+		return http.StatusTooManyRequests
 	case ErrInternalServerError:
 		return http.StatusInternalServerError
 	case ErrNotAcceptable:
@@ -51,22 +60,42 @@ func getStatusCode(err error) int {
 		if errors.As(err, wrapErr) {
 			return wrapErr.StatusCode
 		}
+		if errors.As(err, new(ValidationError)) {
+			return http.StatusBadRequest
+		}
 		return http.StatusInternalServerError
 	}
 }
 
 // RespondError takes an `response` and a `customErr message` args
-// to log the response to system and return to client
+// to log the response to system and return to client. the error code is the one
+// of the custom error if it has one, else the one of err or of its status code
 func RespondError(err error, customErr ...error) (int, Response) {
 	combinedErr := err
 	statusCode := getStatusCode(err)
-	resp := Response{Success: false, Message: err.Error(), StatusCode: statusCode}
+	resp := Response{Success: false, Message: err.Error(), StatusCode: statusCode, ErrorCode: getErrorCode(err)}
+	detailErr := err
 	if len(customErr) > 0 {
 		resp.Message = customErr[0].Error()
 		combinedErr = fmt.Errorf("%s : %s", combinedErr, customErr[0])
+		if code := getErrorCode(customErr[0]); code != "" {
+			resp.ErrorCode = code
+			detailErr = customErr[0]
+		}
+	}
+	if resp.ErrorCode == "" {
+		resp.ErrorCode = statusCodes[statusCode]
 	}
+
+	var ve ValidationError
+	if errors.As(detailErr, &ve) {
+		resp.Details = ve
+	}
+
 	if statusCode == http.StatusInternalServerError {
 		resp.Message = "something went wrong"
+		resp.ErrorCode = CodeInternal
+		resp.Details = nil
 	}
 	return statusCode, resp
 }
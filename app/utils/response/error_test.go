@@ -47,9 +47,84 @@ func TestRespondError(t *testing.T) {
 				Success:    false,
 				Message:    "resource not found",
 				StatusCode: http.StatusNotFound,
+				ErrorCode:  CodeNotFound,
 				Data:       nil,
 			},
 		},
+		{
+			name: "t-02",
+			args: args{
+				err:       ErrBadRequest,
+				customErr: []error{fmt.Errorf("not a valid user id")},
+			},
+			want: http.StatusBadRequest,
+			want1: Response{
+				Success:    false,
+				Message:    "not a valid user id",
+				StatusCode: http.StatusBadRequest,
+				ErrorCode:  CodeBadRequest,
+			},
+		},
+		{
+			name: "t-03",
+			args: args{
+				err: WrapError(fmt.Errorf("user not found"), http.StatusNotFound, CodeUserNotFound),
+			},
+			want: http.StatusNotFound,
+			want1: Response{
+				Success:    false,
+				Message:    "user not found",
+				StatusCode: http.StatusNotFound,
+				ErrorCode:  CodeUserNotFound,
+			},
+		},
+		{
+			name: "t-04",
+			args: args{
+				err: WrapError(fmt.Errorf("user not found"), http.StatusNotFound, ""),
+			},
+			want: http.StatusNotFound,
+			want1: Response{
+				Success:    false,
+				Message:    "user not found",
+				StatusCode: http.StatusNotFound,
+				ErrorCode:  CodeNotFound,
+			},
+		},
+		{
+			name: "t-05",
+			args: args{
+				err: ErrBadRequest,
+				customErr: []error{ValidationError{
+					{Field: "amount", Code: FieldNotPositive, Message: "amount should be positive"},
+					{Field: "transaction_id", Code: FieldRequired, Message: "transaction id required"},
+				}},
+			},
+			want: http.StatusBadRequest,
+			want1: Response{
+				Success:    false,
+				Message:    "amount should be positive",
+				StatusCode: http.StatusBadRequest,
+				ErrorCode:  CodeValidationFailed,
+				Details: []FieldError{
+					{Field: "amount", Code: FieldNotPositive, Message: "amount should be positive"},
+					{Field: "transaction_id", Code: FieldRequired, Message: "transaction id required"},
+				},
+			},
+		},
+		{
+			name: "t-06",
+			args: args{
+				err: WrapError(fmt.Errorf("connection refused"), http.StatusInternalServerError, CodeUserNotFound),
+			},
+			want: http.StatusInternalServerError,
+			want1: Response{
+				Success:    false,
+				Message:    "something went wrong",
+				StatusCode: http.StatusInternalServerError,
+				ErrorCode:  CodeInternal,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
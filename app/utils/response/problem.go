@@ -0,0 +1,88 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package response
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MIMEApplicationProblemJSON is the content type of the rfc 7807 problem details
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// ProblemTypePrefix prefixes the error code in the type uri of the problems
+const ProblemTypePrefix = "urn:your-money:error:"
+
+// Problem is the rfc 7807 representation of an error response. error_code and invalid_params are
+// extension members carrying the error code and the rejected fields of the response envelope
+type Problem struct {
+	Type          string       `json:"type"`
+	Title         string       `json:"title"`
+	Status        int          `json:"status"`
+	Detail        string       `json:"detail,omitempty"`
+	Instance      string       `json:"instance,omitempty"`
+	ErrorCode     string       `json:"error_code,omitempty"`
+	InvalidParams []FieldError `json:"invalid_params,omitempty"`
+}
+
+// NewProblem converts an error response to problem details. the instance is the request id of the response
+func NewProblem(resp Response) Problem {
+	typ := "about:blank"
+	if resp.ErrorCode != "" {
+		typ = ProblemTypePrefix + resp.ErrorCode
+	}
+
+	return Problem{
+		Type:          typ,
+		Title:         http.StatusText(resp.StatusCode),
+		Status:        resp.StatusCode,
+		Detail:        resp.Message,
+		Instance:      resp.RequestID,
+		ErrorCode:     resp.ErrorCode,
+		InvalidParams: resp.Details,
+	}
+}
+
+// AcceptsProblem reports whether the value of an accept header prefers problem details over plain json.
+// the response envelope stays the default for the clients asking for anything else
+func AcceptsProblem(accept string) bool {
+	problemQ, jsonQ := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case MIMEApplicationProblemJSON:
+			problemQ = max(problemQ, q)
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return problemQ > 0 && problemQ >= jsonQ
+}
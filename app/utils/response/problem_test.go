@@ -0,0 +1,106 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package response
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptsProblem(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "*/*", want: false},
+		{accept: "application/json", want: false},
+		{accept: "application/problem+json", want: true},
+		{accept: "application/problem+json, application/json", want: true},
+		{accept: "application/json, application/problem+json;q=0.9", want: false},
+		{accept: "application/json;q=0.5, application/problem+json", want: true},
+		{accept: "application/problem+json;q=0", want: false},
+		{accept: "application/problem+json;q=bad", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			assert.Equal(t, tt.want, AcceptsProblem(tt.accept))
+		})
+	}
+}
+
+func TestNewProblem(t *testing.T) {
+	code, resp := RespondError(ErrBadRequest, ValidationError{
+		{Field: "amount", Code: FieldNotPositive, Message: "amount should be positive"},
+	})
+	resp.RequestID = "req-1"
+
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, Problem{
+		Type:          ProblemTypePrefix + CodeValidationFailed,
+		Title:         "Bad Request",
+		Status:        http.StatusBadRequest,
+		Detail:        "amount should be positive",
+		Instance:      "req-1",
+		ErrorCode:     CodeValidationFailed,
+		InvalidParams: []FieldError{{Field: "amount", Code: FieldNotPositive, Message: "amount should be positive"}},
+	}, NewProblem(resp))
+}
+
+func TestSendError(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "envelope by default",
+			contentType: echo.MIMEApplicationJSONCharsetUTF8,
+			body:        `{"success":false,"message":"user not found","status_code":404,"error_code":"user_not_found","request_id":"req-123"}`,
+		},
+		{
+			name:        "problem details",
+			accept:      MIMEApplicationProblemJSON,
+			contentType: MIMEApplicationProblemJSON,
+			body: `{"type":"urn:your-money:error:user_not_found","title":"Not Found","status":404,"detail":"user not found",` +
+				`"instance":"req-123","error_code":"user_not_found"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderAccept, tt.accept)
+			rec := httptest.NewRecorder()
+			rec.Header().Set(echo.HeaderXRequestID, "req-123")
+
+			err := SendError(echo.New().NewContext(req, rec), WrapError(fmt.Errorf("user not found"), http.StatusNotFound, CodeUserNotFound))
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Equal(t, tt.contentType, rec.Header().Get(echo.HeaderContentType))
+			assert.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+			assert.JSONEq(t, tt.body, rec.Body.String())
+		})
+	}
+}
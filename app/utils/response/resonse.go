@@ -19,10 +19,13 @@ package response
 
 // Response holds generic response structure
 type Response struct {
-	Success    bool        `json:"success"`
-	Message    string      `json:"message"`
-	StatusCode int         `json:"status_code"`
-	Data       interface{} `json:"data,omitempty"`
+	Success    bool         `json:"success"`
+	Message    string       `json:"message"`
+	StatusCode int          `json:"status_code"`
+	ErrorCode  string       `json:"error_code,omitempty"`
+	RequestID  string       `json:"request_id,omitempty"`
+	Details    []FieldError `json:"details,omitempty"`
+	Data       interface{}  `json:"data,omitempty"`
 }
 
 // RespondSuccess returns status code and success response
@@ -0,0 +1,136 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/diptomondal007/your-money/app/server/auth"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/infrastructure/conn"
+)
+
+// apiKeyCmd represents the apikey command
+var apiKeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "apikey command manages the api keys of service callers",
+	Long:  `apikey command creates, revokes and lists the api keys used by service-to-service callers`,
+}
+
+// apiKeyCreateCmd represents the apikey create command
+var apiKeyCreateCmd = &cobra.Command{
+	Use:          "create <name>",
+	Short:        "create a new api key",
+	Long:         `create a new api key with a role (support, payments, admin) and optional extra scopes. the key is printed only once, store it securely`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		uc, err := newAPIKeyUseCase()
+		if err != nil {
+			return err
+		}
+
+		role, _ := cmd.Flags().GetString("role")
+		scopes, _ := cmd.Flags().GetStringSlice("scope")
+
+		res, err := uc.Create(cliContext(cmd), &usecase.CreateAPIKeyReq{Name: args[0], Role: role, Scopes: scopes})
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "key id: %s\nname:   %s\nrole:   %s\nscopes: %s\nkey:    %s\n",
+			res.KeyID, res.Name, res.Role, strings.Join(res.Scopes, " "), res.Key)
+		return nil
+	},
+}
+
+// apiKeyRevokeCmd represents the apikey revoke command
+var apiKeyRevokeCmd = &cobra.Command{
+	Use:          "revoke <key id>",
+	Short:        "revoke an api key",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		uc, err := newAPIKeyUseCase()
+		if err != nil {
+			return err
+		}
+
+		if err := uc.Revoke(cliContext(cmd), args[0]); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "api key %s revoked\n", args[0])
+		return nil
+	},
+}
+
+// apiKeyListCmd represents the apikey list command
+var apiKeyListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "list the api keys",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		uc, err := newAPIKeyUseCase()
+		if err != nil {
+			return err
+		}
+
+		keys, err := uc.List(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY ID\tNAME\tROLE\tSCOPES\tCREATED AT\tREVOKED AT")
+		for _, k := range keys {
+			revokedAt := "-"
+			if k.RevokedAt != nil {
+				revokedAt = k.RevokedAt.Format(time.RFC3339)
+			}
+			scopes := strings.Join(k.Scopes, ",")
+			if scopes == "" {
+				scopes = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", k.KeyID, k.Name, k.Role, scopes, k.CreatedAt.Format(time.RFC3339), revokedAt)
+		}
+		return w.Flush()
+	},
+}
+
+func newAPIKeyUseCase() (usecase.APIKeyUseCase, error) {
+	if err := conn.ConnectDB(); err != nil {
+		return nil, fmt.Errorf("db connection unsuccessful! response: %w", err)
+	}
+	return usecase.NewAPIKeyUseCase(repository.NewAPIKeyRepo(conn.GetDB().DB)), nil
+}
+
+func init() {
+	apiKeyCreateCmd.Flags().String("role", auth.RoleSupport, "role of the key. one of support, payments, admin")
+	apiKeyCreateCmd.Flags().StringSlice("scope", nil, "extra scope granted to the key. can be repeated")
+
+	apiKeyCmd.AddCommand(apiKeyCreateCmd, apiKeyRevokeCmd, apiKeyListCmd)
+	rootCmd.AddCommand(apiKeyCmd)
+}
@@ -0,0 +1,108 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/diptomondal007/your-money/app/server/audit"
+	"github.com/diptomondal007/your-money/app/server/handler"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/infrastructure/conn"
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "audit command reads the audit log",
+	Long:  `audit command reads the append-only audit log of every state-changing operation`,
+}
+
+// auditExportCmd represents the audit export command
+var auditExportCmd = &cobra.Command{
+	Use:          "export",
+	Short:        "export audit events as json lines",
+	Long:         `export the audit events matching the filters as json lines, oldest first. from and to are RFC 3339 timestamps`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := handler.ParseAuditFilter(func(name string) string {
+			v, _ := cmd.Flags().GetString(strings.ReplaceAll(name, "_", "-"))
+			return v
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := conn.ConnectDB(); err != nil {
+			return fmt.Errorf("db connection unsuccessful! response: %w", err)
+		}
+		uc := usecase.NewAuditUseCase(repository.NewAuditRepo(conn.GetDB().DB))
+
+		var w io.Writer = cmd.OutOrStdout()
+		if out, _ := cmd.Flags().GetString("output"); out != "" && out != "-" {
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		n, err := uc.Export(cmd.Context(), filter, w)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.ErrOrStderr(), "%d audit events exported\n", n)
+		return nil
+	},
+}
+
+// cliContext returns the context of a command attributing the changes it makes to the operator running it
+func cliContext(cmd *cobra.Command) context.Context {
+	operator := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		operator = u.Username
+	}
+	if operator == "" {
+		operator = "unknown"
+	}
+
+	return audit.WithCall(cmd.Context(), audit.Call{ActorKind: audit.ActorKindCLI, ActorID: operator})
+}
+
+func init() {
+	for _, f := range []string{"actor-kind", "actor-id", "action", "resource", "outcome", "request-id"} {
+		auditExportCmd.Flags().String(f, "", fmt.Sprintf("only export the events with the given %s", strings.ReplaceAll(f, "-", " ")))
+	}
+	auditExportCmd.Flags().String("from", "", "only export the events created at or after this RFC 3339 timestamp")
+	auditExportCmd.Flags().String("to", "", "only export the events created before this RFC 3339 timestamp")
+	auditExportCmd.Flags().StringP("output", "o", "-", "file to write the events to. - writes to stdout")
+
+	auditCmd.AddCommand(auditExportCmd)
+	rootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,96 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/diptomondal007/your-money/app/bench"
+)
+
+// benchCmd represents the bench command
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "bench fires load at a running server",
+	Long: `bench creates users on a running server, fires a mix of add, balance and history requests at them with the
+given concurrency for the duration, and reports the throughput, the latency percentiles and the errors.
+it finally checks that the balance of every user equals its opening balance plus the credits the server accepted.
+the caller must be granted the users:admin scope, rate limits and request signing should be disabled on the server`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f := cmd.Flags()
+
+		mixFlag, _ := f.GetString("mix")
+		mix, err := bench.ParseMix(mixFlag)
+		if err != nil {
+			return err
+		}
+
+		cfg := bench.Config{Mix: mix}
+		cfg.URL, _ = f.GetString("url")
+		cfg.Users, _ = f.GetInt("users")
+		cfg.Concurrency, _ = f.GetInt("concurrency")
+		cfg.Duration, _ = f.GetDuration("duration")
+		cfg.APIKey, _ = f.GetString("api-key")
+		cfg.Token, _ = f.GetString("token")
+		cfg.Timeout, _ = f.GetDuration("timeout")
+		cfg.MaxAmount, _ = f.GetInt("max-amount")
+		cfg.OpeningBalance, _ = f.GetFloat64("opening-balance")
+		cfg.PageSize, _ = f.GetInt("page-size")
+
+		if cfg.Users < 1 || cfg.Concurrency < 1 || cfg.MaxAmount < 1 || cfg.PageSize < 1 {
+			return errors.New("users, concurrency, max-amount and page-size should be greater than 0")
+		}
+
+		rep, err := bench.Run(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+
+		if err := rep.Print(cmd.OutOrStdout()); err != nil {
+			return err
+		}
+
+		if len(rep.Mismatches) > 0 {
+			return fmt.Errorf("%d balances don't match the credits", len(rep.Mismatches))
+		}
+		return nil
+	},
+}
+
+func init() {
+	f := benchCmd.Flags()
+	f.String("url", "http://localhost:8080", "base url of the server")
+	f.Int("users", 10, "number of users created")
+	f.Int("concurrency", 10, "number of requests in flight")
+	f.Duration("duration", 10*time.Second, "duration of the load")
+	f.String("mix", "add=60,balance=30,history=10", "weights of the requests")
+	f.String("api-key", "", "api key sent in the X-API-Key header")
+	f.String("token", "", "bearer token sent in the Authorization header")
+	f.Duration("timeout", 10*time.Second, "timeout of a single request")
+	f.Int("max-amount", 10, "largest amount credited, the amounts are whole numbers from 1")
+	f.Float64("opening-balance", 0, "opening balance of the users")
+	f.Int("page-size", 10, "page size of the history requests")
+
+	rootCmd.AddCommand(benchCmd)
+}
@@ -0,0 +1,66 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/diptomondal007/your-money/app/server/usecase"
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "check the balances against the history",
+	Long: `check compares the stored balance of every user with the balance recomputed from its opening balance and
+history and reports the drifted ones. with --repair the drift of every user is recorded by an adjusting transaction,
+so the history adds up to the stored balance again. the balances themselves are never changed`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repair, _ := cmd.Flags().GetBool("repair")
+
+		ur, err := newUserRepo()
+		if err != nil {
+			return err
+		}
+
+		res, err := usecase.NewLedgerUseCase(ur).Check(cmd.Context(), repair, func(d usecase.Discrepancy) {
+			fmt.Fprintln(cmd.OutOrStdout(), d)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "checked %d users, %d drifted by %v in total, %d repaired\n",
+			res.Users, res.Drifted, res.Drift, res.Repaired)
+
+		if n := res.Drifted - res.Repaired; n > 0 {
+			return fmt.Errorf("%d balances drifted from the history", n)
+		}
+		return nil
+	},
+}
+
+func init() {
+	checkCmd.Flags().Bool("repair", false, "record the drift of every drifted user by an adjusting transaction")
+
+	rootCmd.AddCommand(checkCmd)
+}
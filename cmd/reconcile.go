@@ -0,0 +1,127 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/diptomondal007/your-money/app/server/handler"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+	"github.com/diptomondal007/your-money/infrastructure/conn"
+)
+
+// reconcileCmd represents the reconcile command
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile <settlement file>",
+	Short: "reconcile a settlement file against the ledger",
+	Long: `reconcile matches the lines of a settlement file of the payment processor to the transactions by transaction id,
+amount and date within the tolerance, and reports the matched, the missing in the ledger, the missing in the file
+and the amount mismatched entries. the transactions posted in the period of the file (from, to) which no line
+matches are missing in the file, the period defaults to the days of the lines. the flags default to the RECONCILE_*
+config. it exits with a non-zero status if any entry isn't matched`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req, err := handler.ParseReconcileParams(func(name string) string {
+			v, _ := cmd.Flags().GetString(strings.ReplaceAll(name, "_", "-"))
+			return v
+		}, config.Get().Reconcile)
+		if err != nil {
+			return err
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "text" && format != "json" && format != "csv" {
+			return fmt.Errorf("unknown format %s, expected text, json or csv", format)
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		req.File = file
+
+		ur, err := newUserRepo()
+		if err != nil {
+			return err
+		}
+
+		// only postgres keeps an audit log
+		var ar repository.AuditRepository
+		if config.Get().Storage.Backend == config.StoragePostgres {
+			ar = repository.NewAuditRepo(conn.GetDB().DB)
+		}
+
+		report, err := usecase.NewReconcileUseCase(ur, ar).Reconcile(cliContext(cmd), req)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = cmd.OutOrStdout()
+		if out, _ := cmd.Flags().GetString("output"); out != "" && out != "-" {
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		switch format {
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(report)
+		case "csv":
+			err = report.WriteCSV(w)
+		default:
+			err = report.Print(w)
+		}
+		if err != nil {
+			return err
+		}
+
+		if n := report.Summary.Discrepancies(); n > 0 {
+			return fmt.Errorf("%d entries not matched", n)
+		}
+		return nil
+	},
+}
+
+func init() {
+	f := reconcileCmd.Flags()
+	f.String("columns", "", "maps the fields to the columns of the file, ex - transaction_id=reference,amount=amount,date=settled_at")
+	f.String("date-format", "", "go time layout of the dates, ex - 2006-01-02")
+	f.String("delimiter", "", "column delimiter, a single character or tab")
+	f.String("tolerance", "", "max difference between the date of a line and the posting of its transaction, ex - 48h")
+	f.String("from", "", "start of the period of the file as a RFC 3339 timestamp")
+	f.String("to", "", "end of the period of the file (exclusive) as a RFC 3339 timestamp")
+	f.String("format", "text", "format of the report. one of text, json, csv")
+	f.StringP("output", "o", "-", "file to write the report to. - writes to stdout")
+
+	rootCmd.AddCommand(reconcileCmd)
+}
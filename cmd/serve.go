@@ -28,10 +28,11 @@ var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "serve command runs the api server",
 	Long:  `serve command runs the api server to http request`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		s := server.NewServer()
-		s.Run()
+		return s.Run()
 	},
+	SilenceUsage: true,
 }
 
 func init() {
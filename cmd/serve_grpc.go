@@ -0,0 +1,40 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/diptomondal007/your-money/app/server"
+)
+
+// serveGRPCCmd represents the serve-grpc command
+var serveGRPCCmd = &cobra.Command{
+	Use:   "serve-grpc",
+	Short: "serve-grpc command runs the grpc api server",
+	Long:  `serve-grpc command runs only the grpc api server on GRPC_PORT, without the rest api`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := server.NewGRPCServer()
+		return s.Run()
+	},
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(serveGRPCCmd)
+}
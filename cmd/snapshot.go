@@ -0,0 +1,71 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "snapshot the balances",
+	Long: `snapshot saves the balance of every user as of a point in time, ex - to close a period. the historical balances
+are computed from the latest snapshot before the requested time. the time defaults to the end of the last period
+closed by SNAPSHOT_INTERVAL and SNAPSHOT_DELAY, the users having a snapshot as of the time already are skipped`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get().Snapshot
+		asOf := time.Now().UTC().Add(-cfg.Delay).Truncate(cfg.Interval)
+
+		if v, _ := cmd.Flags().GetString("as-of"); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return fmt.Errorf("as of should be a valid rfc 3339 time: %w", err)
+			}
+			asOf = t
+		}
+
+		ur, err := newUserRepo()
+		if err != nil {
+			return err
+		}
+
+		res, err := usecase.NewLedgerUseCase(ur).Snapshot(cmd.Context(), asOf)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "saved %d snapshots of %d users as of %s\n",
+			res.Saved, res.Users, res.AsOf.Format(time.RFC3339Nano))
+		return nil
+	},
+}
+
+func init() {
+	snapshotCmd.Flags().String("as-of", "", "time of the snapshots in rfc 3339, ex - 2024-02-01T00:00:00Z")
+
+	rootCmd.AddCommand(snapshotCmd)
+}
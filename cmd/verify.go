@@ -0,0 +1,83 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/diptomondal007/your-money/app/server/ledger"
+	"github.com/diptomondal007/your-money/app/server/repository"
+	"github.com/diptomondal007/your-money/app/server/usecase"
+	"github.com/diptomondal007/your-money/infrastructure/config"
+	"github.com/diptomondal007/your-money/infrastructure/conn"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:          "verify",
+	Short:        "verify the transaction history",
+	Long:         `verify walks the hash chain of every user, recomputes the balances from the history and reports the first broken link of every user or a mismatched balance`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ur, err := newUserRepo()
+		if err != nil {
+			return err
+		}
+		uc := usecase.NewLedgerUseCase(ur)
+
+		res, err := uc.Verify(cmd.Context(), func(issue ledger.Issue) {
+			fmt.Fprintln(cmd.OutOrStdout(), issue)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "verified %d users, %d transactions (%d before the chain was introduced)\n",
+			res.Users, res.Transactions, res.Legacy)
+
+		if res.Issues > 0 {
+			return fmt.Errorf("%d issues found", res.Issues)
+		}
+		return nil
+	},
+}
+
+// newUserRepo returns the user repo of the configured storage backend
+func newUserRepo() (repository.UserRepository, error) {
+	switch backend := config.Get().Storage.Backend; backend {
+	case config.StoragePostgres:
+		if err := conn.ConnectDB(); err != nil {
+			return nil, fmt.Errorf("db connection unsuccessful! response: %w", err)
+		}
+		return repository.NewUserRepo(conn.GetDB().DB), nil
+	case config.StorageSQLite:
+		if err := conn.ConnectSQLite(); err != nil {
+			return nil, fmt.Errorf("sqlite db connection unsuccessful! response: %w", err)
+		}
+		return repository.NewSQLiteUserRepo(conn.GetSQLite()), nil
+	default:
+		return nil, fmt.Errorf("storage backend %s has no history to read", backend)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,30 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+// Auth holds the config for api authentication
+type Auth struct {
+	Enabled bool
+	// APIKeys accepts api keys, they are stored in postgres
+	APIKeys bool
+	// JWKSFile is the path of the local jwks file used to verify jwt bearer tokens.
+	// bearer tokens are rejected if it's not set
+	JWKSFile    string
+	JWTIssuer   string
+	JWTAudience string
+}
@@ -18,13 +18,36 @@
 package config
 
 import (
+	"log/slog"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
-	DB DB
+	Server    Server
+	API       API
+	GRPC      GRPC
+	DB        DB
+	Storage   Storage
+	Auth      Auth
+	Audit     Audit
+	Signing   Signing
+	RateLimit RateLimit
+	Webhook   Webhook
+	Events    Events
+	Snapshot  Snapshot
+	Check     Check
+	Fees      Fees
+	Reconcile Reconcile
+	Log       Log
+	Metrics   Metrics
+	Tracing   Tracing
 }
 
+// defaultRateLimits are the rate limits applied if RATE_LIMITS is not set
+const defaultRateLimits = "add:key=50/s@100,add:user=10/s@20,history:key=20/s@40,balance:key=50/s@100"
+
 var c *Config
 
 func Get() *Config {
@@ -35,6 +58,23 @@ func Get() *Config {
 }
 
 func load() *Config {
+	s := Server{
+		Port:            getEnvInt("SERVER_PORT", 8080),
+		DrainDelay:      getEnvDuration("SHUTDOWN_DRAIN_DELAY", 2*time.Second),
+		ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
+	}
+
+	api := API{
+		LegacyRoutes: getEnvBool("API_LEGACY_ROUTES", true),
+		Deprecation:  getEnvDate("API_LEGACY_DEPRECATION", time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)),
+		Sunset:       getEnvDate("API_LEGACY_SUNSET", time.Date(2027, time.May, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	g := GRPC{
+		Enabled: getEnvBool("GRPC_ENABLED", false),
+		Port:    getEnvInt("GRPC_PORT", 9090),
+	}
+
 	d := DB{
 		Host:     os.Getenv("DB_HOST"),
 		Username: os.Getenv("DB_USER"),
@@ -42,5 +82,153 @@ func load() *Config {
 		Name:     os.Getenv("DB_NAME"),
 		SSLMode:  false,
 	}
-	return &Config{DB: d}
+
+	st := Storage{
+		Backend:    getEnv("STORAGE", StoragePostgres),
+		SQLitePath: getEnv("SQLITE_PATH", "your-money.db"),
+	}
+
+	a := Auth{
+		Enabled:     getEnvBool("AUTH_ENABLED", true),
+		APIKeys:     getEnvBool("AUTH_API_KEYS", true),
+		JWKSFile:    os.Getenv("AUTH_JWKS_FILE"),
+		JWTIssuer:   os.Getenv("AUTH_JWT_ISSUER"),
+		JWTAudience: os.Getenv("AUTH_JWT_AUDIENCE"),
+	}
+
+	au := Audit{
+		Required: getEnvBool("AUDIT_REQUIRED", true),
+	}
+
+	sg := Signing{
+		Enabled:    getEnvBool("SIGNING_ENABLED", false),
+		Keys:       parseSigningKeys(os.Getenv("SIGNING_KEYS")),
+		Tolerance:  getEnvDuration("SIGNING_TOLERANCE", 5*time.Minute),
+		NonceStore: getEnv("SIGNING_NONCE_STORE", "memory"),
+	}
+
+	rules, err := ParseRateLimitRules(getEnv("RATE_LIMITS", defaultRateLimits))
+	if err != nil {
+		slog.Error("invalid RATE_LIMITS, falling back to the defaults", slog.Any("error", err))
+		rules, _ = ParseRateLimitRules(defaultRateLimits)
+	}
+
+	rl := RateLimit{
+		Enabled: getEnvBool("RATE_LIMIT_ENABLED", true),
+		Store:   getEnv("RATE_LIMIT_STORE", "memory"),
+		Rules:   rules,
+	}
+
+	w := Webhook{
+		Enabled:     getEnvBool("WEBHOOK_ENABLED", true),
+		Interval:    getEnvDuration("WEBHOOK_INTERVAL", time.Second),
+		BatchSize:   getEnvInt("WEBHOOK_BATCH_SIZE", 50),
+		Timeout:     getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		MaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 10),
+		BackoffBase: getEnvDuration("WEBHOOK_BACKOFF_BASE", 5*time.Second),
+		BackoffMax:  getEnvDuration("WEBHOOK_BACKOFF_MAX", time.Hour),
+	}
+
+	ev := Events{
+		Enabled:   getEnvBool("EVENTS_ENABLED", true),
+		Heartbeat: getEnvDuration("EVENTS_HEARTBEAT", 15*time.Second),
+	}
+
+	sn := Snapshot{
+		Enabled:  getEnvBool("SNAPSHOT_ENABLED", true),
+		Interval: getEnvDuration("SNAPSHOT_INTERVAL", 24*time.Hour),
+		Delay:    getEnvDuration("SNAPSHOT_DELAY", time.Minute),
+	}
+
+	ck := Check{
+		Enabled:  getEnvBool("CHECK_ENABLED", true),
+		Interval: getEnvDuration("CHECK_INTERVAL", time.Hour),
+	}
+
+	fe := Fees{
+		Schedules:       os.Getenv("FEE_SCHEDULES"),
+		DefaultSchedule: os.Getenv("FEE_DEFAULT_SCHEDULE"),
+		HouseAccount:    getEnv("FEE_HOUSE_ACCOUNT", "house"),
+	}
+
+	rc := Reconcile{
+		Columns:       getEnv("RECONCILE_COLUMNS", "transaction_id=transaction_id,amount=amount,date=date"),
+		DateFormat:    getEnv("RECONCILE_DATE_FORMAT", "2006-01-02"),
+		Delimiter:     getEnv("RECONCILE_DELIMITER", ","),
+		DateTolerance: getEnvDuration("RECONCILE_DATE_TOLERANCE", 48*time.Hour),
+	}
+
+	l := Log{
+		Level:  getEnv("LOG_LEVEL", "info"),
+		Format: getEnv("LOG_FORMAT", "json"),
+	}
+
+	m := Metrics{
+		Enabled: getEnvBool("METRICS_ENABLED", true),
+		Path:    getEnv("METRICS_PATH", "/metrics"),
+	}
+
+	t := Tracing{
+		Exporter:     getEnv("TRACING_EXPORTER", "none"),
+		ServiceName:  getEnv("TRACING_SERVICE_NAME", "your-money"),
+		OTLPEndpoint: os.Getenv("TRACING_OTLP_ENDPOINT"),
+		OTLPInsecure: getEnvBool("TRACING_OTLP_INSECURE", false),
+		File:         getEnv("TRACING_FILE", "traces.json"),
+		SampleRatio:  getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+	}
+
+	return &Config{Server: s, API: api, GRPC: g, DB: d, Storage: st, Auth: a, Audit: au, Signing: sg, RateLimit: rl, Webhook: w, Events: ev, Snapshot: sn, Check: ck, Fees: fe, Reconcile: rc, Log: l, Metrics: m, Tracing: t}
+}
+
+// getEnv returns the value of the env variable or the fallback if it's not set
+func getEnv(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+// getEnvBool returns the boolean value of the env variable or the fallback if it's not set or invalid
+func getEnvBool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// getEnvInt returns the int value of the env variable or the fallback if it's not set or invalid
+func getEnvInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// getEnvDuration returns the duration value (ex - 10s) of the env variable or the fallback if it's not set or invalid
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// getEnvDate returns the date value (ex - 2027-05-01) of the env variable or the fallback if it's not set or invalid
+func getEnvDate(key string, fallback time.Time) time.Time {
+	v, err := time.Parse(time.DateOnly, os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// getEnvFloat returns the float value of the env variable or the fallback if it's not set or invalid
+func getEnvFloat(key string, fallback float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return v
 }
@@ -0,0 +1,28 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+// Fees holds the config for the fees charged on the credits
+type Fees struct {
+	// Schedules are the fee schedules, see fee.ParseSchedules. no fees are charged if it's empty
+	Schedules string
+	// DefaultSchedule is the schedule of the users without a schedule of their own, none if empty
+	DefaultSchedule string
+	// HouseAccount is the id of the user the fees are credited to. the house account pays no fees
+	HouseAccount string
+}
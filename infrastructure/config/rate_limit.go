@@ -0,0 +1,106 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit holds the config for rate limiting
+type RateLimit struct {
+	Enabled bool
+	// Store is one of memory or postgres
+	Store string
+	Rules []RateLimitRule
+}
+
+// RateLimitRule is a token bucket limit of a route for one dimension
+type RateLimitRule struct {
+	// Route is the name of the route, ex - add, balance, history
+	Route string
+	// Dimension is key to limit per caller or user to limit per target user
+	Dimension string
+	// Rate is the number of tokens refilled per second
+	Rate float64
+	// Burst is the size of the bucket
+	Burst int
+}
+
+// ParseRateLimitRules parses a comma separated list of rules in the form route:dimension=count/unit[@burst],
+// ex - add:key=10/s@20,add:user=60/m. unit is one of s, m or h and burst defaults to count
+func ParseRateLimitRules(s string) ([]RateLimitRule, error) {
+	rules := make([]RateLimitRule, 0)
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+
+		target, limit, ok := strings.Cut(r, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit rule %q", r)
+		}
+
+		route, dimension, ok := strings.Cut(target, ":")
+		if !ok || route == "" || (dimension != "key" && dimension != "user") {
+			return nil, fmt.Errorf("invalid rate limit target %q", target)
+		}
+
+		limit, burstStr, hasBurst := strings.Cut(limit, "@")
+		countStr, unit, ok := strings.Cut(limit, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit %q", limit)
+		}
+
+		count, err := strconv.Atoi(countStr)
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("invalid rate limit count %q", countStr)
+		}
+
+		var per time.Duration
+		switch unit {
+		case "s":
+			per = time.Second
+		case "m":
+			per = time.Minute
+		case "h":
+			per = time.Hour
+		default:
+			return nil, fmt.Errorf("invalid rate limit unit %q", unit)
+		}
+
+		burst := count
+		if hasBurst {
+			burst, err = strconv.Atoi(burstStr)
+			if err != nil || burst < 1 {
+				return nil, fmt.Errorf("invalid rate limit burst %q", burstStr)
+			}
+		}
+
+		rules = append(rules, RateLimitRule{
+			Route:     route,
+			Dimension: dimension,
+			Rate:      float64(count) / per.Seconds(),
+			Burst:     burst,
+		})
+	}
+	return rules, nil
+}
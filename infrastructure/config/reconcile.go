@@ -0,0 +1,32 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import "time"
+
+// Reconcile holds the defaults for reading the settlement files, the cli and the api may override them per file
+type Reconcile struct {
+	// Columns maps the fields to the columns of the file, ex - transaction_id=reference,amount=amount,date=settled_at
+	Columns string
+	// DateFormat is the go time layout of the dates
+	DateFormat string
+	// Delimiter separates the columns
+	Delimiter string
+	// DateTolerance is the max difference between the date of a line and the posting of its transaction
+	DateTolerance time.Duration
+}
@@ -0,0 +1,30 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import "time"
+
+// Server holds the config for the http server
+type Server struct {
+	Port int
+	// DrainDelay is how long the server keeps serving after readiness turned unhealthy
+	// so that load balancers stop sending new requests before the listener is closed
+	DrainDelay time.Duration
+	// ShutdownTimeout bounds the whole shutdown including draining in-flight requests
+	ShutdownTimeout time.Duration
+}
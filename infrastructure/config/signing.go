@@ -0,0 +1,49 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Signing holds the config for hmac signed credit requests
+type Signing struct {
+	// Enabled requires a valid signature on credit requests
+	Enabled bool
+	// Keys holds the active secrets by key id. a key id may have multiple
+	// active secrets while it's being rotated
+	Keys map[string][]string
+	// Tolerance is the max allowed clock difference of the signature timestamp
+	Tolerance time.Duration
+	// NonceStore is one of memory or postgres
+	NonceStore string
+}
+
+// parseSigningKeys parses a comma separated list of key_id=secret pairs
+func parseSigningKeys(s string) map[string][]string {
+	keys := map[string][]string{}
+	for _, pair := range strings.Split(s, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || id == "" || secret == "" {
+			continue
+		}
+		keys[id] = append(keys[id], secret)
+	}
+	return keys
+}
@@ -0,0 +1,30 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import "time"
+
+// Snapshot holds the config for the periodic balance snapshots
+type Snapshot struct {
+	// Enabled takes a snapshot of every user at the end of every period
+	Enabled bool
+	// Interval is the length of a period. ex - 24h closes a period at every midnight utc
+	Interval time.Duration
+	// Delay is how long a snapshot waits after the end of a period, so that the postings made before it are committed
+	Delay time.Duration
+}
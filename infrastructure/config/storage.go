@@ -0,0 +1,57 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+// storage backends of the users and their transactions
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+	StorageSQLite   = "sqlite"
+)
+
+// Storage holds the config of the storage of the users and their transactions
+type Storage struct {
+	// Backend is postgres, sqlite or memory. the memory backend needs no db, it's meant for local development
+	// and tests, and loses every change on restart
+	Backend string
+	// SQLitePath is the file of the sqlite db, it's created if it doesn't exist
+	SQLitePath string
+}
+
+// Unsupported returns the env vars of the enabled features which the storage backend can't serve. only postgres
+// keeps the api keys, the audit log and the outbox of the webhooks, and publishes the balance events
+func (c *Config) Unsupported() []string {
+	if c.Storage.Backend == StoragePostgres {
+		return nil
+	}
+
+	var vars []string
+	if c.Auth.Enabled && c.Auth.APIKeys {
+		vars = append(vars, "AUTH_API_KEYS")
+	}
+	if c.Audit.Required {
+		vars = append(vars, "AUDIT_REQUIRED")
+	}
+	if c.Webhook.Enabled {
+		vars = append(vars, "WEBHOOK_ENABLED")
+	}
+	if c.Events.Enabled {
+		vars = append(vars, "EVENTS_ENABLED")
+	}
+	return vars
+}
@@ -0,0 +1,46 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnsupported(t *testing.T) {
+	all := func(backend string) *Config {
+		return &Config{
+			Storage: Storage{Backend: backend},
+			Auth:    Auth{Enabled: true, APIKeys: true},
+			Audit:   Audit{Required: true},
+			Webhook: Webhook{Enabled: true},
+			Events:  Events{Enabled: true},
+		}
+	}
+
+	assert.Empty(t, all(StoragePostgres).Unsupported())
+	assert.Equal(t, []string{"AUTH_API_KEYS", "AUDIT_REQUIRED", "WEBHOOK_ENABLED", "EVENTS_ENABLED"}, all(StorageMemory).Unsupported())
+
+	c := all(StorageSQLite)
+	c.Auth.Enabled = false
+	c.Audit.Required = false
+	c.Webhook.Enabled = false
+	c.Events.Enabled = false
+	assert.Empty(t, c.Unsupported())
+}
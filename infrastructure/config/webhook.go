@@ -0,0 +1,37 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import "time"
+
+// Webhook holds the config for the webhook dispatcher
+type Webhook struct {
+	// Enabled runs the dispatcher. events are written to the outbox either way
+	Enabled bool
+	// Interval is how often the outbox and the due deliveries are polled
+	Interval time.Duration
+	// BatchSize is the max number of events or deliveries handled per poll
+	BatchSize int
+	// Timeout is the timeout of a single delivery
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts before a delivery is dead-lettered
+	MaxAttempts int
+	// BackoffBase is the delay before the first retry, doubled on every attempt up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
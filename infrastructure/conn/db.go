@@ -19,7 +19,7 @@ package conn
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -41,12 +41,10 @@ func GetDB() *postgresClient {
 
 func ConnectDB() error {
 	if db != nil {
-		log.Println("db already initialized!")
+		slog.Debug("db already initialized!")
 		return nil
 	}
-	cfg := config.Get().DB
-
-	d, err := sqlx.Connect("postgres", fmt.Sprintf("host=%s port=5432 user=%s password=%s dbname=%s sslmode=disable", cfg.Host, cfg.Username, cfg.Password, cfg.Name))
+	d, err := sqlx.Connect("postgres", DSN(config.Get().DB))
 	if err != nil {
 		return err
 	}
@@ -54,3 +52,23 @@ func ConnectDB() error {
 	db = &postgresClient{d}
 	return nil
 }
+
+// DSN returns the connection string of the db
+func DSN(cfg config.DB) string {
+	return fmt.Sprintf("host=%s port=5432 user=%s password=%s dbname=%s sslmode=disable", cfg.Host, cfg.Username, cfg.Password, cfg.Name)
+}
+
+// CloseDB closes the connection pools. it waits for the queries in progress to finish
+func CloseDB() error {
+	if err := closeSQLite(); err != nil {
+		return err
+	}
+
+	if db == nil {
+		return nil
+	}
+
+	err := db.Close()
+	db = nil
+	return err
+}
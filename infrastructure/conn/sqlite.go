@@ -0,0 +1,87 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conn
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/diptomondal007/your-money/infrastructure/config"
+	"github.com/diptomondal007/your-money/infrastructure/db/sqlite"
+)
+
+var (
+	sqliteDB *sqlx.DB
+)
+
+// GetSQLite returns the sqlite db, nil if it's not connected
+func GetSQLite() *sqlx.DB {
+	return sqliteDB
+}
+
+// ConnectSQLite opens the sqlite db and applies its migrations
+func ConnectSQLite() error {
+	if sqliteDB != nil {
+		slog.Debug("sqlite db already initialized!")
+		return nil
+	}
+
+	d, err := OpenSQLite(context.Background(), config.Get().Storage.SQLitePath)
+	if err != nil {
+		return err
+	}
+
+	sqliteDB = d
+	return nil
+}
+
+// OpenSQLite opens the sqlite db of the file path and applies its migrations
+func OpenSQLite(ctx context.Context, path string) (*sqlx.DB, error) {
+	d, err := sqlx.Open("sqlite3", SQLiteDSN(path))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sqlite.Migrate(ctx, d); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// SQLiteDSN returns the connection string of the sqlite db of the file path. the transactions are begun
+// immediate, so they take the write lock of the db up front and the writers are serialized without row locks.
+// the writers waiting for the lock are retried for the busy timeout
+func SQLiteDSN(path string) string {
+	return fmt.Sprintf("file:%s?_txlock=immediate&_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on", path)
+}
+
+// closeSQLite closes the sqlite db
+func closeSQLite() error {
+	if sqliteDB == nil {
+		return nil
+	}
+
+	err := sqliteDB.Close()
+	sqliteDB = nil
+	return err
+}
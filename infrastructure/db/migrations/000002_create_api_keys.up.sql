@@ -0,0 +1,11 @@
+CREATE TABLE "api_keys" (
+    id serial primary key unique,
+    key_id varchar(32) not null,
+    name varchar(100) not null,
+    hashed_secret varchar(64) not null,
+    created_at timestamptz not null,
+    revoked_at timestamptz
+);
+
+-- api key table indices
+CREATE UNIQUE INDEX idx_api_keys_key_id ON api_keys(key_id);
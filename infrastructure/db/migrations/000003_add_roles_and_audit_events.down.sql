@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "audit_events";
+
+ALTER TABLE "api_keys" DROP COLUMN IF EXISTS scopes;
+ALTER TABLE "api_keys" DROP COLUMN IF EXISTS role;
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS status;
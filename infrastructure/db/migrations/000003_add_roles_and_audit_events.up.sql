@@ -0,0 +1,21 @@
+ALTER TABLE "users" ADD COLUMN status varchar(16) not null default 'active';
+
+ALTER TABLE "api_keys" ADD COLUMN role varchar(32) not null default 'support';
+ALTER TABLE "api_keys" ADD COLUMN scopes text not null default '';
+
+CREATE TABLE "audit_events" (
+    id bigserial primary key unique,
+    created_at timestamptz not null,
+    request_id varchar(128),
+    actor_kind varchar(16) not null,
+    actor_id varchar(64) not null,
+    action varchar(64) not null,
+    resource varchar(128) not null,
+    outcome varchar(16) not null,
+    source_ip varchar(64),
+    details text not null default ''
+);
+
+-- audit event table indices
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at);
+CREATE INDEX idx_audit_events_resource ON audit_events(resource);
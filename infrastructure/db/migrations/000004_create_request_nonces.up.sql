@@ -0,0 +1,10 @@
+CREATE TABLE "request_nonces" (
+    key_id varchar(64) not null,
+    nonce varchar(128) not null,
+    expires_at timestamptz not null,
+
+    primary key (key_id, nonce)
+);
+
+-- request nonce table indices
+CREATE INDEX idx_request_nonces_expires_at ON request_nonces(expires_at);
@@ -0,0 +1,8 @@
+CREATE TABLE "rate_limit_buckets" (
+    key varchar(255) primary key,
+    tokens double precision not null,
+    updated_at timestamptz not null default now()
+);
+
+-- rate limit bucket table indices
+CREATE INDEX idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+DROP FUNCTION IF EXISTS reject_audit_event_change();
+
+DROP INDEX IF EXISTS idx_audit_events_request_id;
+DROP INDEX IF EXISTS idx_audit_events_action;
+DROP INDEX IF EXISTS idx_audit_events_actor_id;
+
+ALTER TABLE "audit_events" DROP COLUMN IF EXISTS payload;
+ALTER TABLE "audit_events" DROP COLUMN IF EXISTS after_balance;
+ALTER TABLE "audit_events" DROP COLUMN IF EXISTS before_balance;
@@ -0,0 +1,23 @@
+ALTER TABLE "audit_events" ADD COLUMN before_balance float4;
+ALTER TABLE "audit_events" ADD COLUMN after_balance float4;
+ALTER TABLE "audit_events" ADD COLUMN payload text not null default '';
+
+-- audit event table indices
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id);
+CREATE INDEX idx_audit_events_action ON audit_events(action);
+CREATE INDEX idx_audit_events_request_id ON audit_events(request_id);
+
+-- audit events are append-only, any attempt to change or remove them fails
+CREATE OR REPLACE FUNCTION reject_audit_event_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE PROCEDURE reject_audit_event_change();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE PROCEDURE reject_audit_event_change();
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_transactions_user_id_id;
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS opening_balance;
+
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS hash;
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS prev_hash;
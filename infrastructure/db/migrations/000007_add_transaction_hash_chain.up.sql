@@ -0,0 +1,9 @@
+ALTER TABLE "transactions" ADD COLUMN prev_hash varchar(64) not null default '';
+ALTER TABLE "transactions" ADD COLUMN hash varchar(64) not null default '';
+
+-- the balance a user had before the first transaction. existing balances are taken as the baseline of the history
+ALTER TABLE "users" ADD COLUMN opening_balance float4 not null default 0;
+UPDATE "users" SET opening_balance = COALESCE(balance, 0) - COALESCE((SELECT SUM(t.amount) FROM transactions t WHERE t.user_id = users.id), 0);
+
+-- transaction table indices
+CREATE INDEX idx_transactions_user_id_id ON transactions(user_id, id);
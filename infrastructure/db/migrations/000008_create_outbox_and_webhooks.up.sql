@@ -0,0 +1,42 @@
+CREATE TABLE "outbox_events" (
+    id bigserial primary key unique,
+    created_at timestamptz not null,
+    event_type varchar(64) not null,
+    aggregate_id varchar(64) not null,
+    payload text not null,
+    dispatched_at timestamptz
+);
+
+-- outbox event table indices
+CREATE INDEX idx_outbox_events_pending ON outbox_events(id) WHERE dispatched_at IS NULL;
+
+CREATE TABLE "webhooks" (
+    id bigserial primary key unique,
+    url text not null,
+    secret varchar(128) not null,
+    event_types text not null default '',
+    active boolean not null default true,
+    created_at timestamptz not null,
+    updated_at timestamptz not null
+);
+
+CREATE TABLE "webhook_deliveries" (
+    id bigserial primary key unique,
+    webhook_id bigint not null,
+    event_id bigint not null,
+    status varchar(16) not null,
+    attempts int not null default 0,
+    next_attempt_at timestamptz not null,
+    last_status_code int,
+    last_error text,
+    created_at timestamptz not null,
+    updated_at timestamptz not null,
+    delivered_at timestamptz,
+
+    CONSTRAINT fk_webhook_id FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE,
+    CONSTRAINT fk_event_id FOREIGN KEY (event_id) REFERENCES outbox_events(id)
+);
+
+-- webhook delivery table indices
+CREATE UNIQUE INDEX idx_webhook_deliveries_webhook_event ON webhook_deliveries(webhook_id, event_id);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
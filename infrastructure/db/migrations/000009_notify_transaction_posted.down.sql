@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS transactions_notify_posted ON transactions;
+DROP FUNCTION IF EXISTS notify_transaction_posted();
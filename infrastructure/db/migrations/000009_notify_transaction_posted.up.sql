@@ -0,0 +1,11 @@
+-- every posted transaction notifies the listening server instances once the posting transaction commits
+CREATE OR REPLACE FUNCTION notify_transaction_posted() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('transaction_posted', json_build_object('user_id', NEW.user_id, 'id', NEW.id)::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER transactions_notify_posted
+    AFTER INSERT ON transactions
+    FOR EACH ROW EXECUTE PROCEDURE notify_transaction_posted();
@@ -0,0 +1,4 @@
+ALTER TABLE "transactions" ALTER COLUMN transaction_id TYPE varchar(64);
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS fee;
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS fee_schedule;
@@ -0,0 +1,6 @@
+-- the fee schedule of a user, the default schedule applies if it's empty
+ALTER TABLE "users" ADD COLUMN fee_schedule varchar(64) not null default '';
+
+-- the fee charged on a credit. the fee is debited by a transaction of its own, its id extends the id of the credit
+ALTER TABLE "transactions" ADD COLUMN fee float4 not null default 0;
+ALTER TABLE "transactions" ALTER COLUMN transaction_id TYPE varchar(80);
@@ -0,0 +1,92 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sqlite
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migrations are the up migrations of the sqlite schema. they are applied in the order of their version
+//
+//go:embed migrations/*.up.sql
+var migrations embed.FS
+
+// Migrate applies the migrations which were not applied to db yet. the applied versions are kept in schema_migrations
+func Migrate(ctx context.Context, db *sqlx.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "schema_migrations" (version integer primary key, applied_at datetime)`); err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.up.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		version, err := strconv.Atoi(strings.SplitN(strings.TrimPrefix(f, "migrations/"), "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("invalid migration %s: %w", f, err)
+		}
+
+		if err := apply(ctx, db, version, f); err != nil {
+			return fmt.Errorf("migration %s failed: %w", f, err)
+		}
+	}
+	return nil
+}
+
+// apply runs the migration file f of version in a transaction unless it was applied already
+func apply(ctx context.Context, db *sqlx.DB, version int, f string) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var applied int
+	if err := tx.GetContext(ctx, &applied, `SELECT COUNT(*) FROM "schema_migrations" WHERE version = ?`, version); err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	script, err := migrations.ReadFile(f)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO "schema_migrations" (version, applied_at) VALUES (?, ?)`, version, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
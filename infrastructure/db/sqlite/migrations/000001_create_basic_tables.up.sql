@@ -0,0 +1,32 @@
+-- the sqlite schema of the users and their transactions. it matches the postgres schema after its migrations,
+-- only the tables used by the user repository are created
+
+CREATE TABLE "users" (
+    id varchar(64) primary key,
+    created_at datetime,
+    updated_at datetime,
+    name varchar(100),
+    balance real,
+    status varchar(16) not null default 'active',
+    -- the balance a user had before the first transaction
+    opening_balance real not null default 0
+);
+
+CREATE TABLE "transactions" (
+    id integer primary key autoincrement,
+    created_at datetime,
+    amount real,
+    transaction_id varchar(64),
+    user_id varchar(64),
+    prev_hash varchar(64) not null default '',
+    hash varchar(64) not null default '',
+
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- transaction table indices
+CREATE UNIQUE INDEX idx_transaction_id ON transactions(transaction_id);
+CREATE INDEX idx_transactions_user_id_id ON transactions(user_id, id);
+
+-- load sample data
+INSERT into users (id, created_at, updated_at, name, balance, opening_balance) VALUES ('6d7750a1-c3f2-4765-bf8f-33bc80f3f809', strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), 'Test', 100, 100);
@@ -0,0 +1,15 @@
+-- the balance of every user at the end of a period. point in time balances are computed from the latest snapshot
+-- before the time and the transactions since
+CREATE TABLE "balance_snapshots" (
+    id integer primary key autoincrement,
+    user_id varchar(64) not null,
+    as_of datetime not null,
+    -- the id of the last transaction included in the balance
+    transaction_seq integer not null default 0,
+    balance real not null,
+    created_at datetime not null,
+
+    CONSTRAINT fk_balance_snapshots_user_id FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX idx_balance_snapshots_user_id_as_of ON balance_snapshots(user_id, as_of);
@@ -0,0 +1,2 @@
+-- the reconciliation reads the transactions posted in the period of a settlement file
+CREATE INDEX idx_transactions_created_at ON transactions(created_at);
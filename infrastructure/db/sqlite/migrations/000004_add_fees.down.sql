@@ -0,0 +1,3 @@
+ALTER TABLE "transactions" DROP COLUMN fee;
+
+ALTER TABLE "users" DROP COLUMN fee_schedule;
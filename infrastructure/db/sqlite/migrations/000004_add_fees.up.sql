@@ -0,0 +1,5 @@
+-- the fee schedule of a user, the default schedule applies if it's empty
+ALTER TABLE "users" ADD COLUMN fee_schedule varchar(64) not null default '';
+
+-- the fee charged on a credit. the fee is debited by a transaction of its own
+ALTER TABLE "transactions" ADD COLUMN fee real not null default 0;
@@ -0,0 +1,122 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logger
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// KeyRequestID is the log attribute key of the request id
+const KeyRequestID = "request_id"
+
+// maxRequestIDLength is the max length of an incoming request id that is honoured
+const maxRequestIDLength = 128
+
+// RequestID honours the incoming X-Request-ID header or generates a new id. the id is
+// echoed back in the response header and a logger carrying it is stored in the request context
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			rid := RequestIDFrom(req.Header.Get(echo.HeaderXRequestID))
+
+			c.Response().Header().Set(echo.HeaderXRequestID, rid)
+
+			l := FromContext(req.Context()).With(slog.String(KeyRequestID, rid))
+			c.SetRequest(req.WithContext(WithContext(req.Context(), l)))
+
+			return next(c)
+		}
+	}
+}
+
+// AccessLog writes a structured log line for every request
+func AccessLog() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+			if err != nil {
+				// let the error handler write the response so that the real status is logged
+				c.Error(err)
+			}
+
+			req := c.Request()
+			res := c.Response()
+
+			attrs := []any{
+				slog.String("method", req.Method),
+				slog.String("uri", req.RequestURI),
+				slog.String("route", c.Path()),
+				slog.Int("status", res.Status),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_ip", c.RealIP()),
+				slog.Int64("bytes_out", res.Size),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.Any("error", err))
+			}
+
+			l := FromContext(req.Context())
+			switch {
+			case res.Status >= 500:
+				l.Error("request", attrs...)
+			case res.Status >= 400:
+				l.Warn("request", attrs...)
+			default:
+				l.Info("request", attrs...)
+			}
+
+			return err
+		}
+	}
+}
+
+// RequestIDFrom returns the incoming request id if it's valid, otherwise a newly generated id
+func RequestIDFrom(incoming string) string {
+	if validRequestID(incoming) {
+		return incoming
+	}
+	return newRequestID()
+}
+
+func validRequestID(rid string) bool {
+	if rid == "" || len(rid) > maxRequestIDLength {
+		return false
+	}
+
+	for _, r := range rid {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,105 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+func newTestServer(buf *bytes.Buffer) *echo.Echo {
+	slog.SetDefault(New(config.Log{Level: "debug", Format: FormatJSON}, buf))
+
+	e := echo.New()
+	e.Use(RequestID())
+	e.Use(AccessLog())
+	e.GET("/users/:uid/balance", func(c echo.Context) error {
+		FromContext(c.Request().Context()).Info("handler called")
+		return c.NoContent(http.StatusOK)
+	})
+	return e
+}
+
+func TestRequestIDHonoursIncomingHeader(t *testing.T) {
+	buf := &bytes.Buffer{}
+	e := newTestServer(buf)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1/balance", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-123")
+	rec := httptest.NewRecorder()
+
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, "req-123", rec.Header().Get(echo.HeaderXRequestID))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		for _, line := range lines {
+			entry := map[string]interface{}{}
+			assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+			assert.Equal(t, "req-123", entry[KeyRequestID])
+		}
+	}
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+	}{
+		{name: "missing", incoming: ""},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "control characters", incoming: "abc\ndef"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestServer(&bytes.Buffer{})
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1/balance", nil)
+			req.Header.Set(echo.HeaderXRequestID, tt.incoming)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			rid := rec.Header().Get(echo.HeaderXRequestID)
+			assert.Len(t, rid, 32)
+			assert.NotEqual(t, tt.incoming, rid)
+		})
+	}
+}
+
+func TestNewTextFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(config.Log{Level: "warn", Format: FormatText}, buf)
+
+	l.Info("not written")
+	l.Warn("written", slog.String("key", "value"))
+
+	assert.NotContains(t, buf.String(), "not written")
+	assert.Contains(t, buf.String(), "level=WARN msg=written key=value")
+}
@@ -0,0 +1,187 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "your_money"
+
+// add balance results
+const (
+	ResultProcessed = "processed"
+	ResultDuplicate = "duplicate"
+	ResultFailed    = "failed"
+)
+
+// webhook delivery results
+const (
+	ResultDelivered = "delivered"
+	ResultRetry     = "retry"
+	ResultDead      = "dead"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of http requests by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of http requests by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+
+	addBalance = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "add_balance_total",
+		Help:      "Total number of add balance calls by result (processed, duplicate, failed).",
+	}, []string{"result"})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Total number of webhook delivery attempts by result (delivered, retry, dead).",
+	}, []string{"result"})
+
+	transactionAmount = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "transaction_amount",
+		Help:      "Distribution of processed transaction amounts.",
+		Buckets:   []float64{1, 5, 10, 50, 100, 500, 1000, 5000, 10000, 50000},
+	})
+
+	ledgerDrift = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ledger_balance_drift",
+		Help:      "Sum of the absolute drift of the stored balances from the history left by the last integrity check.",
+	})
+
+	ledgerDriftedUsers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ledger_drifted_users",
+		Help:      "Number of users whose stored balance drifted from the history left by the last integrity check.",
+	})
+
+	ledgerCheckTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ledger_check_timestamp_seconds",
+		Help:      "Unix time the last integrity check completed.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		addBalance,
+		transactionAmount,
+		webhookDeliveries,
+		ledgerDrift,
+		ledgerDriftedUsers,
+		ledgerCheckTimestamp,
+	)
+}
+
+// Handler returns the http handler which exposes the registered metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDBStats registers a collector exporting the connection pool stats of db
+func RegisterDBStats(db *sql.DB, dbName string) error {
+	err := registry.Register(collectors.NewDBStatsCollector(db, dbName))
+	if err != nil {
+		are := prometheus.AlreadyRegisteredError{}
+		if errors.As(err, &are) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Middleware records request count and latency for every route
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				he := &echo.HTTPError{}
+				if errors.As(err, &he) {
+					status = he.Code
+				} else {
+					status = http.StatusInternalServerError
+				}
+			}
+
+			route := c.Path()
+			if route == "" {
+				route = "unknown"
+			}
+
+			code := strconv.Itoa(status)
+			httpRequests.WithLabelValues(c.Request().Method, route, code).Inc()
+			httpDuration.WithLabelValues(c.Request().Method, route, code).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+// ObserveAddBalance records the result of an add balance call. amount is only observed for processed transactions
+func ObserveAddBalance(result string, amount float64) {
+	addBalance.WithLabelValues(result).Inc()
+	if result == ResultProcessed {
+		transactionAmount.Observe(amount)
+	}
+}
+
+// ObserveWebhookDelivery records the result of a webhook delivery attempt
+func ObserveWebhookDelivery(result string) {
+	webhookDeliveries.WithLabelValues(result).Inc()
+}
+
+// ObserveLedgerCheck records the users whose balance is still drifted after an integrity check and their total
+// absolute drift
+func ObserveLedgerCheck(drifted int, drift float64) {
+	ledgerDrift.Set(drift)
+	ledgerDriftedUsers.Set(float64(drifted))
+	ledgerCheckTimestamp.SetToCurrentTime()
+}
@@ -0,0 +1,75 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/users/:uid/balance", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/users/:uid/history", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusBadRequest)
+	})
+
+	for _, path := range []string{"/users/1/balance", "/users/2/balance", "/users/1/history"} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/users/:uid/balance", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/users/:uid/history", "400")))
+}
+
+func TestObserveAddBalance(t *testing.T) {
+	ObserveAddBalance(ResultProcessed, 10)
+	ObserveAddBalance(ResultDuplicate, 10)
+	ObserveAddBalance(ResultFailed, 10)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(addBalance.WithLabelValues(ResultProcessed)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(addBalance.WithLabelValues(ResultDuplicate)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(addBalance.WithLabelValues(ResultFailed)))
+	assert.Equal(t, 1, testutil.CollectAndCount(transactionAmount))
+}
+
+func TestObserveLedgerCheck(t *testing.T) {
+	ObserveLedgerCheck(2, 12.5)
+
+	assert.Equal(t, 12.5, testutil.ToFloat64(ledgerDrift))
+	assert.Equal(t, float64(2), testutil.ToFloat64(ledgerDriftedUsers))
+	assert.Greater(t, testutil.ToFloat64(ledgerCheckTimestamp), float64(0))
+}
+
+func TestHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.Contains(rec.Body.String(), "your_money_add_balance_total"))
+}
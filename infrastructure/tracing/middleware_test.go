@@ -0,0 +1,82 @@
+// Licensed to Dipto Mondal under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Dipto Mondal licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/diptomondal007/your-money/infrastructure/config"
+)
+
+func TestMiddleware(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/users/:uid/balance", func(c echo.Context) error {
+		_, span := Tracer("test").Start(c.Request().Context(), "child")
+		span.End()
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1/balance", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+
+	e.ServeHTTP(rec, req)
+
+	spans := sr.Ended()
+	if assert.Len(t, spans, 2) {
+		child, server := spans[0], spans[1]
+
+		assert.Equal(t, "GET /users/:uid/balance", server.Name())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+		assert.Equal(t, server.SpanContext().SpanID(), child.Parent().SpanID())
+	}
+
+	assert.Contains(t, rec.Header().Get("traceparent"), "4bf92f3577b34da6a3ce929d0e0e4736")
+}
+
+func TestInitUnknownExporter(t *testing.T) {
+	_, err := Init(configWithExporter("zipkin"))
+	assert.Error(t, err)
+}
+
+func TestInitNone(t *testing.T) {
+	shutdown, err := Init(configWithExporter(ExporterNone))
+	if assert.NoError(t, err) {
+		assert.NoError(t, shutdown(context.Background()))
+	}
+}
+
+func configWithExporter(exporter string) config.Tracing {
+	return config.Tracing{Exporter: exporter, ServiceName: "test", SampleRatio: 1}
+}